@@ -0,0 +1,41 @@
+package api
+
+import "encoding/json"
+
+// Operations of a batch.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// ToDoBatch changes several todos in one transaction.
+type ToDoBatch struct {
+	// Atomic rolls back the whole batch when an operation fails,
+	// otherwise only the failed operations are undone.
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation creates, updates or deletes a todo. Create takes the todo like
+// POST /todo, update takes a JSON Merge Patch of the todo with id like PATCH /todo/:id.
+type BatchOperation struct {
+	Op   string          `json:"op"`
+	ID   int64           `json:"id,omitempty"`
+	ToDo json.RawMessage `json:"todo,omitempty"`
+	// Version makes an update or a delete conditional like If-Match.
+	Version *int64 `json:"version,omitempty"`
+}
+
+// BatchResult is the outcome of an operation, Status is the http status
+// the operation would get on its own.
+type BatchResult struct {
+	Status int        `json:"status"`
+	ToDo   *ToDo      `json:"todo,omitempty"`
+	Error  *ErrorBody `json:"error,omitempty"`
+}
+
+// BatchResponse has the results of the operations in their order.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Domain error kinds. Every layer reports failures the client can act on
+// as one of these, so they can be matched with errors.Is.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrPreconditionFailed means the record has changed since the client has read it.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrAborted means the change was rolled back because another change of the same batch failed.
+	ErrAborted = errors.New("aborted")
+)
+
+// Error is a domain error of the given kind with a message for the client.
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func NewError(kind error, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+func NotFoundf(format string, args ...interface{}) error {
+	return NewError(ErrNotFound, format, args...)
+}
+
+func Conflictf(format string, args ...interface{}) error {
+	return NewError(ErrConflict, format, args...)
+}
+
+func Validationf(format string, args ...interface{}) error {
+	return NewError(ErrValidation, format, args...)
+}
+
+// ErrorResponse is the body of every failed http request.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
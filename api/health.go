@@ -0,0 +1,28 @@
+package api
+
+// DatabaseHealth reports whether the database answers.
+type DatabaseHealth struct {
+	Status string `json:"status"`
+	// Error is why the database is down, without the details of the connection.
+	Error string `json:"error,omitempty"`
+}
+
+// Statuses of DatabaseHealth.
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+)
+
+// PoolStats are the statistics of a database connection pool, the counters
+// and WaitDurationMs add up since the service started.
+type PoolStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMs     int64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64 `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
@@ -0,0 +1,14 @@
+package api
+
+import "time"
+
+// List groups the todos of a user, like "work" or "home".
+// Archived lists keep their todos but take no new ones.
+type List struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"`
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"created_at"`
+}
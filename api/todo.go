@@ -8,9 +8,168 @@ type ToDo struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	UserID    int64     `json:"user_id"`
+	// Version grows with every change of the todo, it is the ETag of the todo.
+	Version    int64      `json:"version"`
+	DueAt      *time.Time `json:"due_at,omitempty"`
+	ReminderAt *time.Time `json:"reminder_at,omitempty"`
+	// Status is changed only by transitions, CompletedAt is set while the todo is done.
+	Status      string     `json:"status"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// DeletedAt is set while the todo is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Priority  string     `json:"priority"`
+	// Position orders the todos of a user, it is changed only by moves.
+	Position string `json:"position"`
+	// ListID is the list of the todo, todos without a list are in the user's inbox.
+	ListID *int64 `json:"list_id,omitempty"`
+	// Tags are sorted by name, they are changed only by the tag endpoints.
+	Tags []string `json:"tags,omitempty"`
+	// ParentID makes the todo a subtask of another todo of the same user.
+	ParentID *int64 `json:"parent_id,omitempty"`
+	// Progress counts the subtasks, it is set only for todos that have subtasks.
+	Progress *Progress `json:"progress,omitempty"`
+	// Recurrence is an iCalendar RRULE like "FREQ=WEEKLY;BYDAY=MO" anchored at SeriesStart.
+	// Completing an occurrence creates the next one, all occurrences share SeriesID.
+	Recurrence  string     `json:"recurrence,omitempty"`
+	SeriesID    *int64     `json:"series_id,omitempty"`
+	SeriesStart *time.Time `json:"series_start,omitempty"`
+}
+
+// SeriesUpdate changes every open occurrence of a recurring todo, nil fields are kept.
+// An empty Recurrence ends the series.
+type SeriesUpdate struct {
+	Message    *string `json:"message,omitempty"`
+	Priority   *string `json:"priority,omitempty"`
+	Recurrence *string `json:"recurrence,omitempty"`
+}
+
+// Progress tells how many of the subtasks of a todo are done, cancelled subtasks are not counted.
+type Progress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+// Statuses of a todo.
+const (
+	StatusOpen       = "open"
+	StatusInProgress = "in_progress"
+	StatusDone       = "done"
+	StatusCancelled  = "cancelled"
+)
+
+// Priorities of a todo, from the lowest.
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+// ToDoMove places a todo right before or right after another todo, exactly one of them is set.
+type ToDoMove struct {
+	Before *int64 `json:"before,omitempty"`
+	After  *int64 `json:"after,omitempty"`
+}
+
+// AnyVersion stands for "*" in a Precondition.
+const AnyVersion int64 = -1
+
+// Precondition limits a change of a todo to some of its versions.
+// It mirrors the If-Match and If-None-Match headers, a nil list places no condition.
+type Precondition struct {
+	IfMatch     []int64
+	IfNoneMatch []int64
+}
+
+// ToDoUpdate holds the fields of a todo to change, nil fields are kept as they are.
+type ToDoUpdate struct {
+	Message    *string
+	DueAt      *TimeUpdate
+	ReminderAt *TimeUpdate
+	Priority   *string
+	ListID     *IDUpdate
+	ParentID   *IDUpdate
+
+	Status      *string
+	CompletedAt *TimeUpdate
+	Position    *string
+	Recurrence  *string
+	SeriesStart *TimeUpdate
+}
+
+// TimeUpdate sets a nullable time, a nil Time clears it.
+type TimeUpdate struct {
+	Time *time.Time
+}
+
+// IDUpdate sets a nullable reference, a nil ID clears it.
+type IDUpdate struct {
+	ID *int64
+}
+
+// Tag is a label of the user's todos, Count is the number of live todos with it.
+type Tag struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// ToDoTags is the body of a tag change.
+type ToDoTags struct {
+	Tags []string `json:"tags"`
 }
 
 type User struct {
 	ID   int64  `json:"id"`
 	Name string `json:"name"`
 }
+
+// ToDoPage is a single page of a user's todos.
+type ToDoPage struct {
+	ToDos  []ToDo `json:"todos"`
+	Total  int64  `json:"total"`
+	Limit  int64  `json:"limit"`
+	Offset int64  `json:"offset"`
+	Next   string `json:"next,omitempty"`
+	Prev   string `json:"prev,omitempty"`
+
+	// NextCursor is set for keyset paginated pages that have more todos.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// SearchResult is a todo found by a search. Snippet is an excerpt of the message
+// with the matches wrapped in <mark> and </mark>, the rest of it is not escaped.
+type SearchResult struct {
+	ToDo    ToDo    `json:"todo"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+// SearchPage is a single page of search results, the best matches first.
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	Total   int64          `json:"total"`
+	Limit   int64          `json:"limit"`
+	Offset  int64          `json:"offset"`
+	Next    string         `json:"next,omitempty"`
+	Prev    string         `json:"prev,omitempty"`
+}
+
+// UserPage is a single page of users.
+type UserPage struct {
+	Users  []User `json:"users"`
+	Total  int64  `json:"total"`
+	Limit  int64  `json:"limit"`
+	Offset int64  `json:"offset"`
+	Next   string `json:"next,omitempty"`
+	Prev   string `json:"prev,omitempty"`
+}
+
+// APIToken is a long-lived credential of a user. Token holds the secret
+// and is only returned once, when the token is created.
+type APIToken struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Token     string    `json:"token,omitempty"`
+}
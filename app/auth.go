@@ -0,0 +1,176 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"to-do/api"
+	"to-do/repository"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// APITokenPrefix tells API tokens apart from JWTs.
+const APITokenPrefix = "todo_"
+
+// maxTokenNameLength matches todo_app.api_tokens.name.
+const maxTokenNameLength = 100
+
+type AuthConfig struct {
+	// JWTKey is the HMAC key of HS256 signed JWTs. The subject claim holds the user id.
+	JWTKey string
+}
+
+func (c AuthConfig) Validate() error {
+	if len(c.JWTKey) == 0 {
+		return errors.New("jwt key cannot be empty")
+	}
+	return nil
+}
+
+type userCtxKey struct{}
+
+// WithUser returns a copy of ctx carrying the authenticated user.
+func WithUser(ctx context.Context, user *api.User) context.Context {
+	return context.WithValue(ctx, userCtxKey{}, user)
+}
+
+// UserFromContext returns the authenticated user or nil.
+func UserFromContext(ctx context.Context) *api.User {
+	user, _ := ctx.Value(userCtxKey{}).(*api.User)
+	return user
+}
+
+func currentUser(ctx context.Context) (*api.User, error) {
+	user := UserFromContext(ctx)
+	if user == nil {
+		return nil, errUnauthorized("not authenticated")
+	}
+	return user, nil
+}
+
+func errUnauthorized(reason string) error {
+	return api.NewError(api.ErrUnauthorized, reason)
+}
+
+type AuthService struct {
+	db     repository.Storage
+	jwtKey []byte
+}
+
+func NewAuthService(db repository.Storage, cfg AuthConfig) (*AuthService, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &AuthService{db: db, jwtKey: []byte(cfg.JWTKey)}, nil
+}
+
+// Authenticate resolves a bearer token, either an API token or a JWT, to its user.
+func (a *AuthService) Authenticate(ctx context.Context, token string) (*api.User, error) {
+	if strings.HasPrefix(token, APITokenPrefix) {
+		return a.authenticateAPIToken(ctx, token)
+	}
+	return a.authenticateJWT(ctx, token)
+}
+
+func (a *AuthService) authenticateAPIToken(ctx context.Context, token string) (*api.User, error) {
+	user, err := a.db.GetAPITokenUser(ctx, hashToken(token))
+	if errors.Is(err, api.ErrNotFound) {
+		return nil, errUnauthorized("unknown api token")
+	}
+	if err != nil {
+		log.Error("cant return api token user: ", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+func (a *AuthService) authenticateJWT(ctx context.Context, token string) (*api.User, error) {
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return a.jwtKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		log.Debug("invalid jwt: ", err)
+		return nil, errUnauthorized("invalid jwt")
+	}
+	if claims.ExpiresAt == nil {
+		return nil, errUnauthorized("jwt without expiration")
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, errUnauthorized("invalid jwt subject")
+	}
+	user, err := a.db.GetUser(ctx, userID)
+	if errors.Is(err, api.ErrNotFound) {
+		return nil, errUnauthorized("unknown jwt subject")
+	}
+	if err != nil {
+		log.Error("cant return jwt user: ", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateAPIToken creates a token for the authenticated user. The returned
+// token is the only place the secret is available.
+func (a *AuthService) CreateAPIToken(ctx context.Context, name string) (*api.APIToken, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(name) == 0 || len([]rune(name)) > maxTokenNameLength {
+		return nil, api.Validationf("token name must be 1 to %d characters", maxTokenNameLength)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, errors.Wrap(err, "generate api token")
+	}
+	plain := APITokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	token, err := a.db.CreateAPIToken(ctx, api.APIToken{UserID: user.ID, Name: name}, hashToken(plain))
+	if err != nil {
+		log.Error("cant create api token: ", err)
+		return nil, err
+	}
+	token.Token = plain
+	return token, nil
+}
+
+func (a *AuthService) ListAPITokens(ctx context.Context) ([]api.APIToken, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := a.db.GetAPITokens(ctx, user.ID)
+	if err != nil {
+		log.Error("cant list api tokens: ", err)
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (a *AuthService) DeleteAPIToken(ctx context.Context, tokenID int64) error {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	err = a.db.DeleteAPIToken(ctx, user.ID, tokenID)
+	if err != nil {
+		log.Error("cant delete api token: ", err)
+		return err
+	}
+	return nil
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"to-do/api"
+	"to-do/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxBatchOperations limits the operations of a batch.
+const MaxBatchOperations = 500
+
+// BatchOperation is a single change of a batch, see api.BatchOperation.
+type BatchOperation struct {
+	Op string
+	// ToDo is the todo to create.
+	ToDo api.ToDo
+	// ID is the todo to update or delete.
+	ID    int64
+	Patch ToDoPatch
+	Cond  api.Precondition
+}
+
+// BatchResult is the outcome of a BatchOperation.
+type BatchResult struct {
+	// ToDo is the created or updated todo.
+	ToDo *api.ToDo
+	Err  error
+}
+
+// Batch runs the operations in one transaction with the checks of CreateToDo, PatchToDo and DeleteTodo.
+// A failed operation is undone alone, unless atomic is set: then every other operation fails with api.ErrAborted.
+func (t *ToDoService) Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	if _, err := currentUser(ctx); err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, api.Validationf("batch has no operations")
+	}
+	if len(ops) > MaxBatchOperations {
+		return nil, api.Validationf("batch cant have more than %d operations", MaxBatchOperations)
+	}
+
+	results := make([]BatchResult, len(ops))
+	calls := make([]func(repository.Storage) error, len(ops))
+	for i, op := range ops {
+		i, op := i, op
+		calls[i] = func(db repository.Storage) error {
+			// A retried batch runs the operation again.
+			results[i] = BatchResult{}
+			service := t.withStorage(db)
+			var err error
+			switch op.Op {
+			case api.BatchCreate:
+				results[i].ToDo, err = service.CreateToDo(ctx, op.ToDo)
+			case api.BatchUpdate:
+				results[i].ToDo, err = service.PatchToDo(ctx, op.ID, op.Patch, op.Cond)
+			case api.BatchDelete:
+				err = service.DeleteTodo(ctx, op.ID, op.Cond)
+			default:
+				err = api.Validationf("unknown operation %q", op.Op)
+			}
+			return err
+		}
+	}
+
+	errs, err := t.db.Batch(ctx, calls, atomic)
+	if err != nil {
+		log.Error("cant run batch: ", err)
+		return nil, err
+	}
+
+	failed := -1
+	for i, err := range errs {
+		results[i].Err = err
+		if err != nil && failed < 0 {
+			failed = i
+		}
+	}
+	if atomic && failed >= 0 {
+		for i := range results {
+			if i != failed {
+				results[i] = BatchResult{Err: api.NewError(api.ErrAborted, "operation %d failed", failed)}
+			}
+		}
+	}
+	return results, nil
+}
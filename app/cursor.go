@@ -0,0 +1,110 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+	"to-do/repository"
+
+	"github.com/pkg/errors"
+)
+
+// cursorPayload is the signed content of a pagination cursor. It is bound
+// to a user and an order so a cursor can not be replayed against another list.
+type cursorPayload struct {
+	UserID    int64     `json:"u"`
+	Order     string    `json:"o,omitempty"`
+	CreatedAt time.Time `json:"t"`
+	ID        int64     `json:"i"`
+	Position  string    `json:"p,omitempty"`
+	Priority  string    `json:"r,omitempty"`
+	// UpdatedAt, DueAt and CompletedAt are used by the custom sorts.
+	UpdatedAt   *time.Time `json:"m,omitempty"`
+	DueAt       *time.Time `json:"d,omitempty"`
+	CompletedAt *time.Time `json:"c,omitempty"`
+}
+
+type cursorCodec struct {
+	key []byte
+}
+
+// newCursorCodec signs cursors with secret. An empty secret gets a random key,
+// so cursors issued before a restart become invalid.
+func newCursorCodec(secret string) (*cursorCodec, error) {
+	if secret != "" {
+		return &cursorCodec{key: []byte(secret)}, nil
+	}
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.Wrap(err, "generate cursor key")
+	}
+	return &cursorCodec{key: key}, nil
+}
+
+func (c *cursorCodec) encode(userID int64, order string, key repository.ToDoKey) (string, error) {
+	payload, err := json.Marshal(cursorPayload{
+		UserID:      userID,
+		Order:       order,
+		CreatedAt:   key.CreatedAt,
+		ID:          key.ID,
+		Position:    key.Position,
+		Priority:    key.Priority,
+		UpdatedAt:   &key.UpdatedAt,
+		DueAt:       key.DueAt,
+		CompletedAt: key.CompletedAt,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "marshal cursor")
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(c.sign(payload)), nil
+}
+
+func (c *cursorCodec) decode(userID int64, order string, cursor string) (*repository.ToDoKey, error) {
+	parts := strings.Split(cursor, ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	signature, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if !hmac.Equal(signature, c.sign(payload)) {
+		return nil, ErrInvalidCursor
+	}
+
+	var p cursorPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if p.UserID != userID || p.Order != order {
+		return nil, ErrInvalidCursor
+	}
+	key := &repository.ToDoKey{
+		CreatedAt:   p.CreatedAt,
+		ID:          p.ID,
+		Position:    p.Position,
+		Priority:    p.Priority,
+		DueAt:       p.DueAt,
+		CompletedAt: p.CompletedAt,
+	}
+	if p.UpdatedAt != nil {
+		key.UpdatedAt = *p.UpdatedAt
+	}
+	return key, nil
+}
+
+func (c *cursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
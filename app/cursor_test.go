@@ -0,0 +1,56 @@
+package app
+
+import (
+	"testing"
+	"time"
+	"to-do/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorCodec(t *testing.T) {
+	assert := assert.New(t)
+
+	codec, err := newCursorCodec("secret")
+	assert.NoError(err)
+
+	key := repository.ToDoKey{
+		CreatedAt: time.Date(2021, 11, 5, 10, 30, 0, 123456000, time.UTC),
+		ID:        42,
+		Position:  "V3",
+		Priority:  "high",
+	}
+	cursor, err := codec.encode(1, repository.OrderPriority, key)
+	assert.NoError(err)
+
+	decoded, err := codec.decode(1, repository.OrderPriority, cursor)
+	assert.NoError(err)
+	assert.True(key.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(key.ID, decoded.ID)
+	assert.Equal(key.Position, decoded.Position)
+	assert.Equal(key.Priority, decoded.Priority)
+
+	tt := []struct {
+		name   string
+		userID int64
+		order  string
+		cursor string
+	}{
+		{name: "Another user", userID: 2, order: repository.OrderPriority, cursor: cursor},
+		{name: "Another order", userID: 1, order: repository.OrderPosition, cursor: cursor},
+		{name: "Tampered payload", userID: 1, order: repository.OrderPriority, cursor: "x" + cursor},
+		{name: "Missing signature", userID: 1, order: repository.OrderPriority, cursor: cursor[:len(cursor)-5]},
+		{name: "Garbage", userID: 1, order: repository.OrderPriority, cursor: "not-a-cursor"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := codec.decode(tc.userID, tc.order, tc.cursor)
+			assert.Equal(ErrInvalidCursor, err, tc.name)
+		})
+	}
+
+	other, err := newCursorCodec("other secret")
+	assert.NoError(err)
+	_, err = other.decode(1, repository.OrderPriority, cursor)
+	assert.Equal(ErrInvalidCursor, err)
+}
@@ -0,0 +1,5 @@
+package app
+
+import "to-do/api"
+
+var ErrInvalidCursor = api.Validationf("invalid cursor")
@@ -0,0 +1,306 @@
+package app
+
+import (
+	"strconv"
+	"time"
+	"to-do/api"
+	"to-do/repository"
+)
+
+// Fields of a Condition.
+const (
+	FieldStatus    = "status"
+	FieldPriority  = "priority"
+	FieldTag       = "tag"
+	FieldList      = "list"
+	FieldText      = "text"
+	FieldCreated   = "created"
+	FieldUpdated   = "updated"
+	FieldDue       = "due"
+	FieldCompleted = "completed"
+)
+
+// Operators of a Condition.
+const (
+	OpEqual        = ":"
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+)
+
+// InboxList is the value of a list condition that selects the todos without a list.
+const InboxList = "inbox"
+
+// maxConditions limits the conditions of a query.
+const maxConditions = 20
+
+// Condition compares a field of the todos with values.
+//
+// OpEqual selects the todos that have any of the values: a status, a priority, a tag,
+// a list id or InboxList, or a search query for the text. The other operators compare
+// a priority or a time with a single value. A time is a date, meaning the whole day in the
+// query location, or an RFC 3339 timestamp.
+type Condition struct {
+	Field  string
+	Op     string
+	Values []string
+}
+
+// SortKey orders the todos by a field, see the repository Sort constants.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// applyConditions narrows the filter with the conditions of the query.
+func (q ToDoQuery) applyConditions(filter *repository.ToDoFilter) error {
+	if len(q.Conditions) > maxConditions {
+		return api.Validationf("query cant have more than %d conditions", maxConditions)
+	}
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	for _, c := range q.Conditions {
+		switch c.Op {
+		case OpEqual, OpLess, OpLessEqual, OpGreater, OpGreaterEqual:
+		default:
+			return api.Validationf("unknown operator %q", c.Op)
+		}
+		if len(c.Values) == 0 {
+			return api.Validationf("%s condition has no value", c.Field)
+		}
+		if c.Op != OpEqual && len(c.Values) > 1 {
+			return api.Validationf("%s%s can compare only a single value", c.Field, c.Op)
+		}
+
+		var err error
+		switch c.Field {
+		case FieldStatus:
+			err = applyStatuses(filter, c)
+		case FieldPriority:
+			err = applyPriorities(filter, c)
+		case FieldTag:
+			err = applyTags(filter, c)
+		case FieldList:
+			err = applyList(filter, c)
+		case FieldText:
+			err = applyText(filter, c)
+		case FieldCreated:
+			err = applyTimeRange(&filter.CreatedFrom, &filter.CreatedTo, c, loc)
+		case FieldUpdated:
+			err = applyTimeRange(&filter.UpdatedFrom, &filter.UpdatedTo, c, loc)
+		case FieldDue:
+			err = applyTimeRange(&filter.DueFrom, &filter.DueTo, c, loc)
+		case FieldCompleted:
+			err = applyTimeRange(&filter.CompletedFrom, &filter.CompletedTo, c, loc)
+		default:
+			err = api.Validationf("unknown filter field %q", c.Field)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyStatuses(filter *repository.ToDoFilter, c Condition) error {
+	if c.Op != OpEqual {
+		return api.Validationf("status can only be compared with %s", OpEqual)
+	}
+	for _, status := range c.Values {
+		if _, ok := transitions[status]; !ok {
+			return api.Validationf("unknown status %q", status)
+		}
+	}
+	filter.Statuses = intersect(filter.Statuses, c.Values)
+	return nil
+}
+
+// applyPriorities compares priorities by urgency, low < normal < high < urgent.
+func applyPriorities(filter *repository.ToDoFilter, c Condition) error {
+	ranks := map[string]int{}
+	for rank, priority := range priorityOrder {
+		ranks[priority] = rank
+	}
+	for _, value := range c.Values {
+		if _, ok := ranks[value]; !ok {
+			return api.Validationf("unknown priority %q", value)
+		}
+	}
+
+	matches := []string{}
+	for _, priority := range priorityOrder {
+		rank := ranks[priority]
+		match := false
+		switch value := ranks[c.Values[0]]; c.Op {
+		case OpEqual:
+			for _, v := range c.Values {
+				match = match || v == priority
+			}
+		case OpLess:
+			match = rank < value
+		case OpLessEqual:
+			match = rank <= value
+		case OpGreater:
+			match = rank > value
+		case OpGreaterEqual:
+			match = rank >= value
+		}
+		if match {
+			matches = append(matches, priority)
+		}
+	}
+	filter.Priorities = intersect(filter.Priorities, matches)
+	return nil
+}
+
+// priorityOrder lists the priorities from the least urgent.
+var priorityOrder = []string{api.PriorityLow, api.PriorityNormal, api.PriorityHigh, api.PriorityUrgent}
+
+// applyTags selects the todos with every tag of single tag conditions and with any tag
+// of a condition with several tags. Only one condition can have several tags.
+func applyTags(filter *repository.ToDoFilter, c Condition) error {
+	if c.Op != OpEqual {
+		return api.Validationf("tag can only be compared with %s", OpEqual)
+	}
+	tags, err := normalizeTags(c.Values)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 1 {
+		filter.AllTags, err = normalizeTags(append(filter.AllTags, tags...))
+		return err
+	}
+	if filter.AnyTags != nil {
+		return api.Validationf("only one tag condition can have several tags")
+	}
+	filter.AnyTags = tags
+	return nil
+}
+
+func applyList(filter *repository.ToDoFilter, c Condition) error {
+	if c.Op != OpEqual || len(c.Values) > 1 {
+		return api.Validationf("list can only be compared with %s and a single list", OpEqual)
+	}
+	if filter.ListID != nil || filter.Inbox {
+		return api.Validationf("list can be filtered only once")
+	}
+	if c.Values[0] == InboxList {
+		filter.Inbox = true
+		return nil
+	}
+	listID, err := strconv.ParseInt(c.Values[0], 10, 64)
+	if err != nil {
+		return api.Validationf("invalid list %q", c.Values[0])
+	}
+	filter.ListID = &listID
+	return nil
+}
+
+func applyText(filter *repository.ToDoFilter, c Condition) error {
+	if c.Op != OpEqual || len(c.Values) > 1 {
+		return api.Validationf("text can only be compared with %s and a single query", OpEqual)
+	}
+	terms, err := parseSearch(c.Values[0])
+	if err != nil {
+		return err
+	}
+	filter.Search = append(filter.Search, terms...)
+	if len(filter.Search) > maxSearchTerms {
+		return api.Validationf("text cant have more than %d terms", maxSearchTerms)
+	}
+	return nil
+}
+
+// applyTimeRange narrows the range [from, to) with the condition.
+func applyTimeRange(from, to **time.Time, c Condition, loc *time.Location) error {
+	if len(c.Values) > 1 {
+		return api.Validationf("%s can only be compared with a single time", c.Field)
+	}
+	start, end, err := parseTimeValue(c.Values[0], loc)
+	if err != nil {
+		return err
+	}
+
+	switch c.Op {
+	case OpEqual:
+		narrowFrom(from, start)
+		narrowTo(to, end)
+	case OpLess:
+		narrowTo(to, start)
+	case OpLessEqual:
+		narrowTo(to, end)
+	case OpGreater:
+		narrowFrom(from, end)
+	case OpGreaterEqual:
+		narrowFrom(from, start)
+	}
+	return nil
+}
+
+// parseTimeValue returns the times [start, end) of a date or a timestamp. The end of
+// a timestamp is the next microsecond, the precision of the stored times.
+func parseTimeValue(value string, loc *time.Location) (time.Time, time.Time, error) {
+	if day, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return day, day.AddDate(0, 0, 1), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, time.Time{}, api.Validationf("invalid time %q, want a date or an RFC 3339 timestamp", value)
+	}
+	return t, t.Add(time.Microsecond), nil
+}
+
+func narrowFrom(from **time.Time, t time.Time) {
+	if *from == nil || t.After(**from) {
+		*from = &t
+	}
+}
+
+func narrowTo(to **time.Time, t time.Time) {
+	if *to == nil || t.Before(**to) {
+		*to = &t
+	}
+}
+
+// sortKeys validates the sort keys of a query.
+func sortKeys(keys []SortKey) ([]repository.SortKey, error) {
+	sort := []repository.SortKey{}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		switch key.Field {
+		case repository.SortCreated, repository.SortUpdated, repository.SortDue,
+			repository.SortCompleted, repository.SortPriority, repository.SortPosition:
+		default:
+			return nil, api.Validationf("unknown sort field %q", key.Field)
+		}
+		if seen[key.Field] {
+			return nil, api.Validationf("sort field %q is used twice", key.Field)
+		}
+		seen[key.Field] = true
+		sort = append(sort, repository.SortKey{Field: key.Field, Desc: key.Desc})
+	}
+	return sort, nil
+}
+
+// cursorOrder names the order of the filter in cursors, so a cursor works only with the order it was issued for.
+func cursorOrder(filter repository.ToDoFilter) string {
+	if len(filter.Sort) == 0 {
+		return filter.Order
+	}
+	order := "sort"
+	for i, key := range filter.Sort {
+		sep := ","
+		if i == 0 {
+			sep = ":"
+		}
+		if key.Desc {
+			sep += "-"
+		}
+		order += sep + key.Field
+	}
+	return order
+}
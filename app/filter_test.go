@@ -0,0 +1,188 @@
+package app
+
+import (
+	"testing"
+	"time"
+	"to-do/api"
+	"to-do/repository"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToDoQueryConditions(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2021, 11, 5, 22, 30, 0, 0, time.UTC)
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	assert.NoError(err)
+	day := func(d int, loc *time.Location) *time.Time {
+		return timePtr(time.Date(2021, 11, d, 0, 0, 0, 0, loc))
+	}
+	listID := int64(7)
+
+	tt := []struct {
+		name     string
+		query    ToDoQuery
+		expected repository.ToDoFilter
+		errKind  error
+	}{
+		{
+			name: "Statuses narrow the status parameter",
+			query: ToDoQuery{Statuses: []string{"open", "done"}, Conditions: []Condition{
+				{Field: FieldStatus, Op: OpEqual, Values: []string{"done", "cancelled"}},
+			}},
+			expected: repository.ToDoFilter{Statuses: []string{"done"}},
+		},
+		{
+			name: "Priority comparisons",
+			query: ToDoQuery{Conditions: []Condition{
+				{Field: FieldPriority, Op: OpGreaterEqual, Values: []string{api.PriorityNormal}},
+				{Field: FieldPriority, Op: OpLess, Values: []string{api.PriorityUrgent}},
+			}},
+			expected: repository.ToDoFilter{Priorities: []string{api.PriorityNormal, api.PriorityHigh}},
+		},
+		{
+			name: "Dates are whole days in the location",
+			query: ToDoQuery{Location: kyiv, Conditions: []Condition{
+				{Field: FieldCreated, Op: OpEqual, Values: []string{"2021-11-03"}},
+				{Field: FieldDue, Op: OpGreater, Values: []string{"2021-11-01"}},
+				{Field: FieldDue, Op: OpLessEqual, Values: []string{"2021-11-10"}},
+			}},
+			expected: repository.ToDoFilter{
+				CreatedFrom: day(3, kyiv),
+				CreatedTo:   day(4, kyiv),
+				DueFrom:     day(2, kyiv),
+				DueTo:       day(11, kyiv),
+			},
+		},
+		{
+			name: "Timestamps",
+			query: ToDoQuery{Conditions: []Condition{
+				{Field: FieldUpdated, Op: OpGreater, Values: []string{"2021-11-05T10:00:00Z"}},
+				{Field: FieldCompleted, Op: OpLess, Values: []string{"2021-11-05T12:00:00Z"}},
+			}},
+			expected: repository.ToDoFilter{
+				UpdatedFrom: timePtr(time.Date(2021, 11, 5, 10, 0, 0, 1000, time.UTC)),
+				CompletedTo: timePtr(time.Date(2021, 11, 5, 12, 0, 0, 0, time.UTC)),
+			},
+		},
+		{
+			name: "Conditions narrow the due parameter",
+			query: ToDoQuery{Due: DueToday, Conditions: []Condition{
+				{Field: FieldDue, Op: OpGreaterEqual, Values: []string{"2021-11-05T12:00:00Z"}},
+			}},
+			expected: repository.ToDoFilter{
+				DueFrom: timePtr(time.Date(2021, 11, 5, 12, 0, 0, 0, time.UTC)),
+				DueTo:   day(6, time.UTC),
+			},
+		},
+		{
+			name: "Tags, list and text",
+			query: ToDoQuery{Conditions: []Condition{
+				{Field: FieldTag, Op: OpEqual, Values: []string{"work"}},
+				{Field: FieldTag, Op: OpEqual, Values: []string{"urgent", "home"}},
+				{Field: FieldTag, Op: OpEqual, Values: []string{"errands"}},
+				{Field: FieldList, Op: OpEqual, Values: []string{"7"}},
+				{Field: FieldText, Op: OpEqual, Values: []string{`"fresh bread" bak*`}},
+			}},
+			expected: repository.ToDoFilter{
+				AllTags: []string{"errands", "work"},
+				AnyTags: []string{"home", "urgent"},
+				ListID:  &listID,
+				Search: []repository.SearchTerm{
+					{Text: "fresh bread", Phrase: true},
+					{Text: "bak", Prefix: true},
+				},
+			},
+		},
+		{
+			name:     "A single key in the direction of an order selects the order",
+			query:    ToDoQuery{Sort: []SortKey{{Field: repository.SortPriority, Desc: true}}},
+			expected: repository.ToDoFilter{Order: repository.OrderPriority},
+		},
+		{
+			name:     "A single key against the direction of an order sorts",
+			query:    ToDoQuery{Sort: []SortKey{{Field: repository.SortPriority}}},
+			expected: repository.ToDoFilter{Sort: []repository.SortKey{{Field: repository.SortPriority}}},
+		},
+		{
+			name:     "Sort",
+			query:    ToDoQuery{Sort: []SortKey{{Field: repository.SortPriority, Desc: true}, {Field: repository.SortCreated}}},
+			expected: repository.ToDoFilter{Sort: []repository.SortKey{{Field: repository.SortPriority, Desc: true}, {Field: repository.SortCreated}}},
+		},
+		{
+			name:    "Unknown sort field",
+			query:   ToDoQuery{Sort: []SortKey{{Field: "message"}, {Field: repository.SortCreated}}},
+			errKind: api.ErrValidation,
+		},
+		{
+			name:    "Repeated sort field",
+			query:   ToDoQuery{Sort: []SortKey{{Field: repository.SortDue}, {Field: repository.SortDue, Desc: true}}},
+			errKind: api.ErrValidation,
+		},
+		{
+			name:    "Unknown field",
+			query:   ToDoQuery{Conditions: []Condition{{Field: "owner", Op: OpEqual, Values: []string{"1"}}}},
+			errKind: api.ErrValidation,
+		},
+		{
+			name:    "Unknown status",
+			query:   ToDoQuery{Conditions: []Condition{{Field: FieldStatus, Op: OpEqual, Values: []string{"lost"}}}},
+			errKind: api.ErrValidation,
+		},
+		{
+			name:    "Status comparison",
+			query:   ToDoQuery{Conditions: []Condition{{Field: FieldStatus, Op: OpLess, Values: []string{"done"}}}},
+			errKind: api.ErrValidation,
+		},
+		{
+			name:    "Comparison with several values",
+			query:   ToDoQuery{Conditions: []Condition{{Field: FieldPriority, Op: OpGreater, Values: []string{"low", "high"}}}},
+			errKind: api.ErrValidation,
+		},
+		{
+			name:    "Invalid time",
+			query:   ToDoQuery{Conditions: []Condition{{Field: FieldDue, Op: OpLess, Values: []string{"next week"}}}},
+			errKind: api.ErrValidation,
+		},
+		{
+			name: "Two lists",
+			query: ToDoQuery{Inbox: true, Conditions: []Condition{
+				{Field: FieldList, Op: OpEqual, Values: []string{"7"}},
+			}},
+			errKind: api.ErrValidation,
+		},
+		{
+			name: "Two tag alternatives",
+			query: ToDoQuery{Conditions: []Condition{
+				{Field: FieldTag, Op: OpEqual, Values: []string{"a", "b"}},
+				{Field: FieldTag, Op: OpEqual, Values: []string{"c", "d"}},
+			}},
+			errKind: api.ErrValidation,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := tc.query.filter(now)
+			if tc.errKind != nil {
+				assert.True(errors.Is(err, tc.errKind), tc.name)
+				return
+			}
+			assert.NoError(err, tc.name)
+			assert.Equal(tc.expected, filter, tc.name)
+		})
+	}
+}
+
+func TestCursorOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("", cursorOrder(repository.ToDoFilter{}))
+	assert.Equal(repository.OrderPriority, cursorOrder(repository.ToDoFilter{Order: repository.OrderPriority}))
+	assert.Equal("sort:-priority,due", cursorOrder(repository.ToDoFilter{Sort: []repository.SortKey{
+		{Field: repository.SortPriority, Desc: true},
+		{Field: repository.SortDue},
+	}}))
+}
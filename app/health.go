@@ -0,0 +1,59 @@
+package app
+
+import (
+	"context"
+	"time"
+	"to-do/api"
+	"to-do/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// pingTimeout bounds the database check of a health request.
+const pingTimeout = 2 * time.Second
+
+type HealthService struct {
+	db repository.Storage
+}
+
+func NewHealthService(db repository.Storage) (*HealthService, error) {
+	return &HealthService{db: db}, nil
+}
+
+// DatabaseHealth pings the database. Storages without a pool, like the in-memory one, are always up.
+// The ping error names the database host, so it is logged and not returned.
+func (h *HealthService) DatabaseHealth(ctx context.Context) api.DatabaseHealth {
+	pool, ok := h.db.(repository.PoolStorage)
+	if !ok {
+		return api.DatabaseHealth{Status: api.HealthUp}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if err := pool.Ping(ctx); err != nil {
+		log.Warn("database health check failed: ", err)
+		return api.DatabaseHealth{Status: api.HealthDown, Error: "database unreachable"}
+	}
+	return api.DatabaseHealth{Status: api.HealthUp}
+}
+
+// PoolStats returns the statistics of the database connection pool.
+func (h *HealthService) PoolStats() (*api.PoolStats, error) {
+	pool, ok := h.db.(repository.PoolStorage)
+	if !ok {
+		return nil, api.NotFoundf("the storage has no connection pool")
+	}
+
+	stats := pool.Stats()
+	return &api.PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+	"to-do/api"
+	"to-do/repository"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// poolStorage is a repository.Storage with a connection pool that fails pings with err.
+type poolStorage struct {
+	repository.Storage
+	err error
+}
+
+func (p poolStorage) Ping(ctx context.Context) error { return p.err }
+
+func (p poolStorage) Stats() sql.DBStats {
+	return sql.DBStats{MaxOpenConnections: 20, OpenConnections: 3, InUse: 1, Idle: 2, WaitDuration: 1500 * time.Millisecond}
+}
+
+func TestDatabaseHealth(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	db, err := repository.NewDBClient(ctx, repository.StorageConfig{Driver: repository.DriverMemory})
+	assert.NoError(err)
+	service, err := NewHealthService(db)
+	assert.NoError(err)
+	assert.Equal(api.DatabaseHealth{Status: api.HealthUp}, service.DatabaseHealth(ctx))
+	_, err = service.PoolStats()
+	assert.True(errors.Is(err, api.ErrNotFound))
+
+	service, err = NewHealthService(poolStorage{Storage: db})
+	assert.NoError(err)
+	assert.Equal(api.DatabaseHealth{Status: api.HealthUp}, service.DatabaseHealth(ctx))
+	stats, err := service.PoolStats()
+	assert.NoError(err)
+	assert.Equal(&api.PoolStats{MaxOpenConnections: 20, OpenConnections: 3, InUse: 1, Idle: 2, WaitDurationMs: 1500}, stats)
+
+	// The error of the driver names the database host, it is not returned.
+	service, err = NewHealthService(poolStorage{Storage: db, err: errors.New("dial tcp 10.0.0.5:5432: connection refused")})
+	assert.NoError(err)
+	assert.Equal(api.DatabaseHealth{Status: api.HealthDown, Error: "database unreachable"}, service.DatabaseHealth(ctx))
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"regexp"
+	"to-do/api"
+	"to-do/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxListNameLength matches todo_app.lists.name.
+const maxListNameLength = 100
+
+// listColor is a #rrggbb color.
+var listColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func validateList(list api.List) error {
+	if len(list.Name) == 0 {
+		return api.Validationf("name cant be empty")
+	}
+	if len([]rune(list.Name)) > maxListNameLength {
+		return api.Validationf("name cant be longer than %d characters", maxListNameLength)
+	}
+	if list.Color != "" && !listColor.MatchString(list.Color) {
+		return api.Validationf("color must look like #rrggbb, got %q", list.Color)
+	}
+	return nil
+}
+
+type ListService struct {
+	db repository.Storage
+}
+
+func NewListService(db repository.Storage) (*ListService, error) {
+	return &ListService{db: db}, nil
+}
+
+// CreateList creates a list owned by the authenticated user, user_id of the list is ignored.
+func (l *ListService) CreateList(ctx context.Context, list api.List) (*api.List, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list.UserID = user.ID
+	if err := validateList(list); err != nil {
+		return nil, err
+	}
+
+	created, err := l.db.CreateList(ctx, list)
+	if err != nil {
+		log.Error("cant create new list: ", err)
+		return nil, err
+	}
+	return created, nil
+}
+
+func (l *ListService) GetList(ctx context.Context, listID int64) (*api.List, error) {
+	return ownList(ctx, l.db, listID)
+}
+
+// ListLists returns the lists of the authenticated user, archived ones only when archived is set.
+func (l *ListService) ListLists(ctx context.Context, archived bool) ([]api.List, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lists, err := l.db.GetLists(ctx, user.ID, archived)
+	if err != nil {
+		log.Error("cant list lists: ", err)
+		return nil, err
+	}
+	return lists, nil
+}
+
+// UpdateList replaces the name, color and archived flag of the list.
+func (l *ListService) UpdateList(ctx context.Context, list api.List) (*api.List, error) {
+	if _, err := ownList(ctx, l.db, list.ID); err != nil {
+		return nil, err
+	}
+	if err := validateList(list); err != nil {
+		return nil, err
+	}
+
+	updated, err := l.db.UpdateList(ctx, list)
+	if err != nil {
+		log.Error("cant update list: ", err)
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeleteList deletes the list, its todos move to the inbox.
+func (l *ListService) DeleteList(ctx context.Context, listID int64) error {
+	if _, err := ownList(ctx, l.db, listID); err != nil {
+		return err
+	}
+
+	err := l.db.DeleteList(ctx, listID)
+	if err != nil {
+		log.Error("cant delete list: ", err)
+		return err
+	}
+	return nil
+}
+
+// ownList returns the list if it belongs to the authenticated user.
+func ownList(ctx context.Context, db repository.ListStorage, listID int64) (*api.List, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := db.GetList(ctx, listID)
+	if err != nil {
+		log.Error("cant return list: ", err)
+		return nil, err
+	}
+	if list.UserID != user.ID {
+		return nil, api.NewError(api.ErrForbidden, "list %d belongs to another user", listID)
+	}
+	return list, nil
+}
+
+// checkListTarget allows todos to be put only into active lists of their owner, nil is the inbox.
+func checkListTarget(ctx context.Context, db repository.ListStorage, listID *int64) error {
+	if listID == nil {
+		return nil
+	}
+	list, err := ownList(ctx, db, *listID)
+	if err != nil {
+		return err
+	}
+	if list.Archived {
+		return api.Validationf("list %d is archived", list.ID)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+	"to-do/api"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ToDoPatch changes the JSON document of a todo.
+type ToDoPatch func(doc []byte) ([]byte, error)
+
+// NewMergePatch parses a JSON Merge Patch (RFC 7396).
+func NewMergePatch(patch []byte) (ToDoPatch, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return nil, errors.Wrap(err, "merge patch must be a JSON object")
+	}
+	return func(doc []byte) ([]byte, error) {
+		return jsonpatch.MergePatch(doc, patch)
+	}, nil
+}
+
+// NewJSONPatch parses a JSON Patch (RFC 6902).
+func NewJSONPatch(patch []byte) (ToDoPatch, error) {
+	operations, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode json patch")
+	}
+	return func(doc []byte) ([]byte, error) {
+		patched, err := operations.Apply(doc)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return nil, api.Conflictf("json patch test operation failed")
+		}
+		return patched, err
+	}, nil
+}
+
+// PatchToDo applies patch to the todo and stores the fields it changed if cond holds.
+func (t *ToDoService) PatchToDo(ctx context.Context, todoID int64, patch ToDoPatch, cond api.Precondition) (*api.ToDo, error) {
+	return t.inTx(ctx, func(t *ToDoService) (*api.ToDo, error) { return t.patchToDo(ctx, todoID, patch, cond) })
+}
+
+func (t *ToDoService) patchToDo(ctx context.Context, todoID int64, patch ToDoPatch, cond api.Precondition) (*api.ToDo, error) {
+	current, err := t.GetTodo(ctx, todoID)
+	if err != nil {
+		return nil, err
+	}
+	// The storage checks cond again, this check covers patches without changes.
+	if err := checkPrecondition(cond, current); err != nil {
+		return nil, err
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal todo")
+	}
+	doc, err = patch(doc)
+	if errors.Is(err, api.ErrConflict) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, api.Validationf("cant apply patch: %s", err)
+	}
+
+	var patched api.ToDo
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return nil, api.Validationf("patched todo is invalid: %s", err)
+	}
+
+	update, err := diffToDo(*current, patched)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateToDo(patched, current.CreatedAt); err != nil {
+		return nil, err
+	}
+	if update.ListID != nil {
+		if err := checkListTarget(ctx, t.db, update.ListID.ID); err != nil {
+			return nil, err
+		}
+	}
+	if update.ParentID != nil {
+		if err := t.checkParent(ctx, todoID, update.ParentID.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	todo, err := t.db.PatchToDo(ctx, todoID, update, cond)
+	if err != nil {
+		log.Error("cant patch todo: ", err)
+		return nil, err
+	}
+	return todo, nil
+}
+
+// diffToDo returns the changes from current to patched and rejects changes of read-only fields.
+func diffToDo(current, patched api.ToDo) (api.ToDoUpdate, error) {
+	var update api.ToDoUpdate
+	switch {
+	case patched.ID != current.ID:
+		return update, api.Validationf("id is read-only")
+	case patched.UserID != current.UserID:
+		return update, api.Validationf("user_id is read-only")
+	case !patched.CreatedAt.Equal(current.CreatedAt):
+		return update, api.Validationf("created_at is read-only")
+	case !patched.UpdatedAt.Equal(current.UpdatedAt):
+		return update, api.Validationf("updated_at is read-only")
+	case patched.Version != current.Version:
+		return update, api.Validationf("version is read-only")
+	case patched.Status != current.Status:
+		return update, api.Validationf("status can be changed only by transitions")
+	case !equalTimes(patched.CompletedAt, current.CompletedAt):
+		return update, api.Validationf("completed_at is read-only")
+	case !equalTimes(patched.DeletedAt, current.DeletedAt):
+		return update, api.Validationf("deleted_at is read-only")
+	case patched.Position != current.Position:
+		return update, api.Validationf("position can be changed only by moves")
+	case !equalStrings(patched.Tags, current.Tags):
+		return update, api.Validationf("tags can be changed only by the tag endpoints")
+	case !equalProgress(patched.Progress, current.Progress):
+		return update, api.Validationf("progress is read-only")
+	case patched.Recurrence != current.Recurrence:
+		return update, api.Validationf("recurrence can be changed only for the whole series")
+	case !equalIDs(patched.SeriesID, current.SeriesID) || !equalTimes(patched.SeriesStart, current.SeriesStart):
+		return update, api.Validationf("series_id and series_start are read-only")
+	}
+
+	if patched.Message != current.Message {
+		update.Message = &patched.Message
+	}
+	if !equalTimes(patched.DueAt, current.DueAt) {
+		update.DueAt = &api.TimeUpdate{Time: patched.DueAt}
+	}
+	if !equalTimes(patched.ReminderAt, current.ReminderAt) {
+		update.ReminderAt = &api.TimeUpdate{Time: patched.ReminderAt}
+	}
+	if patched.Priority != current.Priority {
+		update.Priority = &patched.Priority
+	}
+	if !equalIDs(patched.ListID, current.ListID) {
+		update.ListID = &api.IDUpdate{ID: patched.ListID}
+	}
+	if !equalIDs(patched.ParentID, current.ParentID) {
+		update.ParentID = &api.IDUpdate{ID: patched.ParentID}
+	}
+	return update, nil
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func equalIDs(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalProgress(a, b *api.Progress) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
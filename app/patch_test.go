@@ -0,0 +1,103 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	"to-do/api"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToDoPatches(t *testing.T) {
+	assert := assert.New(t)
+
+	current := api.ToDo{
+		ID:        1,
+		Message:   "Kill more orcs than Gimli",
+		CreatedAt: time.Date(2021, 11, 5, 10, 30, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2021, 11, 5, 10, 30, 0, 0, time.UTC),
+		UserID:    1,
+	}
+	doc, err := json.Marshal(current)
+	assert.NoError(err)
+
+	tt := []struct {
+		name     string
+		newPatch func([]byte) (ToDoPatch, error)
+		patch    string
+		message  *string
+		errKind  error
+	}{
+		{
+			name:     "Merge patch changes message",
+			newPatch: NewMergePatch,
+			patch:    `{"message": "Help Minas Tirith"}`,
+			message:  stringPtr("Help Minas Tirith"),
+		},
+		{
+			name:     "Merge patch without changes",
+			newPatch: NewMergePatch,
+			patch:    `{"message": "Kill more orcs than Gimli"}`,
+		},
+		{
+			name:     "Json patch replaces message",
+			newPatch: NewJSONPatch,
+			patch:    `[{"op": "replace", "path": "/message", "value": "Help Minas Tirith"}]`,
+			message:  stringPtr("Help Minas Tirith"),
+		},
+		{
+			name:     "Read-only id",
+			newPatch: NewMergePatch,
+			patch:    `{"id": 2}`,
+			errKind:  api.ErrValidation,
+		},
+		{
+			name:     "Read-only created_at",
+			newPatch: NewJSONPatch,
+			patch:    `[{"op": "replace", "path": "/created_at", "value": "2000-01-01T00:00:00Z"}]`,
+			errKind:  api.ErrValidation,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			patch, err := tc.newPatch([]byte(tc.patch))
+			assert.NoError(err, tc.name)
+
+			patchedDoc, err := patch(doc)
+			assert.NoError(err, tc.name)
+			var patched api.ToDo
+			assert.NoError(json.Unmarshal(patchedDoc, &patched), tc.name)
+
+			update, err := diffToDo(current, patched)
+			if tc.errKind != nil {
+				assert.True(errors.Is(err, tc.errKind), tc.name)
+				return
+			}
+			assert.NoError(err, tc.name)
+			assert.Equal(tc.message, update.Message, tc.name)
+		})
+	}
+}
+
+func TestJSONPatchTestFailure(t *testing.T) {
+	patch, err := NewJSONPatch([]byte(`[{"op": "test", "path": "/message", "value": "other"}]`))
+	assert.NoError(t, err)
+
+	_, err = patch([]byte(`{"message": "todo"}`))
+	assert.True(t, errors.Is(err, api.ErrConflict))
+}
+
+func TestMalformedPatches(t *testing.T) {
+	_, err := NewMergePatch([]byte(`[1, 2]`))
+	assert.Error(t, err)
+
+	_, err = NewJSONPatch([]byte(`{"op": "replace"}`))
+	assert.Error(t, err)
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"to-do/api"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// positionDigits are the digits of position keys in their byte order.
+// Keys never end with the zero digit, so there is always a key between two others.
+const positionDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// positionBetween returns a key that sorts strictly between a and b.
+// An empty a stands for the start of the list and an empty b for its end.
+func positionBetween(a, b string) (string, error) {
+	if !validPosition(a) || !validPosition(b) {
+		return "", errors.Errorf("invalid position keys %q and %q", a, b)
+	}
+	if b != "" && a >= b {
+		return "", errors.Errorf("position %q is not before %q", a, b)
+	}
+	return midpoint(a, b), nil
+}
+
+// midpoint expects valid keys with a < b, or an empty b.
+func midpoint(a, b string) string {
+	if b != "" {
+		// Keep the common prefix, a is padded with zeros.
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(tail(a, n), b[n:])
+		}
+	}
+
+	lo := strings.IndexByte(positionDigits, digitAt(a, 0))
+	hi := len(positionDigits)
+	if b != "" {
+		hi = strings.IndexByte(positionDigits, b[0])
+	}
+	if hi-lo > 1 {
+		return string(positionDigits[(lo+hi)/2])
+	}
+	// The first digits are adjacent.
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(positionDigits[lo]) + midpoint(tail(a, 1), "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return positionDigits[0]
+}
+
+func tail(key string, n int) string {
+	if n < len(key) {
+		return key[n:]
+	}
+	return ""
+}
+
+func validPosition(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(positionDigits, key[i]) < 0 {
+			return false
+		}
+	}
+	return key == "" || key[len(key)-1] != positionDigits[0]
+}
+
+// MoveToDo places the todo right before or right after another todo of the same user.
+// Only the position of the moved todo changes.
+func (t *ToDoService) MoveToDo(ctx context.Context, todoID int64, move api.ToDoMove, cond api.Precondition) (*api.ToDo, error) {
+	return t.inTx(ctx, func(t *ToDoService) (*api.ToDo, error) { return t.moveToDo(ctx, todoID, move, cond) })
+}
+
+func (t *ToDoService) moveToDo(ctx context.Context, todoID int64, move api.ToDoMove, cond api.Precondition) (*api.ToDo, error) {
+	if (move.Before == nil) == (move.After == nil) {
+		return nil, api.Validationf("exactly one of before and after must be set")
+	}
+	anchorID, before := move.After, false
+	if move.Before != nil {
+		anchorID, before = move.Before, true
+	}
+	if *anchorID == todoID {
+		return nil, api.Validationf("todo %d cant be moved relative to itself", todoID)
+	}
+
+	current, err := t.GetTodo(ctx, todoID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPrecondition(cond, current); err != nil {
+		return nil, err
+	}
+	anchor, err := t.GetTodo(ctx, *anchorID)
+	if err != nil {
+		return nil, err
+	}
+	// Subtasks are reordered only among their siblings.
+	if !equalIDs(anchor.ParentID, current.ParentID) {
+		return nil, api.Validationf("todo %d and todo %d have different parents", todoID, anchor.ID)
+	}
+
+	adjacent, err := t.db.AdjacentPosition(ctx, current.UserID, anchor.Position, before)
+	if err != nil {
+		log.Error("cant find adjacent todo: ", err)
+		return nil, err
+	}
+	var position string
+	if before {
+		position, err = positionBetween(adjacent, anchor.Position)
+	} else {
+		position, err = positionBetween(anchor.Position, adjacent)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The position was computed from the current version.
+	clientCond := cond
+	if cond.IfMatch == nil {
+		cond.IfMatch = []int64{current.Version}
+	}
+	todo, err := t.db.PatchToDo(ctx, todoID, api.ToDoUpdate{Position: &position}, cond)
+	if errors.Is(err, api.ErrPreconditionFailed) && clientCond.IfMatch == nil {
+		return nil, api.Conflictf("todo %d was changed concurrently", todoID)
+	}
+	if err != nil {
+		log.Error("cant move todo: ", err)
+		return nil, err
+	}
+	return todo, nil
+}
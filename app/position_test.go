@@ -0,0 +1,56 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPositionBetween(t *testing.T) {
+	assert := assert.New(t)
+
+	tt := []struct {
+		name string
+		a, b string
+	}{
+		{name: "Empty list"},
+		{name: "At the end", a: "V"},
+		{name: "At the end after the last digit", a: "z"},
+		{name: "At the start", b: "V"},
+		{name: "At the start before zeros", b: "001"},
+		{name: "Between adjacent digits", a: "V", b: "W"},
+		{name: "Between a key and its extension", a: "V", b: "VV"},
+		{name: "Between longer keys", a: "1V", b: "2"},
+		{name: "Before a longer key", a: "1", b: "2V"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			key, err := positionBetween(tc.a, tc.b)
+			assert.NoError(err, tc.name)
+			assert.True(validPosition(key), tc.name)
+			assert.True(tc.a < key, "%s: %q < %q", tc.name, tc.a, key)
+			if tc.b != "" {
+				assert.True(key < tc.b, "%s: %q < %q", tc.name, key, tc.b)
+			}
+		})
+	}
+
+	_, err := positionBetween("W", "V")
+	assert.Error(err)
+	_, err = positionBetween("V0", "")
+	assert.Error(err)
+}
+
+func TestRepeatedInsertsKeepOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	// Inserting again and again right after the first todo must not run out of keys.
+	first, last := "V", "W"
+	for i := 0; i < 200; i++ {
+		key, err := positionBetween(first, last)
+		assert.NoError(err)
+		assert.True(first < key && key < last)
+		last = key
+	}
+}
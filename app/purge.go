@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"time"
+	"to-do/repository"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type PurgeConfig struct {
+	// Retention is how long deleted todos stay in the trash.
+	Retention time.Duration
+	// Interval is the time between two purges.
+	Interval time.Duration
+}
+
+func (c PurgeConfig) Validate() error {
+	if c.Retention <= 0 {
+		return errors.New("trash retention must be positive")
+	}
+	if c.Interval <= 0 {
+		return errors.New("purge interval must be positive")
+	}
+	return nil
+}
+
+// Purger permanently removes todos that stayed in the trash longer than the retention period.
+type Purger struct {
+	db  repository.TODOStorage
+	cfg PurgeConfig
+	now func() time.Time
+}
+
+func NewPurger(db repository.TODOStorage, cfg PurgeConfig) *Purger {
+	return &Purger{db: db, cfg: cfg, now: time.Now}
+}
+
+// Purge removes the expired todos once and returns their number.
+func (p *Purger) Purge(ctx context.Context) (int64, error) {
+	return p.db.PurgeToDos(ctx, p.now().Add(-p.cfg.Retention))
+}
+
+// Run purges on every interval until ctx is done.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.Purge(ctx)
+		if err != nil {
+			log.Error("cant purge todos: ", err)
+		} else if purged > 0 {
+			log.Infof("Purged %d todos from the trash.", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"time"
+	"to-do/api"
+	"to-do/repository"
+)
+
+// Due filters of ToDoQuery.
+const (
+	DueOverdue = "overdue"
+	DueToday   = "today"
+	DueWithin  = "within"
+)
+
+// ToDoQuery selects the todos of a list request.
+type ToDoQuery struct {
+	// Due is one of DueOverdue, DueToday, DueWithin or empty for all todos.
+	Due string
+	// DueWithinDays is the number of days from now for DueWithin.
+	DueWithinDays int
+	// Location defines the day for DueToday, UTC when nil.
+	Location *time.Location
+	// Statuses limits the todos to the given statuses, all when empty.
+	Statuses []string
+	// Deleted lists the todos in the trash instead of the live ones.
+	Deleted bool
+	// ListID limits the todos to a list, Inbox to the todos without a list.
+	ListID *int64
+	Inbox  bool
+	// AnyTags selects the todos with at least one of the tags, AllTags those with every tag.
+	AnyTags []string
+	AllTags []string
+	// Conditions narrow the todos further, a todo must match all of them.
+	Conditions []Condition
+	// Order is one of repository.OrderCreated, OrderPosition or OrderPriority, OrderCreated when empty.
+	Order string
+	// Sort orders the todos by several fields instead of Order. A single key in the
+	// direction of an order, like -priority, selects that order.
+	Sort []SortKey
+}
+
+// sortOrders are the single sort keys that sort like an order, priority DESC sorts from
+// the most urgent like OrderPriority.
+var sortOrders = map[SortKey]string{
+	{Field: repository.SortCreated}:              repository.OrderCreated,
+	{Field: repository.SortPosition}:             repository.OrderPosition,
+	{Field: repository.SortPriority, Desc: true}: repository.OrderPriority,
+}
+
+// filter translates the query to a storage filter relative to now.
+func (q ToDoQuery) filter(now time.Time) (repository.ToDoFilter, error) {
+	filter := repository.ToDoFilter{Deleted: q.Deleted, ListID: q.ListID, Inbox: q.Inbox}
+	if q.ListID != nil && q.Inbox {
+		return filter, api.Validationf("list and inbox cant be used together")
+	}
+	if q.AnyTags != nil {
+		tags, err := normalizeTags(q.AnyTags)
+		if err != nil {
+			return filter, err
+		}
+		filter.AnyTags = tags
+	}
+	if q.AllTags != nil {
+		tags, err := normalizeTags(q.AllTags)
+		if err != nil {
+			return filter, err
+		}
+		filter.AllTags = tags
+	}
+	if len(q.Sort) == 1 && q.Order == "" {
+		if order, ok := sortOrders[q.Sort[0]]; ok {
+			q.Order, q.Sort = order, nil
+		}
+	}
+	if len(q.Sort) > 0 {
+		if q.Order != "" {
+			return filter, api.Validationf("order and sort cant be used together")
+		}
+		sort, err := sortKeys(q.Sort)
+		if err != nil {
+			return filter, err
+		}
+		filter.Sort = sort
+	}
+	switch q.Order {
+	case "", repository.OrderCreated:
+	case repository.OrderPosition, repository.OrderPriority:
+		filter.Order = q.Order
+	default:
+		return filter, api.Validationf("unknown order %q", q.Order)
+	}
+	for _, status := range q.Statuses {
+		if _, ok := transitions[status]; !ok {
+			return filter, api.Validationf("unknown status %q", status)
+		}
+	}
+	if len(q.Statuses) > 0 {
+		filter.Statuses = q.Statuses
+	}
+
+	switch q.Due {
+	case "":
+	case DueOverdue:
+		filter.DueTo = &now
+		// Done and cancelled todos are never overdue.
+		filter.Statuses = intersect(filter.Statuses, activeStatuses)
+	case DueToday:
+		loc := q.Location
+		if loc == nil {
+			loc = time.UTC
+		}
+		local := now.In(loc)
+		from := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		to := from.AddDate(0, 0, 1)
+		filter.DueFrom, filter.DueTo = &from, &to
+	case DueWithin:
+		if q.DueWithinDays <= 0 {
+			return filter, api.Validationf("due within days must be positive")
+		}
+		to := now.AddDate(0, 0, q.DueWithinDays)
+		filter.DueFrom, filter.DueTo = &now, &to
+	default:
+		return filter, api.Validationf("unknown due filter %q", q.Due)
+	}
+
+	if err := q.applyConditions(&filter); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// intersect returns the values of filter that are in allowed, allowed when filter is nil.
+func intersect(filter, allowed []string) []string {
+	if filter == nil {
+		return allowed
+	}
+	statuses := []string{}
+	for _, status := range filter {
+		for _, a := range allowed {
+			if status == a {
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	return statuses
+}
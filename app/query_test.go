@@ -0,0 +1,94 @@
+package app
+
+import (
+	"testing"
+	"time"
+	"to-do/api"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToDoQueryFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2021, 11, 5, 22, 30, 0, 0, time.UTC)
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	assert.NoError(err)
+
+	tt := []struct {
+		name    string
+		query   ToDoQuery
+		from    *time.Time
+		to      *time.Time
+		errKind error
+	}{
+		{
+			name:  "No filter",
+			query: ToDoQuery{},
+		},
+		{
+			name:  "Overdue",
+			query: ToDoQuery{Due: DueOverdue},
+			to:    &now,
+		},
+		{
+			name:  "Today in UTC",
+			query: ToDoQuery{Due: DueToday},
+			from:  timePtr(time.Date(2021, 11, 5, 0, 0, 0, 0, time.UTC)),
+			to:    timePtr(time.Date(2021, 11, 6, 0, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:  "Today in another timezone",
+			query: ToDoQuery{Due: DueToday, Location: kyiv},
+			from:  timePtr(time.Date(2021, 11, 6, 0, 0, 0, 0, kyiv)),
+			to:    timePtr(time.Date(2021, 11, 7, 0, 0, 0, 0, kyiv)),
+		},
+		{
+			name:  "Within 3 days",
+			query: ToDoQuery{Due: DueWithin, DueWithinDays: 3},
+			from:  &now,
+			to:    timePtr(now.AddDate(0, 0, 3)),
+		},
+		{
+			name:    "Within 0 days",
+			query:   ToDoQuery{Due: DueWithin},
+			errKind: api.ErrValidation,
+		},
+		{
+			name:    "Unknown filter",
+			query:   ToDoQuery{Due: "tomorrow"},
+			errKind: api.ErrValidation,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := tc.query.filter(now)
+			if tc.errKind != nil {
+				assert.True(errors.Is(err, tc.errKind), tc.name)
+				return
+			}
+			assert.NoError(err, tc.name)
+			assert.True(equalTimes(tc.from, filter.DueFrom), tc.name)
+			assert.True(equalTimes(tc.to, filter.DueTo), tc.name)
+		})
+	}
+}
+
+func TestValidateToDoDueDates(t *testing.T) {
+	assert := assert.New(t)
+
+	createdAt := time.Date(2021, 11, 5, 10, 0, 0, 0, time.UTC)
+	before := createdAt.Add(-time.Hour)
+	after := createdAt.Add(time.Hour)
+
+	assert.NoError(validateToDo(api.ToDo{Message: "todo", Priority: api.PriorityNormal, DueAt: &after, ReminderAt: &createdAt}, createdAt))
+	assert.NoError(validateToDo(api.ToDo{Message: "todo", Priority: api.PriorityNormal, ReminderAt: &after}, createdAt))
+	assert.Error(validateToDo(api.ToDo{Message: "todo", Priority: api.PriorityNormal, DueAt: &before}, createdAt))
+	assert.Error(validateToDo(api.ToDo{Message: "todo", Priority: api.PriorityNormal, DueAt: &createdAt, ReminderAt: &after}, createdAt))
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"time"
+	"to-do/api"
+	"to-do/repository"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/teambition/rrule-go"
+)
+
+// parseRecurrence parses an RRULE anchored at start. The anchor comes from the todo,
+// so rules with DTSTART are rejected, and so are rules more frequent than daily.
+func parseRecurrence(rule string, start time.Time) (*rrule.RRule, error) {
+	option, err := rrule.StrToROption(rule)
+	if err != nil {
+		return nil, api.Validationf("invalid recurrence %q: %s", rule, err)
+	}
+	if !option.Dtstart.IsZero() {
+		return nil, api.Validationf("recurrence cant have DTSTART, it starts at due_at")
+	}
+	switch option.Freq {
+	case rrule.DAILY, rrule.WEEKLY, rrule.MONTHLY, rrule.YEARLY:
+	default:
+		return nil, api.Validationf("recurrence can repeat at most daily")
+	}
+	option.Dtstart = start
+	recurrence, err := rrule.NewRRule(*option)
+	if err != nil {
+		return nil, api.Validationf("invalid recurrence %q: %s", rule, err)
+	}
+	return recurrence, nil
+}
+
+// validateRecurrence checks the recurrence of a todo that starts a series.
+func validateRecurrence(todo api.ToDo) error {
+	if todo.Recurrence == "" {
+		return nil
+	}
+	if todo.DueAt == nil {
+		return api.Validationf("recurring todo needs due_at")
+	}
+	_, err := parseRecurrence(todo.Recurrence, *todo.DueAt)
+	return err
+}
+
+// nextOccurrence returns the due time of the occurrence after todo, nil when the series has ended.
+func nextOccurrence(todo api.ToDo) (*time.Time, error) {
+	if todo.Recurrence == "" || todo.DueAt == nil || todo.SeriesStart == nil {
+		return nil, nil
+	}
+	recurrence, err := parseRecurrence(todo.Recurrence, *todo.SeriesStart)
+	if err != nil {
+		return nil, err
+	}
+	next := recurrence.After(*todo.DueAt, false)
+	if next.IsZero() {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+// scheduleNext creates the occurrence that follows the completed todo, unless the series
+// has ended or the next occurrence exists already because the todo was completed before.
+func (t *ToDoService) scheduleNext(ctx context.Context, todo api.ToDo) error {
+	next, err := nextOccurrence(todo)
+	if err != nil || next == nil {
+		return err
+	}
+
+	later := todo.DueAt.Add(time.Microsecond)
+	count, err := t.db.CountToDos(ctx, todo.UserID, repository.ToDoFilter{SeriesID: todo.SeriesID, DueFrom: &later})
+	if err != nil {
+		log.Error("cant count occurrences: ", err)
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	occurrence := api.ToDo{
+		UserID:      todo.UserID,
+		Message:     todo.Message,
+		DueAt:       next,
+		Priority:    todo.Priority,
+		ListID:      todo.ListID,
+		ParentID:    todo.ParentID,
+		Recurrence:  todo.Recurrence,
+		SeriesID:    todo.SeriesID,
+		SeriesStart: todo.SeriesStart,
+	}
+	if todo.ReminderAt != nil {
+		reminder := next.Add(todo.ReminderAt.Sub(*todo.DueAt))
+		occurrence.ReminderAt = &reminder
+	}
+	last, err := t.db.LastPosition(ctx, todo.UserID)
+	if err != nil {
+		log.Error("cant find last todo position: ", err)
+		return err
+	}
+	if occurrence.Position, err = positionBetween(last, ""); err != nil {
+		return err
+	}
+
+	created, err := t.db.CreateToDo(ctx, occurrence)
+	if err != nil {
+		log.Error("cant create next occurrence: ", err)
+		return err
+	}
+	if len(todo.Tags) > 0 {
+		if _, err := t.db.AddToDoTags(ctx, created.ID, todo.Tags); err != nil {
+			log.Error("cant tag next occurrence: ", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateSeries applies update to every open occurrence of the todo's series and returns the todo.
+// A todo that does not recur yet starts a series at its due_at when update sets a recurrence.
+func (t *ToDoService) UpdateSeries(ctx context.Context, todoID int64, update api.SeriesUpdate) (*api.ToDo, error) {
+	return t.inTx(ctx, func(t *ToDoService) (*api.ToDo, error) { return t.updateSeries(ctx, todoID, update) })
+}
+
+func (t *ToDoService) updateSeries(ctx context.Context, todoID int64, update api.SeriesUpdate) (*api.ToDo, error) {
+	current, err := t.GetTodo(ctx, todoID)
+	if err != nil {
+		return nil, err
+	}
+	if update.Message != nil || update.Priority != nil {
+		changed := *current
+		if update.Message != nil {
+			changed.Message = *update.Message
+		}
+		if update.Priority != nil {
+			changed.Priority = *update.Priority
+		}
+		if err := validateToDo(changed, current.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	todoUpdate := api.ToDoUpdate{Message: update.Message, Priority: update.Priority, Recurrence: update.Recurrence}
+	if current.Recurrence == "" {
+		if update.Recurrence == nil || *update.Recurrence == "" {
+			return nil, api.Validationf("todo %d does not recur", todoID)
+		}
+		// The todo becomes the first occurrence of a new series.
+		starting := *current
+		starting.Recurrence = *update.Recurrence
+		if err := validateRecurrence(starting); err != nil {
+			return nil, err
+		}
+		todoUpdate.SeriesStart = &api.TimeUpdate{Time: current.DueAt}
+		todo, err := t.db.PatchToDo(ctx, todoID, todoUpdate, api.Precondition{})
+		if err != nil {
+			log.Error("cant start series: ", err)
+			return nil, err
+		}
+		return todo, nil
+	}
+
+	if update.Recurrence != nil && *update.Recurrence != "" {
+		if _, err := parseRecurrence(*update.Recurrence, *current.SeriesStart); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := t.db.UpdateSeries(ctx, *current.SeriesID, todoUpdate); err != nil {
+		log.Error("cant update series: ", err)
+		return nil, err
+	}
+	return t.GetTodo(ctx, todoID)
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"testing"
+	"time"
+	"to-do/api"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextOccurrence(t *testing.T) {
+	assert := assert.New(t)
+
+	// Friday, 5 November 2021.
+	start := time.Date(2021, 11, 5, 9, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name       string
+		recurrence string
+		due        time.Time
+		next       *time.Time
+	}{
+		{
+			name:       "Daily",
+			recurrence: "FREQ=DAILY",
+			due:        start,
+			next:       timePtr(start.AddDate(0, 0, 1)),
+		},
+		{
+			name:       "Weekly on Monday",
+			recurrence: "FREQ=WEEKLY;BYDAY=MO",
+			due:        start,
+			next:       timePtr(time.Date(2021, 11, 8, 9, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:       "Monthly from a later occurrence",
+			recurrence: "FREQ=MONTHLY",
+			due:        start.AddDate(0, 2, 0),
+			next:       timePtr(start.AddDate(0, 3, 0)),
+		},
+		{
+			name:       "Count is anchored at the series start",
+			recurrence: "FREQ=DAILY;COUNT=3",
+			due:        start.AddDate(0, 0, 2),
+		},
+		{
+			name:       "Until",
+			recurrence: "FREQ=WEEKLY;UNTIL=20211110T000000Z",
+			due:        start,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			next, err := nextOccurrence(api.ToDo{Recurrence: tc.recurrence, DueAt: &tc.due, SeriesStart: &start})
+			assert.NoError(err, tc.name)
+			assert.True(equalTimes(tc.next, next), "%s: %v", tc.name, next)
+		})
+	}
+}
+
+func TestValidateRecurrence(t *testing.T) {
+	assert := assert.New(t)
+
+	due := time.Date(2021, 11, 5, 9, 0, 0, 0, time.UTC)
+	assert.NoError(validateRecurrence(api.ToDo{}))
+	assert.NoError(validateRecurrence(api.ToDo{Recurrence: "FREQ=MONTHLY;BYMONTHDAY=1", DueAt: &due}))
+
+	for _, todo := range []api.ToDo{
+		{Recurrence: "FREQ=DAILY"},
+		{Recurrence: "FREQ=HOURLY", DueAt: &due},
+		{Recurrence: "every monday", DueAt: &due},
+		{Recurrence: "DTSTART:20211105T090000Z\nRRULE:FREQ=DAILY", DueAt: &due},
+	} {
+		err := validateRecurrence(todo)
+		assert.True(errors.Is(err, api.ErrValidation), todo.Recurrence)
+	}
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"to-do/api"
+	"to-do/repository"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Limits of a search query.
+const (
+	maxSearchLength = 200
+	maxSearchTerms  = 20
+)
+
+// parseSearch splits a search query into terms. Text in double quotes is a phrase,
+// a word ending with * matches the words that start with it, other words match
+// their own forms ("tasks" matches "task").
+func parseSearch(q string) ([]repository.SearchTerm, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil, api.Validationf("search query cant be empty")
+	}
+	if len([]rune(q)) > maxSearchLength {
+		return nil, api.Validationf("search query cant be longer than %d characters", maxSearchLength)
+	}
+
+	terms := []repository.SearchTerm{}
+	for rest := q; rest != ""; rest = strings.TrimSpace(rest) {
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, api.Validationf("search query has an unterminated phrase")
+			}
+			if phrase := strings.TrimSpace(rest[1 : end+1]); phrase != "" {
+				terms = append(terms, repository.SearchTerm{Text: phrase, Phrase: true})
+			}
+			rest = rest[end+2:]
+			continue
+		}
+
+		end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		if end < 0 {
+			end = len(rest)
+		}
+		word := rest[:end]
+		rest = rest[end:]
+
+		if strings.HasSuffix(word, "*") {
+			prefix := strings.TrimSuffix(word, "*")
+			if prefix == "" || strings.IndexFunc(prefix, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) >= 0 {
+				return nil, api.Validationf("prefix %q can only contain letters and digits", word)
+			}
+			terms = append(terms, repository.SearchTerm{Text: prefix, Prefix: true})
+			continue
+		}
+		terms = append(terms, repository.SearchTerm{Text: word})
+	}
+
+	if len(terms) == 0 {
+		return nil, api.Validationf("search query cant be empty")
+	}
+	if len(terms) > maxSearchTerms {
+		return nil, api.Validationf("search query cant have more than %d terms", maxSearchTerms)
+	}
+	return terms, nil
+}
+
+// Search returns a page of the authenticated user's todos whose message matches q, see parseSearch
+// for the query syntax. Limit is clamped like in ListToDos.
+func (t *ToDoService) Search(ctx context.Context, q string, limit, offset int64) (*api.SearchPage, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	terms, err := parseSearch(q)
+	if err != nil {
+		return nil, err
+	}
+	limit = pageLimit(limit)
+	if offset < 0 {
+		offset = 0
+	}
+
+	results, total, err := t.db.SearchToDos(ctx, user.ID, terms, limit, offset)
+	if err != nil {
+		log.Error("cant search todos: ", err)
+		return nil, err
+	}
+
+	return &api.SearchPage{
+		Results: results,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	}, nil
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"to-do/api"
+	"to-do/repository"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSearch(t *testing.T) {
+	assert := assert.New(t)
+
+	terms, err := parseSearch(` buy "fresh  bread" bake* `)
+	assert.NoError(err)
+	assert.Equal([]repository.SearchTerm{
+		{Text: "buy"},
+		{Text: "fresh  bread", Phrase: true},
+		{Text: "bake", Prefix: true},
+	}, terms)
+
+	terms, err = parseSearch(`milk"eggs"`)
+	assert.NoError(err)
+	assert.Equal([]repository.SearchTerm{{Text: "milk"}, {Text: "eggs", Phrase: true}}, terms)
+
+	for _, q := range []string{
+		"",
+		`""`,
+		`"unterminated`,
+		"*",
+		"a-b*",
+		strings.Repeat("x", maxSearchLength+1),
+		strings.Repeat("x ", maxSearchTerms+1),
+	} {
+		_, err := parseSearch(q)
+		assert.True(errors.Is(err, api.ErrValidation), q)
+	}
+}
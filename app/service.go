@@ -2,53 +2,352 @@ package app
 
 import (
 	"context"
+	"time"
 	"to-do/api"
 	"to-do/repository"
 
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+type ServiceConfig struct {
+	// CursorSecret signs pagination cursors. Random per process when empty.
+	CursorSecret string
+}
+
 type ToDoService struct {
-	db repository.Storage
+	db      repository.Storage
+	cursors *cursorCodec
+	now     func() time.Time
+}
+
+func NewToDoService(db repository.Storage, cfg ServiceConfig) (*ToDoService, error) {
+	cursors, err := newCursorCodec(cfg.CursorSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &ToDoService{db: db, cursors: cursors, now: time.Now}, nil
+}
+
+// withStorage returns a copy of the service that uses db, like a storage bound to a transaction.
+func (t *ToDoService) withStorage(db repository.Storage) *ToDoService {
+	service := *t
+	service.db = db
+	return &service
+}
+
+// inTx runs fn with a copy of the service bound to a transaction, so the checks of fn
+// and its changes are atomic. fn can run several times, see repository.TxStorage.
+func (t *ToDoService) inTx(ctx context.Context, fn func(t *ToDoService) (*api.ToDo, error)) (*api.ToDo, error) {
+	var todo *api.ToDo
+	err := t.db.WithTx(ctx, func(db repository.Storage) error {
+		var err error
+		todo, err = fn(t.withStorage(db))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
 }
 
-func NewToDoService(db repository.Storage) (*ToDoService, error) {
-	return &ToDoService{db: db}, nil
+// CreateToDo creates a todo owned by the authenticated user at the end of the user's list,
+// user_id, position and the series fields of the todo are ignored.
+func (t *ToDoService) CreateToDo(ctx context.Context, todo api.ToDo) (*api.ToDo, error) {
+	return t.inTx(ctx, func(t *ToDoService) (*api.ToDo, error) { return t.createToDo(ctx, todo) })
 }
 
-func (t *ToDoService) CreateToDo(ctx context.Context, todo api.ToDo) error {
-	err := t.db.CreateToDo(ctx, todo)
+func (t *ToDoService) createToDo(ctx context.Context, todo api.ToDo) (*api.ToDo, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	todo.UserID = user.ID
+	if todo.Priority == "" {
+		todo.Priority = api.PriorityNormal
+	}
+	if err := validateToDo(todo, t.now()); err != nil {
+		return nil, err
+	}
+	if err := checkListTarget(ctx, t.db, todo.ListID); err != nil {
+		return nil, err
+	}
+	if err := t.checkParent(ctx, 0, todo.ParentID); err != nil {
+		return nil, err
+	}
+	if err := validateRecurrence(todo); err != nil {
+		return nil, err
+	}
+	// A recurring todo starts a new series.
+	todo.SeriesID, todo.SeriesStart = nil, nil
+	if todo.Recurrence != "" {
+		todo.SeriesStart = todo.DueAt
+	}
+
+	last, err := t.db.LastPosition(ctx, user.ID)
+	if err != nil {
+		log.Error("cant find last todo position: ", err)
+		return nil, err
+	}
+	todo.Position, err = positionBetween(last, "")
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := t.db.CreateToDo(ctx, todo)
 	if err != nil {
 		log.Error("cant create new todo: ", err)
-		return err
+		return nil, err
 	}
-	return nil
+	return created, nil
+}
+
+// UpdateToDo replaces the todo with the given one if cond holds. Read-only fields of todo are ignored.
+func (t *ToDoService) UpdateToDo(ctx context.Context, todo api.ToDo, cond api.Precondition) (*api.ToDo, error) {
+	return t.inTx(ctx, func(t *ToDoService) (*api.ToDo, error) { return t.updateToDo(ctx, todo, cond) })
 }
 
-func (t *ToDoService) UpdateToDo(ctx context.Context, todo api.ToDo) error {
-	err := t.db.UpdateToDo(ctx, todo)
+func (t *ToDoService) updateToDo(ctx context.Context, todo api.ToDo, cond api.Precondition) (*api.ToDo, error) {
+	current, err := t.GetTodo(ctx, todo.ID)
+	if err != nil {
+		return nil, err
+	}
+	if todo.Priority == "" {
+		todo.Priority = api.PriorityNormal
+	}
+	if err := validateToDo(todo, current.CreatedAt); err != nil {
+		return nil, err
+	}
+	if !equalIDs(todo.ListID, current.ListID) {
+		if err := checkListTarget(ctx, t.db, todo.ListID); err != nil {
+			return nil, err
+		}
+	}
+	if !equalIDs(todo.ParentID, current.ParentID) {
+		if err := t.checkParent(ctx, todo.ID, todo.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := t.db.UpdateToDo(ctx, todo, cond)
 	if err != nil {
 		log.Error("cant update todo: ", err)
-		return err
+		return nil, err
 	}
-	return nil
+	return updated, nil
 }
 
 func (t *ToDoService) GetTodo(ctx context.Context, todoID int64) (*api.ToDo, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	todo, err := t.db.GetToDo(ctx, todoID)
-	// TODO: handle db error NotFound
 	if err != nil {
 		log.Error("cant return todo: ", err)
 		return nil, err
 	}
+	if todo.UserID != user.ID {
+		return nil, forbiddenToDo(todoID)
+	}
 	return todo, nil
 }
 
-func (t *ToDoService) DeleteTodo(ctx context.Context, todoID int64) error {
-	err := t.db.DeleteToDo(ctx, todoID)
+func (t *ToDoService) DeleteTodo(ctx context.Context, todoID int64, cond api.Precondition) error {
+	if err := t.checkOwner(ctx, todoID); err != nil {
+		return err
+	}
+
+	err := t.db.DeleteToDo(ctx, todoID, cond)
 	if err != nil {
 		log.Error("cant delete todo: ", err)
 		return err
 	}
 	return nil
 }
+
+// RestoreToDo moves the authenticated user's todo out of the trash.
+func (t *ToDoService) RestoreToDo(ctx context.Context, todoID int64) (*api.ToDo, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	todo, err := t.db.RestoreToDo(ctx, user.ID, todoID)
+	if err != nil {
+		log.Error("cant restore todo: ", err)
+		return nil, err
+	}
+	return todo, nil
+}
+
+// ListToDos returns a page of the user's todos ordered by creation time.
+// Limit is clamped to MaxPageLimit, zero or negative values fall back to DefaultPageLimit.
+func (t *ToDoService) ListToDos(ctx context.Context, userID int64, query ToDoQuery, limit, offset int64) (*api.ToDoPage, error) {
+	if err := checkSelf(ctx, userID); err != nil {
+		return nil, err
+	}
+	filter, err := query.filter(t.now())
+	if err != nil {
+		return nil, err
+	}
+	limit = pageLimit(limit)
+	if offset < 0 {
+		offset = 0
+	}
+
+	todos, err := t.db.GetToDos(ctx, userID, filter, limit, offset)
+	if err != nil {
+		log.Error("cant list todos: ", err)
+		return nil, err
+	}
+
+	total, err := t.db.CountToDos(ctx, userID, filter)
+	if err != nil {
+		log.Error("cant count todos: ", err)
+		return nil, err
+	}
+
+	return &api.ToDoPage{
+		ToDos:  todos,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}, nil
+}
+
+// ListToDosAfter returns the page of the user's todos that follows cursor,
+// or the first page when cursor is empty. Unlike ListToDos it pages by key,
+// so pages stay stable while todos are inserted or deleted.
+func (t *ToDoService) ListToDosAfter(ctx context.Context, userID int64, query ToDoQuery, limit int64, cursor string) (*api.ToDoPage, error) {
+	if err := checkSelf(ctx, userID); err != nil {
+		return nil, err
+	}
+	filter, err := query.filter(t.now())
+	if err != nil {
+		return nil, err
+	}
+	limit = pageLimit(limit)
+
+	var after *repository.ToDoKey
+	if cursor != "" {
+		key, err := t.cursors.decode(userID, cursorOrder(filter), cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = key
+	}
+
+	// One extra row tells if there is a next page.
+	todos, err := t.db.GetToDosAfter(ctx, userID, filter, after, limit+1)
+	if err != nil {
+		log.Error("cant list todos: ", err)
+		return nil, err
+	}
+
+	total, err := t.db.CountToDos(ctx, userID, filter)
+	if err != nil {
+		log.Error("cant count todos: ", err)
+		return nil, err
+	}
+
+	page := &api.ToDoPage{
+		ToDos: todos,
+		Total: total,
+		Limit: limit,
+	}
+	if int64(len(todos)) > limit {
+		page.ToDos = todos[:limit]
+		last := page.ToDos[limit-1]
+		page.NextCursor, err = t.cursors.encode(userID, cursorOrder(filter), repository.ToDoKey{
+			CreatedAt:   last.CreatedAt,
+			ID:          last.ID,
+			Position:    last.Position,
+			Priority:    last.Priority,
+			UpdatedAt:   last.UpdatedAt,
+			DueAt:       last.DueAt,
+			CompletedAt: last.CompletedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// maxMessageLength matches todo_app.todo_list.message.
+const maxMessageLength = 40000
+
+// validateToDo checks the mutable fields of a todo created at createdAt.
+func validateToDo(todo api.ToDo, createdAt time.Time) error {
+	if len(todo.Message) == 0 {
+		return api.Validationf("message cant be empty")
+	}
+	if len([]rune(todo.Message)) > maxMessageLength {
+		return api.Validationf("message cant be longer than %d characters", maxMessageLength)
+	}
+	if todo.DueAt != nil && todo.DueAt.Before(createdAt) {
+		return api.Validationf("due_at cant be before the todo is created")
+	}
+	if todo.ReminderAt != nil && todo.DueAt != nil && todo.ReminderAt.After(*todo.DueAt) {
+		return api.Validationf("reminder_at cant be after due_at")
+	}
+	switch todo.Priority {
+	case api.PriorityLow, api.PriorityNormal, api.PriorityHigh, api.PriorityUrgent:
+	default:
+		return api.Validationf("unknown priority %q", todo.Priority)
+	}
+	return nil
+}
+
+func checkPrecondition(cond api.Precondition, todo *api.ToDo) error {
+	matches := func(versions []int64) bool {
+		for _, v := range versions {
+			if v == api.AnyVersion || v == todo.Version {
+				return true
+			}
+		}
+		return false
+	}
+	if cond.IfMatch != nil && !matches(cond.IfMatch) || cond.IfNoneMatch != nil && matches(cond.IfNoneMatch) {
+		return api.NewError(api.ErrPreconditionFailed, "todo %d has version %d", todo.ID, todo.Version)
+	}
+	return nil
+}
+
+// checkOwner allows access to the todo only for the authenticated user who owns it.
+func (t *ToDoService) checkOwner(ctx context.Context, todoID int64) error {
+	_, err := t.GetTodo(ctx, todoID)
+	return err
+}
+
+func forbiddenToDo(todoID int64) error {
+	return api.NewError(api.ErrForbidden, "todo %d belongs to another user", todoID)
+}
+
+// checkSelf allows access to a user's data only for that user.
+func checkSelf(ctx context.Context, userID int64) error {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	if user.ID != userID {
+		return api.NewError(api.ErrForbidden, "user %d can not access data of user %d", user.ID, userID)
+	}
+	return nil
+}
+
+func pageLimit(limit int64) int64 {
+	if limit <= 0 {
+		return DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return limit
+}
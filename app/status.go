@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"to-do/api"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// transitions lists the statuses a todo can move to from each status.
+var transitions = map[string][]string{
+	api.StatusOpen:       {api.StatusInProgress, api.StatusDone, api.StatusCancelled},
+	api.StatusInProgress: {api.StatusOpen, api.StatusDone, api.StatusCancelled},
+	api.StatusDone:       {api.StatusOpen},
+	api.StatusCancelled:  {api.StatusOpen},
+}
+
+// activeStatuses are the statuses of todos that still have to be done.
+var activeStatuses = []string{api.StatusOpen, api.StatusInProgress}
+
+func canTransition(from, to string) bool {
+	for _, status := range transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionToDo moves the todo to the status to. Illegal transitions fail with api.ErrConflict.
+// Completing a recurring todo creates its next occurrence.
+func (t *ToDoService) TransitionToDo(ctx context.Context, todoID int64, to string, cond api.Precondition) (*api.ToDo, error) {
+	return t.inTx(ctx, func(t *ToDoService) (*api.ToDo, error) { return t.transitionToDo(ctx, todoID, to, cond) })
+}
+
+func (t *ToDoService) transitionToDo(ctx context.Context, todoID int64, to string, cond api.Precondition) (*api.ToDo, error) {
+	if _, ok := transitions[to]; !ok {
+		return nil, api.Validationf("unknown status %q", to)
+	}
+	current, err := t.GetTodo(ctx, todoID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPrecondition(cond, current); err != nil {
+		return nil, err
+	}
+	if !canTransition(current.Status, to) {
+		return nil, api.Conflictf("todo %d cant move from %s to %s", todoID, current.Status, to)
+	}
+
+	update := api.ToDoUpdate{
+		Status:      &to,
+		CompletedAt: &api.TimeUpdate{},
+	}
+	if to == api.StatusDone {
+		now := t.now()
+		update.CompletedAt.Time = &now
+	}
+
+	// The transition was checked against the current version,
+	// it must not be applied to any other.
+	clientCond := cond
+	if cond.IfMatch == nil {
+		cond.IfMatch = []int64{current.Version}
+	}
+	todo, err := t.db.PatchToDo(ctx, todoID, update, cond)
+	if errors.Is(err, api.ErrPreconditionFailed) && clientCond.IfMatch == nil {
+		return nil, api.Conflictf("todo %d was changed concurrently", todoID)
+	}
+	if err != nil {
+		log.Error("cant change todo status: ", err)
+		return nil, err
+	}
+	if to == api.StatusDone {
+		if err := t.scheduleNext(ctx, *todo); err != nil {
+			return nil, err
+		}
+	}
+	return todo, nil
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"testing"
+	"time"
+	"to-do/api"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	assert := assert.New(t)
+
+	tt := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{from: api.StatusOpen, to: api.StatusInProgress, allowed: true},
+		{from: api.StatusOpen, to: api.StatusDone, allowed: true},
+		{from: api.StatusInProgress, to: api.StatusCancelled, allowed: true},
+		{from: api.StatusDone, to: api.StatusOpen, allowed: true},
+		{from: api.StatusCancelled, to: api.StatusOpen, allowed: true},
+		{from: api.StatusOpen, to: api.StatusOpen, allowed: false},
+		{from: api.StatusDone, to: api.StatusDone, allowed: false},
+		{from: api.StatusDone, to: api.StatusInProgress, allowed: false},
+		{from: api.StatusCancelled, to: api.StatusDone, allowed: false},
+	}
+
+	for _, tc := range tt {
+		assert.Equal(tc.allowed, canTransition(tc.from, tc.to), "%s -> %s", tc.from, tc.to)
+	}
+}
+
+func TestOverdueSkipsFinishedTodos(t *testing.T) {
+	assert := assert.New(t)
+
+	filter, err := ToDoQuery{Due: DueOverdue}.filter(time.Now())
+	assert.NoError(err)
+	assert.Equal(activeStatuses, filter.Statuses)
+
+	filter, err = ToDoQuery{Due: DueOverdue, Statuses: []string{api.StatusDone, api.StatusOpen}}.filter(time.Now())
+	assert.NoError(err)
+	assert.Equal([]string{api.StatusOpen}, filter.Statuses)
+
+	_, err = ToDoQuery{Statuses: []string{"archived"}}.filter(time.Now())
+	assert.Error(err)
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"to-do/api"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// checkParent allows the todo to become a subtask of parentID only if the parent is a live todo
+// of the same user and the todo is not an ancestor of the parent. A zero todoID is a new todo.
+func (t *ToDoService) checkParent(ctx context.Context, todoID int64, parentID *int64) error {
+	if parentID == nil {
+		return nil
+	}
+	if *parentID == todoID {
+		return api.Validationf("todo %d cant be its own subtask", todoID)
+	}
+	if _, err := t.GetTodo(ctx, *parentID); err != nil {
+		return err
+	}
+	if todoID == 0 {
+		return nil
+	}
+
+	ancestors, err := t.db.GetAncestorIDs(ctx, *parentID)
+	if err != nil {
+		log.Error("cant return todo ancestors: ", err)
+		return err
+	}
+	for _, id := range ancestors {
+		if id == todoID {
+			return api.Validationf("todo %d cant be a subtask of its own subtask %d", todoID, *parentID)
+		}
+	}
+	return nil
+}
+
+// CreateSubtask creates todo as a subtask of the parent todo.
+func (t *ToDoService) CreateSubtask(ctx context.Context, parentID int64, todo api.ToDo) (*api.ToDo, error) {
+	todo.ParentID = &parentID
+	return t.CreateToDo(ctx, todo)
+}
+
+// ListSubtasks returns the direct subtasks of the todo ordered by position.
+func (t *ToDoService) ListSubtasks(ctx context.Context, parentID int64) ([]api.ToDo, error) {
+	if err := t.checkOwner(ctx, parentID); err != nil {
+		return nil, err
+	}
+
+	subtasks, err := t.db.GetSubtasks(ctx, parentID)
+	if err != nil {
+		log.Error("cant list subtasks: ", err)
+		return nil, err
+	}
+	return subtasks, nil
+}
+
+// ToggleToDo reopens a done todo and completes any other, it checks off checklist items.
+// The status is read in the transaction of the transition, so concurrent toggles cancel out.
+func (t *ToDoService) ToggleToDo(ctx context.Context, todoID int64, cond api.Precondition) (*api.ToDo, error) {
+	return t.inTx(ctx, func(t *ToDoService) (*api.ToDo, error) {
+		current, err := t.GetTodo(ctx, todoID)
+		if err != nil {
+			return nil, err
+		}
+		to := api.StatusDone
+		if current.Status == api.StatusDone {
+			to = api.StatusOpen
+		}
+		return t.transitionToDo(ctx, todoID, to, cond)
+	})
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"to-do/api"
+	"to-do/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// racingStorage holds every read of a todo outside of a transaction until another one ran,
+// like concurrent requests that read at the same time.
+type racingStorage struct {
+	repository.Storage
+	mu      sync.Mutex
+	waiting chan struct{}
+}
+
+func (r *racingStorage) GetToDo(ctx context.Context, todoID int64) (*api.ToDo, error) {
+	todo, err := r.Storage.GetToDo(ctx, todoID)
+	r.mu.Lock()
+	if r.waiting != nil {
+		close(r.waiting)
+		r.waiting = nil
+		r.mu.Unlock()
+		return todo, err
+	}
+	waiting := make(chan struct{})
+	r.waiting = waiting
+	r.mu.Unlock()
+	select {
+	case <-waiting:
+	case <-time.After(100 * time.Millisecond):
+		r.mu.Lock()
+		if r.waiting == waiting {
+			r.waiting = nil
+		}
+		r.mu.Unlock()
+	}
+	return todo, err
+}
+
+func TestConcurrentToggles(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := repository.NewDBClient(context.Background(), repository.StorageConfig{Driver: repository.DriverMemory})
+	assert.NoError(err)
+	user, err := db.CreateUser(context.Background(), api.User{Name: "Sam"})
+	assert.NoError(err)
+	ctx := WithUser(context.Background(), user)
+	service, err := NewToDoService(db, ServiceConfig{})
+	assert.NoError(err)
+	todo, err := service.CreateToDo(ctx, api.ToDo{Message: "Carry the pans"})
+	assert.NoError(err)
+
+	// Two toggles that run at the same time cancel out.
+	service, err = NewToDoService(&racingStorage{Storage: db}, ServiceConfig{})
+	assert.NoError(err)
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = service.ToggleToDo(ctx, todo.ID, api.Precondition{})
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal([]error{nil, nil}, errs)
+	toggled, err := db.GetToDo(ctx, todo.ID)
+	assert.NoError(err)
+	assert.Equal(api.StatusOpen, toggled.Status)
+	assert.Equal(todo.Version+2, toggled.Version)
+}
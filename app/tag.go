@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"to-do/api"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxTagLength matches todo_app.tags.name.
+const maxTagLength = 50
+
+// normalizeTags trims the tags, drops duplicates and sorts them.
+func normalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, api.Validationf("tag cant be empty")
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, api.Validationf("tag cant be longer than %d characters", maxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// AddTags labels the todo with tags, the todo keeps the tags it already has.
+func (t *ToDoService) AddTags(ctx context.Context, todoID int64, tags []string) (*api.ToDo, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, api.Validationf("no tags to add")
+	}
+	if err := t.checkOwner(ctx, todoID); err != nil {
+		return nil, err
+	}
+
+	todo, err := t.db.AddToDoTags(ctx, todoID, tags)
+	if err != nil {
+		log.Error("cant add todo tags: ", err)
+		return nil, err
+	}
+	return todo, nil
+}
+
+func (t *ToDoService) RemoveTag(ctx context.Context, todoID int64, tag string) (*api.ToDo, error) {
+	if err := t.checkOwner(ctx, todoID); err != nil {
+		return nil, err
+	}
+
+	todo, err := t.db.RemoveToDoTag(ctx, todoID, strings.TrimSpace(tag))
+	if err != nil {
+		log.Error("cant remove todo tag: ", err)
+		return nil, err
+	}
+	return todo, nil
+}
+
+// ListTags returns the tags of the authenticated user with their usage counts.
+func (t *ToDoService) ListTags(ctx context.Context) ([]api.Tag, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := t.db.GetTags(ctx, user.ID)
+	if err != nil {
+		log.Error("cant list tags: ", err)
+		return nil, err
+	}
+	return tags, nil
+}
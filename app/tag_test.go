@@ -0,0 +1,24 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"to-do/api"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	assert := assert.New(t)
+
+	tags, err := normalizeTags([]string{" work", "home", "work ", "errands"})
+	assert.NoError(err)
+	assert.Equal([]string{"errands", "home", "work"}, tags)
+
+	_, err = normalizeTags([]string{"home", " "})
+	assert.True(errors.Is(err, api.ErrValidation))
+
+	_, err = normalizeTags([]string{strings.Repeat("x", maxTagLength+1)})
+	assert.True(errors.Is(err, api.ErrValidation))
+}
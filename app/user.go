@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"to-do/api"
+	"to-do/repository"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxUserNameLength matches todo_app.users.username.
+const maxUserNameLength = 50
+
+func validateUserName(name string) error {
+	if len(name) == 0 {
+		return api.Validationf("name cant be empty")
+	}
+	if len([]rune(name)) > maxUserNameLength {
+		return api.Validationf("name cant be longer than %d characters", maxUserNameLength)
+	}
+	return nil
+}
+
+type UserService struct {
+	db repository.Storage
+}
+
+func NewUserService(db repository.Storage) (*UserService, error) {
+	return &UserService{db: db}, nil
+}
+
+// CreateUser registers a new user, it is the only user operation that needs no authentication.
+func (u *UserService) CreateUser(ctx context.Context, user api.User) (*api.User, error) {
+	if err := validateUserName(user.Name); err != nil {
+		return nil, err
+	}
+
+	created, err := u.db.CreateUser(ctx, user)
+	if err != nil {
+		log.Error("cant create new user: ", err)
+		return nil, err
+	}
+	return created, nil
+}
+
+func (u *UserService) GetUser(ctx context.Context, userID int64) (*api.User, error) {
+	if err := checkSelf(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	user, err := u.db.GetUser(ctx, userID)
+	if err != nil {
+		log.Error("cant return user: ", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+// ListUsers pages the users the authenticated user may see, which is only the user itself.
+// There is no admin role, so the names of the other users are not disclosed.
+func (u *UserService) ListUsers(ctx context.Context, limit, offset int64) (*api.UserPage, error) {
+	self, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limit = pageLimit(limit)
+	if offset < 0 {
+		offset = 0
+	}
+
+	user, err := u.db.GetUser(ctx, self.ID)
+	if err != nil {
+		log.Error("cant list users: ", err)
+		return nil, err
+	}
+
+	users := []api.User{}
+	if offset == 0 {
+		users = append(users, *user)
+	}
+	return &api.UserPage{
+		Users:  users,
+		Total:  1,
+		Limit:  limit,
+		Offset: offset,
+	}, nil
+}
+
+func (u *UserService) RenameUser(ctx context.Context, user api.User) error {
+	if err := checkSelf(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := validateUserName(user.Name); err != nil {
+		return err
+	}
+
+	err := u.db.UpdateUser(ctx, user)
+	if err != nil {
+		log.Error("cant rename user: ", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteUser deletes the user and cascades to all of the user's todos.
+func (u *UserService) DeleteUser(ctx context.Context, userID int64) error {
+	if err := checkSelf(ctx, userID); err != nil {
+		return err
+	}
+
+	err := u.db.DeleteUser(ctx, userID)
+	if err != nil {
+		log.Error("cant delete user: ", err)
+		return err
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"time"
+	_ "time/tzdata" // tz of due filters in images without zoneinfo
 	"to-do/app"
 	"to-do/delivery"
 	"to-do/repository"
@@ -25,14 +26,27 @@ const (
 	defaultHost            = "0.0.0.0"
 	defaultLogLevel        = "info"
 	defaultShutdownTimeout = 10 * time.Second
+	defaultTrashRetention  = 30 * 24 * time.Hour
+	defaultPurgeInterval   = time.Hour
+
+	defaultDBMaxOpenConns      = 20
+	defaultDBMaxIdleConns      = 10
+	defaultDBConnMaxLifetime   = 30 * time.Minute
+	defaultDBConnectRetries    = 5
+	defaultDBConnectRetryDelay = time.Second
 )
 
 type AppConfig struct {
-	DB   repository.StorageConfig
-	HTTP delivery.HTTPConfig
+	DB      repository.StorageConfig
+	HTTP    delivery.HTTPConfig
+	Service app.ServiceConfig
+	Auth    app.AuthConfig
+	Purge   app.PurgeConfig
 
 	AppName  string
 	LogLevel string
+	// Command is the subcommand and its arguments, empty to run the service.
+	Command []string
 }
 
 func (cfg *AppConfig) Validate() error {
@@ -40,11 +54,26 @@ func (cfg *AppConfig) Validate() error {
 	if err := cfg.DB.Validate(); err != nil {
 		errs = append(errs, err)
 	}
+	// Subcommands only talk to the database.
+	if len(cfg.Command) > 0 {
+		if len(errs) > 0 {
+			return errors.Errorf("%v", errs)
+		}
+		return nil
+	}
 
 	if err := cfg.HTTP.Validate(); err != nil {
 		errs = append(errs, err)
 	}
 
+	if err := cfg.Auth.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := cfg.Purge.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
 	if len(errs) > 0 {
 		return errors.Errorf("%v", errs)
 	}
@@ -63,15 +92,27 @@ func parseAppCfg() (*AppConfig, error) {
 	flagset.StringVar(&config.HTTP.Host, "host", defaultHost, "Host part of listening address.")
 	flagset.IntVar(&config.HTTP.Port, "port", defaultPort, "Listening port.")
 	flagset.DurationVar(&config.HTTP.ShutdownTimeout, "shutdown-timeout", defaultShutdownTimeout, "Shutdown timeout for http service.")
+	flagset.BoolVar(&config.HTTP.DBStats, "db-stats", false, "Serve the database connection pool statistics on /debug/db, keep it off on public listeners.")
 	//  DB
-	flagset.StringVar(&config.DB.Driver, "db-driver", defaultDBDriver, "Data service driver.")
+	flagset.StringVar(&config.DB.Driver, "db-driver", defaultDBDriver, "Data service driver (postgres, sqlite, memory), the DSN of sqlite is the database file.")
 	flagset.StringVar(&config.DB.DSN, "db-dsn", "", "Data service data source name.")
-
-	logrus.WithField("osenviron", os.Environ()).Info("env")
+	flagset.IntVar(&config.DB.MaxOpenConns, "db-max-open-conns", defaultDBMaxOpenConns, "Maximum open database connections, 0 for no limit.")
+	flagset.IntVar(&config.DB.MaxIdleConns, "db-max-idle-conns", defaultDBMaxIdleConns, "Maximum idle database connections, 0 for the database/sql default.")
+	flagset.DurationVar(&config.DB.ConnMaxLifetime, "db-conn-max-lifetime", defaultDBConnMaxLifetime, "Maximum lifetime of a database connection, 0 to reuse connections forever.")
+	flagset.DurationVar(&config.DB.StatementTimeout, "db-statement-timeout", 0, "Timeout of a postgres statement, 0 to disable.")
+	flagset.IntVar(&config.DB.ConnectRetries, "db-connect-retries", defaultDBConnectRetries, "How many times a failed database connection is retried at startup.")
+	flagset.DurationVar(&config.DB.ConnectRetryDelay, "db-connect-retry-delay", defaultDBConnectRetryDelay, "First delay between database connection retries, it doubles after each retry.")
+	// Service
+	flagset.StringVar(&config.Service.CursorSecret, "cursor-secret", "", "Secret for signing pagination cursors, random per process if empty.")
+	flagset.DurationVar(&config.Purge.Retention, "trash-retention", defaultTrashRetention, "How long deleted todos stay in the trash.")
+	flagset.DurationVar(&config.Purge.Interval, "purge-interval", defaultPurgeInterval, "Time between two purges of the trash.")
+	// Auth
+	flagset.StringVar(&config.Auth.JWTKey, "jwt-key", "", "HMAC key for HS256 signed bearer JWTs.")
 
 	if err := flagset.Parse(os.Args[1:]); err != nil {
 		return nil, errors.Wrap(err, "parsing flags")
 	}
+	config.Command = flagset.Args()
 
 	// Validate the config.
 	if err := config.Validate(); err != nil {
@@ -97,7 +138,8 @@ func initLogging(out io.Writer, level string) error {
 }
 
 func main() {
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	log.Printf("running todo app, current version: %s \n", Version)
 
 	cfg, err := parseAppCfg()
@@ -110,16 +152,45 @@ func main() {
 		logrus.Fatal(err)
 	}
 
+	if len(cfg.Command) > 0 {
+		if err := runCommand(ctx, cfg); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+
 	db, err := repository.NewDBClient(ctx, cfg.DB)
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	service, err := app.NewToDoService(db)
+	service, err := app.NewToDoService(db, cfg.Service)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	userService, err := app.NewUserService(db)
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	httpService := delivery.NewHTTPService(cfg.HTTP, service)
+	authService, err := app.NewAuthService(db, cfg.Auth)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	listService, err := app.NewListService(db)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	healthService, err := app.NewHealthService(db)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	go app.NewPurger(db, cfg.Purge).Run(ctx)
+
+	httpService := delivery.NewHTTPService(cfg.HTTP, service, userService, authService, listService, healthService)
 	httpService.Run()
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"to-do/database"
+	"to-do/repository"
+
+	"github.com/pkg/errors"
+)
+
+const migrateUsage = "usage: migrate up|down|status|to <version>"
+
+// runCommand runs the subcommand of cfg.Command.
+func runCommand(ctx context.Context, cfg *AppConfig) error {
+	switch cfg.Command[0] {
+	case "migrate":
+		return migrate(ctx, cfg.DB, cfg.Command[1:])
+	default:
+		return errors.Errorf("unknown command %q", cfg.Command[0])
+	}
+}
+
+// migrate applies or reverts the embedded migrations of the database package.
+func migrate(ctx context.Context, cfg repository.StorageConfig, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	if cfg.Driver == repository.DriverMemory {
+		return errors.New("the memory storage has no schema to migrate")
+	}
+
+	files := database.Migrations()
+	if cfg.Driver == repository.DriverSQLite {
+		files = database.SQLiteMigrations()
+	}
+	migrations, err := repository.LoadMigrations(files)
+	if err != nil {
+		return err
+	}
+	migrator, err := repository.NewMigrator(ctx, cfg, migrations)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		return migrator.Up(ctx)
+	case args[0] == "down" && len(args) == 1:
+		return migrator.Down(ctx)
+	case args[0] == "to" && len(args) == 2:
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return errors.Errorf("invalid version %q", args[1])
+		}
+		return migrator.To(ctx, version)
+	case args[0] == "status" && len(args) == 1:
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
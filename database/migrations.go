@@ -0,0 +1,32 @@
+// Package database holds the schema migrations of the service.
+package database
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var files embed.FS
+
+// Migrations returns the migration files <version>_<name>.up.sql and <version>_<name>.down.sql.
+func Migrations() fs.FS {
+	migrations, err := fs.Sub(files, "migrations")
+	if err != nil {
+		// fs.Sub fails only for an invalid directory name.
+		panic(err)
+	}
+	return migrations
+}
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+// SQLiteMigrations returns the migration files of the SQLite storage, named like the ones of Migrations.
+func SQLiteMigrations() fs.FS {
+	migrations, err := fs.Sub(sqliteFiles, "sqlite")
+	if err != nil {
+		panic(err)
+	}
+	return migrations
+}
@@ -0,0 +1,3 @@
+DROP SCHEMA IF EXISTS todo_app CASCADE;
+
+DROP FUNCTION IF EXISTS update_time();
@@ -0,0 +1,58 @@
+CREATE
+OR REPLACE FUNCTION update_time()
+    RETURNS TRIGGER AS $$
+BEGIN
+   IF
+row(NEW.*) IS DISTINCT FROM row(OLD.*) THEN
+      NEW.updated_at := now();
+RETURN NEW;
+ELSE
+      RETURN OLD;
+END IF;
+END;
+$$ language 'plpgsql';
+
+
+-- Databases created before the migrations were versioned have these tables already,
+-- the later migrations add what they lack.
+CREATE SCHEMA IF NOT EXISTS todo_app;
+
+CREATE TABLE IF NOT EXISTS todo_app.users
+(
+    user_id  serial PRIMARY KEY,
+    username VARCHAR(50) UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS todo_app.todo_list
+(
+    id  serial PRIMARY KEY,
+    user_id    INT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    message    VARCHAR(40000),
+    FOREIGN KEY (user_id)
+        REFERENCES todo_app.users (user_id)
+);
+
+DROP TRIGGER IF EXISTS prevent_timestamp_changes ON todo_app.todo_list;
+CREATE TRIGGER prevent_timestamp_changes
+    BEFORE UPDATE
+    ON todo_app.todo_list
+    FOR EACH ROW
+    EXECUTE PROCEDURE update_time();
+
+CREATE INDEX IF NOT EXISTS todo_list_user_id_created_at_idx
+    ON todo_app.todo_list (user_id, created_at, id);
+
+
+CREATE TABLE IF NOT EXISTS todo_app.api_tokens
+(
+    id         serial PRIMARY KEY,
+    user_id    INT          NOT NULL,
+    name       VARCHAR(100) NOT NULL,
+    token_hash BYTEA UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    FOREIGN KEY (user_id)
+        REFERENCES todo_app.users (user_id)
+        ON DELETE CASCADE
+);
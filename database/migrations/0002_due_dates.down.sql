@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS todo_app.todo_list_user_id_due_at_idx;
+
+ALTER TABLE todo_app.todo_list
+    DROP COLUMN IF EXISTS due_at,
+    DROP COLUMN IF EXISTS reminder_at;
@@ -0,0 +1,6 @@
+ALTER TABLE todo_app.todo_list
+    ADD COLUMN IF NOT EXISTS due_at      TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS reminder_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS todo_list_user_id_due_at_idx
+    ON todo_app.todo_list (user_id, due_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS todo_app.todo_list_user_id_status_idx;
+
+ALTER TABLE todo_app.todo_list
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS completed_at;
@@ -0,0 +1,7 @@
+ALTER TABLE todo_app.todo_list
+    ADD COLUMN IF NOT EXISTS status       VARCHAR(20) NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'in_progress', 'done', 'cancelled')),
+    ADD COLUMN IF NOT EXISTS completed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS todo_list_user_id_status_idx
+    ON todo_app.todo_list (user_id, status);
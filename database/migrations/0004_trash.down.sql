@@ -0,0 +1,7 @@
+-- Todos in the trash would come back, they are removed for good instead.
+DELETE FROM todo_app.todo_list WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS todo_app.todo_list_deleted_at_idx;
+
+ALTER TABLE todo_app.todo_list
+    DROP COLUMN IF EXISTS deleted_at;
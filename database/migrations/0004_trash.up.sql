@@ -0,0 +1,6 @@
+ALTER TABLE todo_app.todo_list
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS todo_list_deleted_at_idx
+    ON todo_app.todo_list (deleted_at)
+    WHERE deleted_at IS NOT NULL;
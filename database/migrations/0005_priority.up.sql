@@ -0,0 +1,19 @@
+ALTER TABLE todo_app.todo_list
+    ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 1
+        CHECK (priority BETWEEN 0 AND 3),
+    ADD COLUMN IF NOT EXISTS position TEXT COLLATE "C";
+
+-- Positions of the existing todos follow their creation order.
+UPDATE todo_app.todo_list t
+SET position = p.position
+FROM (
+    SELECT id, lpad(row_number() OVER (PARTITION BY user_id ORDER BY created_at, id)::text, 10, '0') || 'V' AS position
+    FROM todo_app.todo_list
+) p
+WHERE t.id = p.id AND t.position IS NULL;
+
+ALTER TABLE todo_app.todo_list
+    ALTER COLUMN position SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS todo_list_user_id_position_idx
+    ON todo_app.todo_list (user_id, position, id);
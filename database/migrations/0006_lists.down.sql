@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS todo_app.todo_list_list_id_idx;
+
+ALTER TABLE todo_app.todo_list
+    DROP COLUMN IF EXISTS list_id;
+
+DROP TABLE IF EXISTS todo_app.lists;
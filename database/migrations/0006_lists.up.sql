@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS todo_app.lists
+(
+    id         serial PRIMARY KEY,
+    user_id    INT          NOT NULL,
+    name       VARCHAR(100) NOT NULL,
+    color      VARCHAR(7)   NOT NULL DEFAULT '',
+    archived   BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id)
+        REFERENCES todo_app.users (user_id)
+        ON DELETE CASCADE
+);
+
+-- Todos of a deleted list move to the inbox of its owner.
+ALTER TABLE todo_app.todo_list
+    ADD COLUMN IF NOT EXISTS list_id INT
+        REFERENCES todo_app.lists (id)
+        ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS todo_list_list_id_idx
+    ON todo_app.todo_list (list_id);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS todo_app.tags
+(
+    id      serial PRIMARY KEY,
+    user_id INT         NOT NULL,
+    name    VARCHAR(50) NOT NULL,
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id)
+        REFERENCES todo_app.users (user_id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS todo_app.todo_tags
+(
+    todo_id INT NOT NULL,
+    tag_id  INT NOT NULL,
+    PRIMARY KEY (todo_id, tag_id),
+    FOREIGN KEY (todo_id)
+        REFERENCES todo_app.todo_list (id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (tag_id)
+        REFERENCES todo_app.tags (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS todo_tags_tag_id_idx
+    ON todo_app.todo_tags (tag_id);
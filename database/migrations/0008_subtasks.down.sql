@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS todo_app.todo_list_parent_id_idx;
+
+ALTER TABLE todo_app.todo_list
+    DROP COLUMN IF EXISTS parent_id;
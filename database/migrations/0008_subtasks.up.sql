@@ -0,0 +1,8 @@
+-- Subtasks of a purged todo become top-level todos.
+ALTER TABLE todo_app.todo_list
+    ADD COLUMN IF NOT EXISTS parent_id INT
+        REFERENCES todo_app.todo_list (id)
+        ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS todo_list_parent_id_idx
+    ON todo_app.todo_list (parent_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS todo_app.todo_list_series_idx;
+
+ALTER TABLE todo_app.todo_list
+    DROP COLUMN IF EXISTS recurrence,
+    DROP COLUMN IF EXISTS series_id,
+    DROP COLUMN IF EXISTS series_start;
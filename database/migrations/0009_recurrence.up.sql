@@ -0,0 +1,9 @@
+-- series_id is NULL for the first occurrence of a series, the series is then identified by its id.
+ALTER TABLE todo_app.todo_list
+    ADD COLUMN IF NOT EXISTS recurrence   TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS series_id    INT,
+    ADD COLUMN IF NOT EXISTS series_start TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS todo_list_series_idx
+    ON todo_app.todo_list (coalesce(series_id, id))
+    WHERE recurrence <> '';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS todo_app.todo_list_search_vector_idx;
+
+ALTER TABLE todo_app.todo_list
+    DROP COLUMN IF EXISTS search_vector;
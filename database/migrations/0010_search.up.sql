@@ -0,0 +1,6 @@
+ALTER TABLE todo_app.todo_list
+    ADD COLUMN IF NOT EXISTS search_vector tsvector
+        GENERATED ALWAYS AS (to_tsvector('english', coalesce(message, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS todo_list_search_vector_idx
+    ON todo_app.todo_list USING GIN (search_vector);
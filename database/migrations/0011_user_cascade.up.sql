@@ -0,0 +1,6 @@
+-- Deleting a user deletes their todos, databases created before it refuse the delete.
+ALTER TABLE todo_app.todo_list
+    DROP CONSTRAINT IF EXISTS todo_list_user_id_fkey,
+    ADD CONSTRAINT todo_list_user_id_fkey FOREIGN KEY (user_id)
+        REFERENCES todo_app.users (user_id)
+        ON DELETE CASCADE;
@@ -0,0 +1,2 @@
+ALTER TABLE todo_app.todo_list
+    DROP COLUMN IF EXISTS version;
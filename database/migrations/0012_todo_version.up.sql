@@ -0,0 +1,3 @@
+-- The version of a todo is its ETag, every change bumps it.
+ALTER TABLE todo_app.todo_list
+    ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
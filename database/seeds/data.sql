@@ -3,9 +3,9 @@ INSERT INTO todo_app.users (user_id, username)
 INSERT INTO todo_app.users (user_id, username)
     VALUES (DEFAULT, 'Dúnadan');
 
-INSERT INTO todo_app.todo_list (id, user_id, created_at, updated_at, message)
-    VALUES (DEFAULT, 1, DEFAULT, DEFAULT, 'Kill more orcs than Gimli');
-INSERT INTO todo_app.todo_list (id, user_id, created_at, updated_at, message)
-    VALUES (DEFAULT, 1, DEFAULT, DEFAULT, 'Help Minas Tirit');
-INSERT INTO todo_app.todo_list (id, user_id, created_at, updated_at, message)
-    VALUES (DEFAULT, 1, DEFAULT, DEFAULT, 'To be handsome');
\ No newline at end of file
+INSERT INTO todo_app.todo_list (id, user_id, created_at, updated_at, message, position)
+    VALUES (DEFAULT, 1, DEFAULT, DEFAULT, 'Kill more orcs than Gimli', '0000000001V');
+INSERT INTO todo_app.todo_list (id, user_id, created_at, updated_at, message, position)
+    VALUES (DEFAULT, 1, DEFAULT, DEFAULT, 'Help Minas Tirit', '0000000002V');
+INSERT INTO todo_app.todo_list (id, user_id, created_at, updated_at, message, position)
+    VALUES (DEFAULT, 1, DEFAULT, DEFAULT, 'To be handsome', '0000000003V');
\ No newline at end of file
@@ -0,0 +1,6 @@
+DROP TABLE api_tokens;
+DROP TABLE todo_tags;
+DROP TABLE tags;
+DROP TABLE todo_list;
+DROP TABLE lists;
+DROP TABLE users;
@@ -0,0 +1,120 @@
+-- Times are UTC text like '2006-01-02 15:04:05.999999999+00:00' without trailing zeros,
+-- the format of the driver, so they compare in order.
+
+CREATE TABLE users
+(
+    user_id  INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) UNIQUE NOT NULL
+);
+
+CREATE TABLE lists
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER      NOT NULL,
+    name       VARCHAR(100) NOT NULL,
+    color      VARCHAR(7)   NOT NULL DEFAULT '',
+    archived   BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP    NOT NULL
+        DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00'),
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id)
+        REFERENCES users (user_id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE todo_list
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id      INTEGER   NOT NULL,
+    created_at   TIMESTAMP NOT NULL
+        DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00'),
+    updated_at   TIMESTAMP NOT NULL
+        DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00'),
+    message      VARCHAR(40000) NOT NULL,
+    version      INTEGER   NOT NULL DEFAULT 1,
+    due_at       TIMESTAMP,
+    reminder_at  TIMESTAMP,
+    status       VARCHAR(20) NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'in_progress', 'done', 'cancelled')),
+    completed_at TIMESTAMP,
+    deleted_at   TIMESTAMP,
+    priority     SMALLINT  NOT NULL DEFAULT 1
+        CHECK (priority BETWEEN 0 AND 3),
+    position     TEXT      NOT NULL COLLATE BINARY,
+    -- Todos of a deleted list move to the inbox of its owner.
+    list_id      INTEGER
+        REFERENCES lists (id)
+        ON DELETE SET NULL,
+    -- Subtasks of a purged todo become top-level todos.
+    parent_id    INTEGER
+        REFERENCES todo_list (id)
+        ON DELETE SET NULL,
+    -- series_id is NULL for the first occurrence of a series, the series is then identified by its id.
+    recurrence   TEXT      NOT NULL DEFAULT '',
+    series_id    INTEGER,
+    series_start TIMESTAMP,
+    FOREIGN KEY (user_id)
+        REFERENCES users (user_id)
+        ON DELETE CASCADE
+);
+
+-- The statements leave updated_at to the trigger, like update_time() of Postgres.
+-- Recursive triggers are off, so the update of the trigger does not fire it again.
+CREATE TRIGGER todo_list_updated_at
+    AFTER UPDATE
+    ON todo_list
+    FOR EACH ROW
+    WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE todo_list
+    SET updated_at = rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00'
+    WHERE id = NEW.id;
+END;
+
+CREATE INDEX todo_list_user_id_created_at_idx ON todo_list (user_id, created_at, id);
+CREATE INDEX todo_list_user_id_due_at_idx ON todo_list (user_id, due_at);
+CREATE INDEX todo_list_user_id_status_idx ON todo_list (user_id, status);
+CREATE INDEX todo_list_deleted_at_idx ON todo_list (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX todo_list_user_id_position_idx ON todo_list (user_id, position, id);
+CREATE INDEX todo_list_list_id_idx ON todo_list (list_id);
+CREATE INDEX todo_list_parent_id_idx ON todo_list (parent_id);
+CREATE INDEX todo_list_series_idx ON todo_list (coalesce(series_id, id)) WHERE recurrence <> '';
+
+CREATE TABLE tags
+(
+    id      INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER     NOT NULL,
+    name    VARCHAR(50) NOT NULL,
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id)
+        REFERENCES users (user_id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE todo_tags
+(
+    todo_id INTEGER NOT NULL,
+    tag_id  INTEGER NOT NULL,
+    PRIMARY KEY (todo_id, tag_id),
+    FOREIGN KEY (todo_id)
+        REFERENCES todo_list (id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (tag_id)
+        REFERENCES tags (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX todo_tags_tag_id_idx ON todo_tags (tag_id);
+
+CREATE TABLE api_tokens
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER      NOT NULL,
+    name       VARCHAR(100) NOT NULL,
+    token_hash BLOB UNIQUE  NOT NULL,
+    created_at TIMESTAMP    NOT NULL
+        DEFAULT (rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00'),
+    FOREIGN KEY (user_id)
+        REFERENCES users (user_id)
+        ON DELETE CASCADE
+);
@@ -0,0 +1,86 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"to-do/api"
+	"to-do/app"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	batchPath = "batch"
+)
+
+// postToDo serves POST /todo/batch. httprouter can not route /todo/batch next to
+// /todo/:todoid, so it is served for the todo id "batch".
+func (s *httpService) postToDo(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	if params.ByName(ToDoIDParam) != batchPath {
+		writeError(w, api.NotFoundf("POST %s not found", req.URL.Path))
+		return
+	}
+	s.batchToDos(w, req, params)
+}
+
+// batchToDos runs the operations of an api.ToDoBatch and responds with the status of every
+// operation. The response is 200 whenever the batch ran, even if its operations failed.
+func (s *httpService) batchToDos(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	var batch api.ToDoBatch
+	if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	ops := make([]app.BatchOperation, len(batch.Operations))
+	for i, operation := range batch.Operations {
+		op, err := parseBatchOperation(operation)
+		if err != nil {
+			writeError(w, badRequestf("operation %d: %s", i, err))
+			return
+		}
+		ops[i] = op
+	}
+
+	results, err := s.todoService.Batch(req.Context(), ops, batch.Atomic)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response := api.BatchResponse{Results: make([]api.BatchResult, len(results))}
+	for i, result := range results {
+		switch {
+		case result.Err != nil:
+			status, body := errorStatus(result.Err)
+			response.Results[i] = api.BatchResult{Status: status, Error: &body}
+		case ops[i].Op == api.BatchCreate:
+			response.Results[i] = api.BatchResult{Status: http.StatusCreated, ToDo: result.ToDo}
+		default:
+			response.Results[i] = api.BatchResult{Status: http.StatusOK, ToDo: result.ToDo}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+func parseBatchOperation(operation api.BatchOperation) (app.BatchOperation, error) {
+	op := app.BatchOperation{Op: operation.Op, ID: operation.ID}
+	if operation.Version != nil {
+		op.Cond.IfMatch = []int64{*operation.Version}
+	}
+
+	var err error
+	switch operation.Op {
+	case api.BatchCreate:
+		err = json.Unmarshal(operation.ToDo, &op.ToDo)
+	case api.BatchUpdate:
+		op.Patch, err = app.NewMergePatch(operation.ToDo)
+	}
+	return op, err
+}
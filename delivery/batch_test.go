@@ -0,0 +1,99 @@
+// +build integration
+
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"to-do/api"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchTodos(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := newHttpTestService()
+	require.NoError(t, err)
+	user := newTestUser(t, service, "Frodo")
+
+	batch := func(body string) (int, api.BatchResponse) {
+		responseRecorder := httptest.NewRecorder()
+		request := withUser(httptest.NewRequest(http.MethodPost, testURL+"/todo/batch", strings.NewReader(body)), user.ID)
+		service.postToDo(responseRecorder, request, httprouter.Params{httprouter.Param{Key: "todoid", Value: "batch"}})
+		response := api.BatchResponse{}
+		if responseRecorder.Code == http.StatusOK {
+			assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&response))
+		}
+		return responseRecorder.Code, response
+	}
+	statuses := func(response api.BatchResponse) []int {
+		statuses := []int{}
+		for _, result := range response.Results {
+			statuses = append(statuses, result.Status)
+		}
+		return statuses
+	}
+	count := func() int64 {
+		responseRecorder := httptest.NewRecorder()
+		request := withUser(httptest.NewRequest(http.MethodGet, testURL+"?offset=0", nil), user.ID)
+		service.listToDos(responseRecorder, request, httprouter.Params{
+			httprouter.Param{Key: "userid", Value: strconv.FormatInt(user.ID, 10)},
+		})
+		assert.Equal(http.StatusOK, responseRecorder.Code)
+		page := api.ToDoPage{}
+		assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&page))
+		return page.Total
+	}
+
+	code, response := batch(`{"operations": [
+		{"op": "create", "todo": {"message": "Pack the map"}},
+		{"op": "create", "todo": {"message": "Leave the Shire", "priority": "high"}},
+		{"op": "create", "todo": {"message": ""}}
+	]}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []int{http.StatusCreated, http.StatusCreated, http.StatusUnprocessableEntity}, statuses(response))
+	assert.Equal("validation_failed", response.Results[2].Error.Code)
+	packed, left := response.Results[0].ToDo, response.Results[1].ToDo
+	assert.Equal(api.PriorityHigh, left.Priority)
+	assert.Equal(int64(2), count())
+
+	code, response = batch(fmt.Sprintf(`{"operations": [
+		{"op": "update", "id": %d, "version": %d, "todo": {"priority": "urgent"}},
+		{"op": "update", "id": %d, "version": %d, "todo": {"priority": "low"}},
+		{"op": "delete", "id": %d},
+		{"op": "delete", "id": 0}
+	]}`, packed.ID, packed.Version, left.ID, left.Version+1, left.ID))
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []int{http.StatusOK, http.StatusPreconditionFailed, http.StatusOK, http.StatusNotFound}, statuses(response))
+	assert.Equal(api.PriorityUrgent, response.Results[0].ToDo.Priority)
+	assert.Equal(int64(1), count())
+
+	// A failure rolls back the whole atomic batch.
+	code, response = batch(fmt.Sprintf(`{"atomic": true, "operations": [
+		{"op": "create", "todo": {"message": "Meet the dwarves"}},
+		{"op": "delete", "id": %d},
+		{"op": "update", "id": %d, "todo": {"priority": "sometime"}},
+		{"op": "create", "todo": {"message": "Find the arkenstone"}}
+	]}`, packed.ID, packed.ID))
+	assert.Equal(http.StatusOK, code)
+	assert.Equal([]int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusNotFound, http.StatusFailedDependency}, statuses(response))
+	assert.Equal(int64(1), count())
+
+	code, _ = batch(`{"operations": [{"op": "update", "id": 1, "todo": [1]}]}`)
+	assert.Equal(http.StatusBadRequest, code)
+	code, _ = batch(`{"operations": []}`)
+	assert.Equal(http.StatusUnprocessableEntity, code)
+
+	responseRecorder := httptest.NewRecorder()
+	request := withUser(httptest.NewRequest(http.MethodPost, testURL+"/todo/1", strings.NewReader(`{}`)), user.ID)
+	service.postToDo(responseRecorder, request, httprouter.Params{httprouter.Param{Key: "todoid", Value: "1"}})
+	assert.Equal(http.StatusNotFound, responseRecorder.Code)
+}
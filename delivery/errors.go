@@ -0,0 +1,68 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"to-do/api"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// errBadRequest is the kind of errors about malformed requests: bodies,
+// path and query parameters that can not be parsed.
+var errBadRequest = errors.New("bad request")
+
+var errUnsupportedMediaType = errors.New("unsupported media type")
+
+func badRequest(err error) error {
+	return api.NewError(errBadRequest, "%s", err)
+}
+
+func badRequestf(format string, args ...interface{}) error {
+	return api.NewError(errBadRequest, format, args...)
+}
+
+var errorStatuses = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{kind: errBadRequest, status: http.StatusBadRequest, code: "bad_request"},
+	{kind: errUnsupportedMediaType, status: http.StatusUnsupportedMediaType, code: "unsupported_media_type"},
+	{kind: api.ErrUnauthorized, status: http.StatusUnauthorized, code: "unauthorized"},
+	{kind: api.ErrForbidden, status: http.StatusForbidden, code: "forbidden"},
+	{kind: api.ErrNotFound, status: http.StatusNotFound, code: "not_found"},
+	{kind: api.ErrConflict, status: http.StatusConflict, code: "conflict"},
+	{kind: api.ErrValidation, status: http.StatusUnprocessableEntity, code: "validation_failed"},
+	{kind: api.ErrPreconditionFailed, status: http.StatusPreconditionFailed, code: "precondition_failed"},
+	{kind: api.ErrAborted, status: http.StatusFailedDependency, code: "aborted"},
+}
+
+// writeError is the only place errors are turned into http responses.
+// Domain errors keep their message, anything else is reported as an
+// internal error without details.
+func writeError(w http.ResponseWriter, err error) {
+	status, body := errorStatus(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(api.ErrorResponse{Error: body}); err != nil {
+		log.Error("cant write error response: ", err)
+	}
+}
+
+// errorStatus returns the http status and the body of err, see writeError.
+func errorStatus(err error) (int, api.ErrorBody) {
+	for _, s := range errorStatuses {
+		if errors.Is(err, s.kind) {
+			return s.status, api.ErrorBody{Code: s.code, Message: err.Error()}
+		}
+	}
+	log.Error("internal error: ", err)
+	return http.StatusInternalServerError, api.ErrorBody{
+		Code:    "internal",
+		Message: http.StatusText(http.StatusInternalServerError),
+	}
+}
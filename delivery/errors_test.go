@@ -0,0 +1,90 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"to-do/api"
+	"to-do/app"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteError(t *testing.T) {
+	assert := assert.New(t)
+
+	tt := []struct {
+		name       string
+		err        error
+		statusCode int
+		code       string
+		message    string
+	}{
+		{
+			name:       "Bad request. 400",
+			err:        badRequestf("invalid limit"),
+			statusCode: http.StatusBadRequest,
+			code:       "bad_request",
+			message:    "invalid limit",
+		},
+		{
+			name:       "Wrapped not found. 404",
+			err:        errors.Wrap(api.NotFoundf("todo 1 not found"), "get todo"),
+			statusCode: http.StatusNotFound,
+			code:       "not_found",
+			message:    "get todo: todo 1 not found",
+		},
+		{
+			name:       "Forbidden sentinel. 403",
+			err:        api.ErrForbidden,
+			statusCode: http.StatusForbidden,
+			code:       "forbidden",
+			message:    "forbidden",
+		},
+		{
+			name:       "Conflict. 409",
+			err:        api.Conflictf("name already taken"),
+			statusCode: http.StatusConflict,
+			code:       "conflict",
+			message:    "name already taken",
+		},
+		{
+			name:       "Validation. 422",
+			err:        api.Validationf("message cant be empty"),
+			statusCode: http.StatusUnprocessableEntity,
+			code:       "validation_failed",
+			message:    "message cant be empty",
+		},
+		{
+			name:       "Invalid cursor. 422",
+			err:        app.ErrInvalidCursor,
+			statusCode: http.StatusUnprocessableEntity,
+			code:       "validation_failed",
+			message:    "invalid cursor",
+		},
+		{
+			name:       "Internal errors hide details. 500",
+			err:        errors.New("pq: connection refused"),
+			statusCode: http.StatusInternalServerError,
+			code:       "internal",
+			message:    "Internal Server Error",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			responseRecorder := httptest.NewRecorder()
+
+			writeError(responseRecorder, tc.err)
+
+			assert.Equal(tc.statusCode, responseRecorder.Code, tc.name)
+			assert.Equal("application/json", responseRecorder.Header().Get("Content-Type"), tc.name)
+			var body api.ErrorResponse
+			assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&body))
+			assert.Equal(tc.code, body.Error.Code, tc.name)
+			assert.Equal(tc.message, body.Error.Message, tc.name)
+		})
+	}
+}
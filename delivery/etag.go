@@ -0,0 +1,79 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"to-do/api"
+)
+
+// noVersion never matches a todo, versions start at 1.
+const noVersion int64 = 0
+
+// etag is the strong entity tag of a todo version.
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// parsePrecondition reads the If-Match and If-None-Match headers. If-Match
+// uses the strong comparison, so weak tags in it never match.
+func parsePrecondition(req *http.Request) (api.Precondition, error) {
+	var (
+		cond api.Precondition
+		err  error
+	)
+	if header := req.Header.Get("If-Match"); header != "" {
+		cond.IfMatch, err = parseETags(header, false)
+		if err != nil {
+			return cond, err
+		}
+	}
+	if header := req.Header.Get("If-None-Match"); header != "" {
+		cond.IfNoneMatch, err = parseETags(header, true)
+		if err != nil {
+			return cond, err
+		}
+	}
+	return cond, nil
+}
+
+// parseETags returns the versions of a comma separated list of entity tags.
+func parseETags(header string, weak bool) ([]int64, error) {
+	versions := []int64{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			versions = append(versions, api.AnyVersion)
+			continue
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				versions = append(versions, noVersion)
+				continue
+			}
+			tag = tag[len("W/"):]
+		}
+
+		unquoted, err := strconv.Unquote(tag)
+		if err != nil || !strings.HasPrefix(tag, `"`) {
+			return nil, badRequestf("invalid entity tag %s", tag)
+		}
+		version, err := strconv.ParseInt(unquoted, 10, 64)
+		if err != nil {
+			// Not one of ours, it can not match any version.
+			version = noVersion
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// notModified reports if the If-None-Match header of a GET request matches version.
+func notModified(cond api.Precondition, version int64) bool {
+	for _, v := range cond.IfNoneMatch {
+		if v == api.AnyVersion || v == version {
+			return true
+		}
+	}
+	return false
+}
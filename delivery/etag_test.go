@@ -0,0 +1,81 @@
+package delivery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"to-do/api"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePrecondition(t *testing.T) {
+	assert := assert.New(t)
+
+	tt := []struct {
+		name        string
+		ifMatch     string
+		ifNoneMatch string
+		expected    api.Precondition
+		isErr       bool
+	}{
+		{
+			name: "No headers",
+		},
+		{
+			name:     "If-Match list",
+			ifMatch:  `"3", "4"`,
+			expected: api.Precondition{IfMatch: []int64{3, 4}},
+		},
+		{
+			name:     "Weak tags never match strongly",
+			ifMatch:  `W/"3"`,
+			expected: api.Precondition{IfMatch: []int64{noVersion}},
+		},
+		{
+			name:        "If-None-Match uses weak comparison",
+			ifNoneMatch: `W/"3"`,
+			expected:    api.Precondition{IfNoneMatch: []int64{3}},
+		},
+		{
+			name:        "Any version",
+			ifMatch:     `*`,
+			ifNoneMatch: `*`,
+			expected:    api.Precondition{IfMatch: []int64{api.AnyVersion}, IfNoneMatch: []int64{api.AnyVersion}},
+		},
+		{
+			name:     "Foreign entity tag",
+			ifMatch:  `"abc"`,
+			expected: api.Precondition{IfMatch: []int64{noVersion}},
+		},
+		{
+			name:    "Unquoted entity tag",
+			ifMatch: `3`,
+			isErr:   true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPut, "/todo/1", nil)
+			if tc.ifMatch != "" {
+				request.Header.Set("If-Match", tc.ifMatch)
+			}
+			if tc.ifNoneMatch != "" {
+				request.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+
+			cond, err := parsePrecondition(request)
+			if tc.isErr {
+				assert.Error(err, tc.name)
+				return
+			}
+			assert.NoError(err, tc.name)
+			assert.Equal(tc.expected, cond, tc.name)
+		})
+	}
+}
+
+func TestETag(t *testing.T) {
+	assert.Equal(t, `"7"`, etag(7))
+}
@@ -0,0 +1,127 @@
+package delivery
+
+import (
+	"strings"
+	"to-do/app"
+	"unicode"
+)
+
+const (
+	filterQuery = "filter"
+)
+
+// filterOperators are tried in order, so the longer operators come first.
+var filterOperators = []string{app.OpLessEqual, app.OpGreaterEqual, app.OpEqual, app.OpLess, app.OpGreater}
+
+// parseFilter reads a filter expression of conditions joined with AND:
+//
+//	status:open,in_progress AND due<2026-11-01 AND text:"fresh bread"
+//
+// A condition is a field, an operator (: < <= > >=) and a value. An unquoted value
+// ends at a space, a comma separates the values of :. A quoted value can contain
+// spaces and commas, \" and \\ stand for a quote and a backslash in it. The fields
+// and the values are checked by app.ToDoQuery.
+func parseFilter(expression string) ([]app.Condition, error) {
+	conditions := []app.Condition{}
+	rest := strings.TrimSpace(expression)
+	for rest != "" {
+		if len(conditions) > 0 {
+			keyword := rest
+			if end := strings.IndexFunc(rest, unicode.IsSpace); end >= 0 {
+				keyword = rest[:end]
+			}
+			if !strings.EqualFold(keyword, "AND") || len(keyword) == len(rest) {
+				return nil, badRequestf("invalid %s: want AND and a condition after %q", filterQuery, expression[:len(expression)-len(rest)])
+			}
+			rest = strings.TrimSpace(rest[len(keyword):])
+		}
+
+		condition, tail, err := parseCondition(rest)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		rest = strings.TrimSpace(tail)
+	}
+	return conditions, nil
+}
+
+// parseCondition reads the condition at the start of s and returns the rest of s.
+func parseCondition(s string) (app.Condition, string, error) {
+	var condition app.Condition
+	end := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && r != '_' })
+	if end <= 0 {
+		return condition, "", badRequestf("invalid %s: want a field at %q", filterQuery, s)
+	}
+	condition.Field, s = strings.ToLower(s[:end]), s[end:]
+
+	for _, op := range filterOperators {
+		if strings.HasPrefix(s, op) {
+			condition.Op, s = op, s[len(op):]
+			break
+		}
+	}
+	if condition.Op == "" {
+		return condition, "", badRequestf("invalid %s: want an operator after %q", filterQuery, condition.Field)
+	}
+
+	if strings.HasPrefix(s, `"`) {
+		value, rest, err := parseQuoted(s)
+		if err != nil {
+			return condition, "", err
+		}
+		condition.Values = []string{value}
+		return condition, rest, nil
+	}
+
+	end = strings.IndexFunc(s, unicode.IsSpace)
+	if end < 0 {
+		end = len(s)
+	}
+	value := s[:end]
+	if value == "" || strings.Contains(value, `"`) {
+		return condition, "", badRequestf("invalid %s: want a value after %s%s", filterQuery, condition.Field, condition.Op)
+	}
+	if condition.Op == app.OpEqual {
+		condition.Values = strings.Split(value, ",")
+	} else {
+		condition.Values = []string{value}
+	}
+	return condition, s[end:], nil
+}
+
+// parseQuoted reads the quoted value at the start of s and returns the rest of s.
+func parseQuoted(s string) (string, string, error) {
+	var value strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			rest := s[i+1:]
+			if rest != "" && !unicode.IsSpace(rune(rest[0])) {
+				return "", "", badRequestf("invalid %s: want a space after %q", filterQuery, s[:i+1])
+			}
+			return value.String(), rest, nil
+		case '\\':
+			if i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+				i++
+			}
+		}
+		value.WriteByte(s[i])
+	}
+	return "", "", badRequestf("invalid %s: unterminated quote in %q", filterQuery, s)
+}
+
+// parseSort reads sort=<field>[,<field>...] with the fields created, updated, due, completed,
+// priority and position, a - before a field sorts it in descending order.
+func parseSort(value string) []app.SortKey {
+	if value == "" {
+		return nil
+	}
+	keys := []app.SortKey{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		key := app.SortKey{Field: strings.TrimPrefix(field, "-"), Desc: strings.HasPrefix(field, "-")}
+		keys = append(keys, key)
+	}
+	return keys
+}
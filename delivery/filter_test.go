@@ -0,0 +1,75 @@
+package delivery
+
+import (
+	"testing"
+	"to-do/app"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	tt := []struct {
+		name       string
+		expression string
+		expected   []app.Condition
+		isErr      bool
+	}{
+		{
+			name:     "Empty",
+			expected: []app.Condition{},
+		},
+		{
+			name:       "Conditions",
+			expression: ` status:open,in_progress AND due<2026-11-01 and Priority>=high `,
+			expected: []app.Condition{
+				{Field: app.FieldStatus, Op: app.OpEqual, Values: []string{"open", "in_progress"}},
+				{Field: app.FieldDue, Op: app.OpLess, Values: []string{"2026-11-01"}},
+				{Field: app.FieldPriority, Op: app.OpGreaterEqual, Values: []string{"high"}},
+			},
+		},
+		{
+			name:       "Quoted values",
+			expression: `text:"buy \"fresh, bread\"" AND tag:"farmer maggot"`,
+			expected: []app.Condition{
+				{Field: app.FieldText, Op: app.OpEqual, Values: []string{`buy "fresh, bread"`}},
+				{Field: app.FieldTag, Op: app.OpEqual, Values: []string{"farmer maggot"}},
+			},
+		},
+		{
+			name:       "Timestamp",
+			expression: "updated>2026-10-18T10:00:00+03:00",
+			expected: []app.Condition{
+				{Field: app.FieldUpdated, Op: app.OpGreater, Values: []string{"2026-10-18T10:00:00+03:00"}},
+			},
+		},
+		{name: "Missing AND", expression: "status:open due<2026-11-01", isErr: true},
+		{name: "Trailing AND", expression: "status:open AND", isErr: true},
+		{name: "OR", expression: "status:open OR status:done", isErr: true},
+		{name: "Missing operator", expression: "status", isErr: true},
+		{name: "Unknown operator", expression: "due=2026-11-01", isErr: true},
+		{name: "Missing value", expression: "status: AND due<2026-11-01", isErr: true},
+		{name: "Unterminated quote", expression: `text:"bread`, isErr: true},
+		{name: "Text after quote", expression: `text:"bread"milk`, isErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			conditions, err := parseFilter(tc.expression)
+			if tc.isErr {
+				assert.Error(err, tc.name)
+				return
+			}
+			assert.NoError(err, tc.name)
+			assert.Equal(tc.expected, conditions, tc.name)
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(parseSort(""))
+	assert.Equal([]app.SortKey{{Field: "priority", Desc: true}, {Field: "created"}}, parseSort("-priority, created"))
+}
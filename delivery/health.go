@@ -0,0 +1,40 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"to-do/api"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// databaseHealth reports whether the database answers, it answers
+// 503 Service Unavailable while the database is down.
+func (s *httpService) databaseHealth(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	health := s.healthService.DatabaseHealth(req.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if health.Status != api.HealthUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		log.Error("cant encode database health: ", err)
+	}
+}
+
+// poolStats returns the statistics of the database connection pool, it is served only with DBStats.
+func (s *httpService) poolStats(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	stats, err := s.healthService.PoolStats()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Error("cant encode pool stats: ", err)
+	}
+}
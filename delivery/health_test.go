@@ -0,0 +1,33 @@
+// +build integration
+
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"to-do/api"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabaseHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := newHttpTestService()
+	assert.NoError(err)
+
+	responseRecorder := httptest.NewRecorder()
+	service.databaseHealth(responseRecorder, httptest.NewRequest(http.MethodGet, testURL+"/health/db", nil), nil)
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+	assert.Equal("application/json", responseRecorder.Header().Get("Content-Type"))
+	health := api.DatabaseHealth{}
+	assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&health))
+	assert.Equal(api.HealthUp, health.Status)
+	assert.Empty(health.Error)
+
+	// The pool statistics are served only with DBStats.
+	handle, _, _ := service.router.Lookup(http.MethodGet, "/debug/db")
+	assert.Nil(handle)
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/http/pprof"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 	"to-do/api"
 	"to-do/app"
@@ -18,6 +22,26 @@ import (
 
 const (
 	ToDoIDParam = "todoid"
+	UserIDParam = "userid"
+
+	mergePatchMediaType = "application/merge-patch+json"
+	jsonPatchMediaType  = "application/json-patch+json"
+
+	limitQuery  = "limit"
+	offsetQuery = "offset"
+	cursorQuery = "cursor"
+
+	statusQuery    = "status"
+	dueQuery       = "due"
+	dueWithinQuery = "due_within"
+	timezoneQuery  = "tz"
+	sortQuery      = "sort"
+	listQuery      = "list"
+	anyTagsQuery   = "tags_any"
+	allTagsQuery   = "tags_all"
+
+	// inboxList selects the todos without a list in the list query.
+	inboxList = "inbox"
 )
 
 type HTTPConfig struct {
@@ -26,6 +50,8 @@ type HTTPConfig struct {
 	ShutdownTimeout time.Duration
 
 	InitProfiling bool
+	// DBStats serves the statistics of the database connection pool on /debug/db.
+	DBStats bool
 }
 
 func (h *HTTPConfig) Validate() error {
@@ -47,19 +73,30 @@ func (h *HTTPConfig) Validate() error {
 
 type httpService struct {
 	HTTPConfig
-	todoService *app.ToDoService
-	router      *httprouter.Router
+	todoService   *app.ToDoService
+	userService   *app.UserService
+	authService   *app.AuthService
+	listService   *app.ListService
+	healthService *app.HealthService
+	router        *httprouter.Router
 }
 
-func NewHTTPService(cfg HTTPConfig, todoService *app.ToDoService) *httpService {
+func NewHTTPService(cfg HTTPConfig, todoService *app.ToDoService, userService *app.UserService, authService *app.AuthService, listService *app.ListService, healthService *app.HealthService) *httpService {
 	service := httpService{
-		HTTPConfig:  cfg,
-		todoService: todoService,
-		router:      httprouter.New(),
+		HTTPConfig:    cfg,
+		todoService:   todoService,
+		userService:   userService,
+		authService:   authService,
+		listService:   listService,
+		healthService: healthService,
+		router:        httprouter.New(),
 	}
 	if cfg.InitProfiling {
 		service.pprofHandlers("/debug/pprof")
 	}
+	if cfg.DBStats {
+		service.router.GET("/debug/db", logMiddleware(service.poolStats))
+	}
 	service.registerRoutes()
 	return &service
 }
@@ -72,10 +109,47 @@ func (s *httpService) Run() {
 }
 
 func (s *httpService) registerRoutes() {
-	s.router.GET("/todo/:todoid", logMiddleware(s.getToDo))
-	s.router.PUT("/todo", logMiddleware(s.updateToDo))
-	s.router.POST("/todo", logMiddleware(s.createToDo))
-	s.router.DELETE("/todo/:todoid", logMiddleware(s.deleteToDo))
+	s.router.GET("/todo/:todoid", logMiddleware(s.authMiddleware(s.getToDo)))
+	s.router.PUT("/todo/:todoid", logMiddleware(s.authMiddleware(s.updateToDo)))
+	s.router.PATCH("/todo/:todoid", logMiddleware(s.authMiddleware(s.patchToDo)))
+	s.router.POST("/todo/:todoid/start", logMiddleware(s.authMiddleware(s.transitionToDo(api.StatusInProgress))))
+	s.router.POST("/todo/:todoid/complete", logMiddleware(s.authMiddleware(s.transitionToDo(api.StatusDone))))
+	s.router.POST("/todo/:todoid/cancel", logMiddleware(s.authMiddleware(s.transitionToDo(api.StatusCancelled))))
+	s.router.POST("/todo/:todoid/reopen", logMiddleware(s.authMiddleware(s.transitionToDo(api.StatusOpen))))
+	s.router.POST("/todo", logMiddleware(s.authMiddleware(s.createToDo)))
+	s.router.POST("/todo/:todoid", logMiddleware(s.authMiddleware(s.postToDo)))
+	s.router.DELETE("/todo/:todoid", logMiddleware(s.authMiddleware(s.deleteToDo)))
+	s.router.PATCH("/todo/:todoid/series", logMiddleware(s.authMiddleware(s.updateSeries)))
+	s.router.POST("/todo/:todoid/toggle", logMiddleware(s.authMiddleware(s.toggleToDo)))
+	s.router.GET("/todo/:todoid/subtasks", logMiddleware(s.authMiddleware(s.listSubtasks)))
+	s.router.POST("/todo/:todoid/subtasks", logMiddleware(s.authMiddleware(s.createSubtask)))
+	s.router.POST("/todo/:todoid/tags", logMiddleware(s.authMiddleware(s.addToDoTags)))
+	s.router.DELETE("/todo/:todoid/tags/:tag", logMiddleware(s.authMiddleware(s.removeToDoTag)))
+	s.router.POST("/todo/:todoid/move", logMiddleware(s.authMiddleware(s.moveToDo)))
+	s.router.POST("/todo/:todoid/restore", logMiddleware(s.authMiddleware(s.restoreToDo)))
+	s.router.GET("/users/:userid/todos", logMiddleware(s.authMiddleware(s.listToDos)))
+	s.router.GET("/users/:userid/trash", logMiddleware(s.authMiddleware(s.listTrash)))
+
+	s.router.GET("/users", logMiddleware(s.authMiddleware(s.listUsers)))
+	s.router.POST("/users", logMiddleware(s.createUser))
+	s.router.GET("/users/:userid", logMiddleware(s.authMiddleware(s.getUser)))
+	s.router.PUT("/users/:userid", logMiddleware(s.authMiddleware(s.renameUser)))
+	s.router.DELETE("/users/:userid", logMiddleware(s.authMiddleware(s.deleteUser)))
+
+	s.router.GET("/tags", logMiddleware(s.authMiddleware(s.listTags)))
+	s.router.GET("/search", logMiddleware(s.authMiddleware(s.search)))
+
+	s.router.GET("/lists", logMiddleware(s.authMiddleware(s.listLists)))
+	s.router.POST("/lists", logMiddleware(s.authMiddleware(s.createList)))
+	s.router.GET("/lists/:listid", logMiddleware(s.authMiddleware(s.getList)))
+	s.router.PUT("/lists/:listid", logMiddleware(s.authMiddleware(s.updateList)))
+	s.router.DELETE("/lists/:listid", logMiddleware(s.authMiddleware(s.deleteList)))
+
+	s.router.GET("/tokens", logMiddleware(s.authMiddleware(s.listAPITokens)))
+	s.router.POST("/tokens", logMiddleware(s.authMiddleware(s.createAPIToken)))
+	s.router.DELETE("/tokens/:tokenid", logMiddleware(s.authMiddleware(s.deleteAPIToken)))
+
+	s.router.GET("/health/db", logMiddleware(s.databaseHealth))
 }
 
 func (s *httpService) pprofHandlers(path string) {
@@ -120,62 +194,144 @@ func (s *httpService) getToDo(w http.ResponseWriter, req *http.Request, params h
 
 	todoID, err := strconv.ParseInt(todoIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, badRequest(err))
 		return
 	}
-	todo, err := s.todoService.GetTodo(ctx, todoID)
+	cond, err := parsePrecondition(req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-	if todo == nil {
-		w.WriteHeader(http.StatusNotFound)
+	todo, err := s.todoService.GetTodo(ctx, todoID)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
+	w.Header().Set("ETag", etag(todo.Version))
+	if notModified(cond, todo.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(todo)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
 }
 
 func (s *httpService) updateToDo(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
 	ctx := req.Context()
+	todoID, err := strconv.ParseInt(params.ByName(ToDoIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
 	var newTodo api.ToDo
 	if err := json.NewDecoder(req.Body).Decode(&newTodo); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, badRequest(err))
+		return
+	}
+	if newTodo.ID != 0 && newTodo.ID != todoID {
+		writeError(w, api.Validationf("id %d in body does not match todo %d", newTodo.ID, todoID))
 		return
 	}
+	newTodo.ID = todoID
 
-	if len(newTodo.Message) == 0 {
-		http.Error(w, "message cant be empty", http.StatusNoContent)
+	cond, err := parsePrecondition(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	todo, err := s.todoService.UpdateToDo(ctx, newTodo, cond)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	err := s.todoService.UpdateToDo(ctx, newTodo)
+	w.Header().Set("ETag", etag(todo.Version))
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(todo)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+}
+
+// patchToDo accepts JSON Merge Patch and JSON Patch documents, plain JSON is treated as a merge patch.
+func (s *httpService) patchToDo(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	ctx := req.Context()
+	todoID, err := strconv.ParseInt(params.ByName(ToDoIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	var patch app.ToDoPatch
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case mergePatchMediaType, "application/json":
+		patch, err = app.NewMergePatch(body)
+	case jsonPatchMediaType:
+		patch, err = app.NewJSONPatch(body)
+	default:
+		writeError(w, api.NewError(errUnsupportedMediaType, "use %s or %s", mergePatchMediaType, jsonPatchMediaType))
+		return
+	}
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	cond, err := parsePrecondition(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	todo, err := s.todoService.PatchToDo(ctx, todoID, patch, cond)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(todo.Version))
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(todo)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
 }
 
 func (s *httpService) createToDo(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
 	ctx := req.Context()
 	var newTodo api.ToDo
 	if err := json.NewDecoder(req.Body).Decode(&newTodo); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, badRequest(err))
 		return
 	}
 
-	err := s.todoService.CreateToDo(ctx, newTodo)
+	todo, err := s.todoService.CreateToDo(ctx, newTodo)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/todo/%d", todo.ID))
 	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(todo)
+	if err != nil {
+		log.Error("cant encode created todo: ", err)
+	}
 }
 
 func (s *httpService) deleteToDo(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
@@ -190,15 +346,309 @@ func (s *httpService) deleteToDo(w http.ResponseWriter, req *http.Request, param
 
 	todoId, err := strconv.ParseInt(todoIdStr, 10, 64)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, badRequest(err))
 		return
 	}
 
-	err = s.todoService.DeleteTodo(ctx, todoId)
+	cond, err := parsePrecondition(req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+	err = s.todoService.DeleteTodo(ctx, todoId, cond)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
+
+func (s *httpService) listToDos(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	s.listToDoPage(w, req, params, false)
+}
+
+// listTrash lists the deleted todos of a user, with the same paging and filters as listToDos.
+func (s *httpService) listTrash(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	s.listToDoPage(w, req, params, true)
+}
+
+func (s *httpService) listToDoPage(w http.ResponseWriter, req *http.Request, params httprouter.Params, deleted bool) {
+	ctx := req.Context()
+	userID, err := strconv.ParseInt(params.ByName(UserIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	query := req.URL.Query()
+	limit, err := parseInt64Query(query, limitQuery)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	todoQuery, err := parseToDoQuery(query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	todoQuery.Deleted = deleted
+
+	// Offset paging is kept for clients that jump to arbitrary pages,
+	// everything else is paged by cursor.
+	if query.Get(offsetQuery) == "" {
+		s.listToDosByCursor(w, req, userID, todoQuery, limit)
+		return
+	}
+	if query.Get(cursorQuery) != "" {
+		writeError(w, badRequestf("offset and cursor cant be used together"))
+		return
+	}
+	offset, err := parseInt64Query(query, offsetQuery)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	page, err := s.todoService.ListToDos(ctx, userID, todoQuery, limit, offset)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if page.Offset+page.Limit < page.Total {
+		page.Next = offsetPageLink(req.URL, page.Limit, page.Offset+page.Limit)
+	}
+	if page.Offset > 0 {
+		prev := page.Offset - page.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		page.Prev = offsetPageLink(req.URL, page.Limit, prev)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(page)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+// parseToDoQuery reads the filters of a todo list: status=<status>[,<status>...],
+// due=overdue|today, due_within=<days>, tz=<IANA zone> for today, list=<list id>|inbox,
+// tags_any=<tag>[,<tag>...], tags_all=<tag>[,<tag>...], filter=<expression> (see parseFilter)
+// and sort=<field>[,<field>...] (see parseSort).
+func parseToDoQuery(query url.Values) (app.ToDoQuery, error) {
+	todoQuery := app.ToDoQuery{Due: query.Get(dueQuery), Sort: parseSort(query.Get(sortQuery))}
+	switch list := query.Get(listQuery); list {
+	case "":
+	case inboxList:
+		todoQuery.Inbox = true
+	default:
+		listID, err := parseInt64Query(query, listQuery)
+		if err != nil {
+			return todoQuery, err
+		}
+		todoQuery.ListID = &listID
+	}
+	if statuses := query.Get(statusQuery); statuses != "" {
+		todoQuery.Statuses = strings.Split(statuses, ",")
+	}
+	if tags := query.Get(anyTagsQuery); tags != "" {
+		todoQuery.AnyTags = strings.Split(tags, ",")
+	}
+	if tags := query.Get(allTagsQuery); tags != "" {
+		todoQuery.AllTags = strings.Split(tags, ",")
+	}
+	if query.Get(dueWithinQuery) != "" {
+		if todoQuery.Due != "" {
+			return todoQuery, badRequestf("%s and %s cant be used together", dueQuery, dueWithinQuery)
+		}
+		days, err := parseInt64Query(query, dueWithinQuery)
+		if err != nil {
+			return todoQuery, err
+		}
+		todoQuery.Due = app.DueWithin
+		todoQuery.DueWithinDays = int(days)
+	}
+	if filter := query.Get(filterQuery); filter != "" {
+		conditions, err := parseFilter(filter)
+		if err != nil {
+			return todoQuery, err
+		}
+		todoQuery.Conditions = conditions
+	}
+	if tz := query.Get(timezoneQuery); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return todoQuery, badRequestf("invalid %s: %q", timezoneQuery, tz)
+		}
+		todoQuery.Location = loc
+	}
+	return todoQuery, nil
+}
+
+// parseInt64Query returns zero when the query parameter is absent.
+func parseInt64Query(query url.Values, key string) (int64, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, badRequestf("invalid %s: %q", key, value)
+	}
+	return n, nil
+}
+
+func (s *httpService) listToDosByCursor(w http.ResponseWriter, req *http.Request, userID int64, todoQuery app.ToDoQuery, limit int64) {
+	page, err := s.todoService.ListToDosAfter(req.Context(), userID, todoQuery, limit, req.URL.Query().Get(cursorQuery))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if page.NextCursor != "" {
+		page.Next = cursorPageLink(req.URL, page.Limit, page.NextCursor)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(page)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+func offsetPageLink(u *url.URL, limit, offset int64) string {
+	query := u.Query()
+	query.Set(limitQuery, strconv.FormatInt(limit, 10))
+	query.Set(offsetQuery, strconv.FormatInt(offset, 10))
+	link := url.URL{Path: u.Path, RawQuery: query.Encode()}
+	return link.String()
+}
+
+func cursorPageLink(u *url.URL, limit int64, cursor string) string {
+	query := u.Query()
+	query.Set(limitQuery, strconv.FormatInt(limit, 10))
+	query.Set(cursorQuery, cursor)
+	link := url.URL{Path: u.Path, RawQuery: query.Encode()}
+	return link.String()
+}
+
+// moveToDo places the todo before or after another todo, see api.ToDoMove.
+func (s *httpService) moveToDo(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	todoID, err := strconv.ParseInt(params.ByName(ToDoIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	var move api.ToDoMove
+	if err := json.NewDecoder(req.Body).Decode(&move); err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	cond, err := parsePrecondition(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	todo, err := s.todoService.MoveToDo(req.Context(), todoID, move, cond)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(todo.Version))
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(todo)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+// updateSeries changes the whole series of a recurring todo, see api.SeriesUpdate.
+// PUT and PATCH on /todo/:todoid change only that occurrence.
+func (s *httpService) updateSeries(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	todoID, err := strconv.ParseInt(params.ByName(ToDoIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	var update api.SeriesUpdate
+	if err := json.NewDecoder(req.Body).Decode(&update); err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	todo, err := s.todoService.UpdateSeries(req.Context(), todoID, update)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(todo.Version))
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(todo)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+func (s *httpService) restoreToDo(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	todoID, err := strconv.ParseInt(params.ByName(ToDoIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	todo, err := s.todoService.RestoreToDo(req.Context(), todoID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(todo.Version))
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(todo)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+// transitionToDo returns the handler that moves a todo to the status to.
+func (s *httpService) transitionToDo(to string) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		ctx := req.Context()
+		todoID, err := strconv.ParseInt(params.ByName(ToDoIDParam), 10, 64)
+		if err != nil {
+			writeError(w, badRequest(err))
+			return
+		}
+
+		cond, err := parsePrecondition(req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		todo, err := s.todoService.TransitionToDo(ctx, todoID, to, cond)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("ETag", etag(todo.Version))
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(todo)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"to-do/api"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ListIDParam = "listid"
+
+	archivedQuery = "archived"
+)
+
+func (s *httpService) createList(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	ctx := req.Context()
+	var newList api.List
+	if err := json.NewDecoder(req.Body).Decode(&newList); err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	list, err := s.listService.CreateList(ctx, newList)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/lists/%d", list.ID))
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(list)
+	if err != nil {
+		log.Error("cant encode created list: ", err)
+	}
+}
+
+func (s *httpService) getList(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	listID, err := strconv.ParseInt(params.ByName(ListIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	list, err := s.listService.GetList(req.Context(), listID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(list)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+// listLists returns the lists of the authenticated user, archived=true includes the archived ones.
+func (s *httpService) listLists(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	var archived bool
+	if value := req.URL.Query().Get(archivedQuery); value != "" {
+		var err error
+		archived, err = strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, badRequestf("invalid %s: %q", archivedQuery, value))
+			return
+		}
+	}
+
+	lists, err := s.listService.ListLists(req.Context(), archived)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(lists)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+func (s *httpService) updateList(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	listID, err := strconv.ParseInt(params.ByName(ListIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	var newList api.List
+	if err := json.NewDecoder(req.Body).Decode(&newList); err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+	if newList.ID != 0 && newList.ID != listID {
+		writeError(w, api.Validationf("id %d in body does not match list %d", newList.ID, listID))
+		return
+	}
+	newList.ID = listID
+
+	list, err := s.listService.UpdateList(req.Context(), newList)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(list)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+func (s *httpService) deleteList(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	listID, err := strconv.ParseInt(params.ByName(ListIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	err = s.listService.DeleteList(req.Context(), listID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,122 @@
+// +build integration
+
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"to-do/api"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListLifecycle(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := newHttpTestService()
+	assert.NoError(err)
+
+	user := newTestUser(t, service, "Thorin")
+
+	authenticated := authenticatedAs(user)
+
+	tt := []struct {
+		name       string
+		list       api.List
+		statusCode int
+	}{
+		{name: "Create list. 201", list: api.List{Name: "Mines", Color: "#aa5500"}, statusCode: http.StatusCreated},
+		{name: "Duplicate name. 409", list: api.List{Name: "Mines"}, statusCode: http.StatusConflict},
+		{name: "Empty name. 422", list: api.List{}, statusCode: http.StatusUnprocessableEntity},
+		{name: "Invalid color. 422", list: api.List{Name: "Axes", Color: "red"}, statusCode: http.StatusUnprocessableEntity},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := json.Marshal(tc.list)
+			assert.NoError(err)
+			responseRecorder := httptest.NewRecorder()
+			service.createList(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, bytes.NewReader(body))), nil)
+			assert.Equal(tc.statusCode, responseRecorder.Code, tc.name)
+		})
+	}
+
+	responseRecorder := httptest.NewRecorder()
+	service.listLists(responseRecorder, authenticated(httptest.NewRequest(http.MethodGet, testURL, nil)), nil)
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+	lists := []api.List{}
+	assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&lists))
+	if !assert.Len(lists, 1) {
+		return
+	}
+	list := lists[0]
+	listParams := httprouter.Params{
+		httprouter.Param{
+			Key:   "listid",
+			Value: strconv.FormatInt(list.ID, 10),
+		}}
+
+	responseRecorder = httptest.NewRecorder()
+	service.getList(responseRecorder, withUser(httptest.NewRequest(http.MethodGet, testURL, nil), 1), listParams)
+	assert.Equal(http.StatusForbidden, responseRecorder.Code, "list of another user")
+
+	// Todos are created in the list and listed by it.
+	body, err := json.Marshal(api.ToDo{Message: "Count the orcs", ListID: &list.ID})
+	assert.NoError(err)
+	responseRecorder = httptest.NewRecorder()
+	service.createToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, bytes.NewReader(body))), nil)
+	assert.Equal(http.StatusCreated, responseRecorder.Code)
+	todo := api.ToDo{}
+	assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&todo))
+	assert.Equal(&list.ID, todo.ListID)
+
+	body, err = json.Marshal(api.ToDo{Message: "Polish the axe"})
+	assert.NoError(err)
+	responseRecorder = httptest.NewRecorder()
+	service.createToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, bytes.NewReader(body))), nil)
+	assert.Equal(http.StatusCreated, responseRecorder.Code)
+
+	listed := func(query string) []string {
+		responseRecorder := httptest.NewRecorder()
+		service.listToDos(responseRecorder, authenticated(httptest.NewRequest(http.MethodGet, testURL+"?list="+query, nil)), httprouter.Params{
+			httprouter.Param{Key: "userid", Value: strconv.FormatInt(user.ID, 10)},
+		})
+		assert.Equal(http.StatusOK, responseRecorder.Code)
+		page := api.ToDoPage{}
+		assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&page))
+		messages := []string{}
+		for _, todo := range page.ToDos {
+			messages = append(messages, todo.Message)
+		}
+		return messages
+	}
+	assert.Equal([]string{"Count the orcs"}, listed(strconv.FormatInt(list.ID, 10)))
+	assert.Equal([]string{"Polish the axe"}, listed("inbox"))
+
+	// Archived lists take no new todos.
+	body, err = json.Marshal(api.List{Name: "Mines", Archived: true})
+	assert.NoError(err)
+	responseRecorder = httptest.NewRecorder()
+	service.updateList(responseRecorder, authenticated(httptest.NewRequest(http.MethodPut, testURL, bytes.NewReader(body))), listParams)
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+
+	body, err = json.Marshal(api.ToDo{Message: "Dig deeper", ListID: &list.ID})
+	assert.NoError(err)
+	responseRecorder = httptest.NewRecorder()
+	service.createToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, bytes.NewReader(body))), nil)
+	assert.Equal(http.StatusUnprocessableEntity, responseRecorder.Code)
+
+	// Todos of a deleted list move to the inbox.
+	responseRecorder = httptest.NewRecorder()
+	service.deleteList(responseRecorder, authenticated(httptest.NewRequest(http.MethodDelete, testURL, nil)), listParams)
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+	assert.ElementsMatch([]string{"Count the orcs", "Polish the axe"}, listed("inbox"))
+
+	responseRecorder = httptest.NewRecorder()
+	service.getList(responseRecorder, authenticated(httptest.NewRequest(http.MethodGet, testURL, nil)), listParams)
+	assert.Equal(http.StatusNotFound, responseRecorder.Code)
+}
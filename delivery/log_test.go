@@ -0,0 +1,42 @@
+package delivery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogMiddleware(t *testing.T) {
+	assert := assert.New(t)
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	tt := []struct {
+		statusCode int
+		level      log.Level
+	}{
+		{statusCode: http.StatusOK, level: log.InfoLevel},
+		{statusCode: http.StatusCreated, level: log.InfoLevel},
+		{statusCode: http.StatusFailedDependency, level: log.InfoLevel},
+		{statusCode: http.StatusUnsupportedMediaType, level: log.InfoLevel},
+		{statusCode: http.StatusInternalServerError, level: log.ErrorLevel},
+		{statusCode: http.StatusServiceUnavailable, level: log.InfoLevel},
+		{statusCode: http.StatusTeapot, level: log.WarnLevel},
+	}
+
+	for _, tc := range tt {
+		handle := logMiddleware(func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+			w.WriteHeader(tc.statusCode)
+		})
+		hook.Reset()
+		handle(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/todo/1", nil), nil)
+		if assert.NotNil(hook.LastEntry(), http.StatusText(tc.statusCode)) {
+			assert.Equal(tc.level, hook.LastEntry().Level, http.StatusText(tc.statusCode))
+		}
+	}
+}
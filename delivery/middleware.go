@@ -2,7 +2,10 @@ package delivery
 
 import (
 	"net/http"
+	"strings"
 	"time"
+	"to-do/api"
+	"to-do/app"
 
 	"github.com/julienschmidt/httprouter"
 	log "github.com/sirupsen/logrus"
@@ -41,14 +44,56 @@ func logMiddleware(h httprouter.Handle) httprouter.Handle {
 		switch lrw.statusCode {
 		case http.StatusOK:
 			logger.Info("Success")
+		case http.StatusCreated:
+			logger.Info("Created")
 		case http.StatusBadRequest:
 			logger.Info("bad request")
+		case http.StatusUnauthorized:
+			logger.Info("Unauthorized")
+		case http.StatusForbidden:
+			logger.Info("Forbidden")
+		case http.StatusConflict:
+			logger.Info("Conflict")
+		case http.StatusUnprocessableEntity:
+			logger.Info("Validation failed")
+		case http.StatusUnsupportedMediaType:
+			logger.Info("Unsupported Media Type")
+		case http.StatusNotModified:
+			logger.Info("Not Modified")
+		case http.StatusPreconditionFailed:
+			logger.Info("Precondition Failed")
+		case http.StatusFailedDependency:
+			logger.Info("Failed Dependency")
 		case http.StatusNotFound:
 			logger.Info("Not Found")
 		case http.StatusInternalServerError:
 			logger.Error("Return an error")
+		case http.StatusServiceUnavailable:
+			logger.Info("Service Unavailable")
 		default:
 			logger.Warning("unhandle http status code")
 		}
 	}
 }
+
+// authMiddleware authenticates the bearer token of the request and puts the
+// authenticated user into the request context.
+func (s *httpService) authMiddleware(h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		header := r.Header.Get("Authorization")
+		const prefix = "Bearer "
+		if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, api.NewError(api.ErrUnauthorized, "missing bearer token"))
+			return
+		}
+
+		user, err := s.authService.Authenticate(r.Context(), header[len(prefix):])
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, err)
+			return
+		}
+		h(w, r.WithContext(app.WithUser(r.Context(), user)), ps)
+	}
+}
@@ -0,0 +1,97 @@
+// +build integration
+
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	"to-do/api"
+	"to-do/app"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func signedJWT(t *testing.T, key string, userID int64, expiresAt time.Time) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   strconv.FormatInt(userID, 10),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	})
+	signed, err := token.SignedString([]byte(key))
+	assert.NoError(t, err)
+	return signed
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := newHttpTestService()
+	assert.NoError(err)
+
+	apiToken, err := service.authService.CreateAPIToken(app.WithUser(context.Background(), &api.User{ID: 1}), "test")
+	assert.NoError(err)
+
+	tt := []struct {
+		name          string
+		authorization string
+		statusCode    int
+	}{
+		{
+			name:          "Valid jwt. 200",
+			authorization: "Bearer " + signedJWT(t, "changeme", 1, time.Now().Add(time.Hour)),
+			statusCode:    http.StatusOK,
+		},
+		{
+			name:          "Valid api token. 200",
+			authorization: "Bearer " + apiToken.Token,
+			statusCode:    http.StatusOK,
+		},
+		{
+			name:          "Expired jwt. 401",
+			authorization: "Bearer " + signedJWT(t, "changeme", 1, time.Now().Add(-time.Hour)),
+			statusCode:    http.StatusUnauthorized,
+		},
+		{
+			name:          "Jwt signed with another key. 401",
+			authorization: "Bearer " + signedJWT(t, "another key", 1, time.Now().Add(time.Hour)),
+			statusCode:    http.StatusUnauthorized,
+		},
+		{
+			name:          "Jwt of unknown user. 401",
+			authorization: "Bearer " + signedJWT(t, "changeme", 95, time.Now().Add(time.Hour)),
+			statusCode:    http.StatusUnauthorized,
+		},
+		{
+			name:          "Unknown api token. 401",
+			authorization: "Bearer " + app.APITokenPrefix + "unknown",
+			statusCode:    http.StatusUnauthorized,
+		},
+		{
+			name:       "Missing token. 401",
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, testURL, nil)
+			if tc.authorization != "" {
+				request.Header.Set("Authorization", tc.authorization)
+			}
+			responseRecorder := httptest.NewRecorder()
+
+			handle := service.authMiddleware(func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+				assert.Equal(int64(1), app.UserFromContext(req.Context()).ID, tc.name)
+				w.WriteHeader(http.StatusOK)
+			})
+			handle(responseRecorder, request, nil)
+
+			assert.Equal(tc.statusCode, responseRecorder.Code, tc.name)
+		})
+	}
+}
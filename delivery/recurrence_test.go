@@ -0,0 +1,95 @@
+// +build integration
+
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"to-do/api"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecurringTodo(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := newHttpTestService()
+	assert.NoError(err)
+
+	user := newTestUser(t, service, "Bilbo")
+
+	authenticated := authenticatedAs(user)
+	series := func(seriesID int64) []api.ToDo {
+		responseRecorder := httptest.NewRecorder()
+		service.listToDos(responseRecorder, authenticated(httptest.NewRequest(http.MethodGet, testURL, nil)), httprouter.Params{
+			httprouter.Param{Key: "userid", Value: strconv.FormatInt(user.ID, 10)},
+		})
+		assert.Equal(http.StatusOK, responseRecorder.Code)
+		page := api.ToDoPage{}
+		assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&page))
+		occurrences := []api.ToDo{}
+		for _, todo := range page.ToDos {
+			if todo.SeriesID != nil && *todo.SeriesID == seriesID {
+				occurrences = append(occurrences, todo)
+			}
+		}
+		return occurrences
+	}
+
+	due := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	body, err := json.Marshal(api.ToDo{Message: "Write the memoirs", DueAt: &due, Recurrence: "FREQ=WEEKLY"})
+	assert.NoError(err)
+	responseRecorder := httptest.NewRecorder()
+	service.createToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, bytes.NewReader(body))), nil)
+	assert.Equal(http.StatusCreated, responseRecorder.Code)
+	first := api.ToDo{}
+	assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&first))
+	if !assert.NotNil(first.SeriesID) {
+		return
+	}
+	assert.Equal(first.ID, *first.SeriesID)
+
+	// Completing an occurrence creates the next one, completing it again does not.
+	for i := 0; i < 2; i++ {
+		responseRecorder = httptest.NewRecorder()
+		service.toggleToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, nil)), todoParams(first.ID))
+		assert.Equal(http.StatusOK, responseRecorder.Code)
+		responseRecorder = httptest.NewRecorder()
+		service.transitionToDo(api.StatusDone)(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, nil)), todoParams(first.ID))
+	}
+	occurrences := series(first.ID)
+	if !assert.Len(occurrences, 2) {
+		return
+	}
+	next := occurrences[1]
+	assert.True(due.AddDate(0, 0, 7).Equal(*next.DueAt))
+	assert.Equal(api.StatusOpen, next.Status)
+
+	// An occurrence edit stays with the occurrence.
+	request := authenticated(httptest.NewRequest(http.MethodPatch, testURL, strings.NewReader(`{"message": "Write the last chapter"}`)))
+	request.Header.Set("Content-Type", "application/merge-patch+json")
+	responseRecorder = httptest.NewRecorder()
+	service.patchToDo(responseRecorder, request, todoParams(next.ID))
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+	assert.Equal("Write the memoirs", series(first.ID)[0].Message)
+
+	// A series edit changes the open occurrences only.
+	responseRecorder = httptest.NewRecorder()
+	service.updateSeries(responseRecorder, authenticated(httptest.NewRequest(http.MethodPatch, testURL, strings.NewReader(`{"message": "Write There and Back Again", "recurrence": "FREQ=MONTHLY"}`))), todoParams(next.ID))
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+	occurrences = series(first.ID)
+	assert.Equal("Write the memoirs", occurrences[0].Message)
+	assert.Equal("Write There and Back Again", occurrences[1].Message)
+	assert.Equal("FREQ=MONTHLY", occurrences[1].Recurrence)
+
+	responseRecorder = httptest.NewRecorder()
+	service.updateSeries(responseRecorder, authenticated(httptest.NewRequest(http.MethodPatch, testURL, strings.NewReader(`{"recurrence": "FREQ=SOMETIMES"}`))), todoParams(next.ID))
+	assert.Equal(http.StatusUnprocessableEntity, responseRecorder.Code)
+}
@@ -0,0 +1,51 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	searchQuery = "q"
+)
+
+// search returns the authenticated user's todos matching q=<query>, see app.ToDoService.Search
+// for the query syntax. Snippets mark the matches with <mark> and </mark>.
+func (s *httpService) search(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	query := req.URL.Query()
+	limit, err := parseInt64Query(query, limitQuery)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+	offset, err := parseInt64Query(query, offsetQuery)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	page, err := s.todoService.Search(req.Context(), query.Get(searchQuery), limit, offset)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if page.Offset+page.Limit < page.Total {
+		page.Next = offsetPageLink(req.URL, page.Limit, page.Offset+page.Limit)
+	}
+	if page.Offset > 0 {
+		prev := page.Offset - page.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		page.Prev = offsetPageLink(req.URL, page.Limit, prev)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(page)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
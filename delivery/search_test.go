@@ -0,0 +1,99 @@
+// +build integration
+
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"to-do/api"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := newHttpTestService()
+	assert.NoError(err)
+
+	user := newTestUser(t, service, "Rosie")
+	other := newTestUser(t, service, "Lobelia")
+
+	for _, todo := range []struct {
+		userID  int64
+		message string
+	}{
+		{user.ID, "Bake mushroom pie for the party"},
+		{user.ID, "Buy mushrooms at the market"},
+		{user.ID, "Sing in the green dragon"},
+		{other.ID, "Hide the silver mushroom spoons"},
+	} {
+		body, err := json.Marshal(api.ToDo{Message: todo.message})
+		assert.NoError(err)
+		responseRecorder := httptest.NewRecorder()
+		service.createToDo(responseRecorder, withUser(httptest.NewRequest(http.MethodPost, testURL, bytes.NewReader(body)), todo.userID), nil)
+		assert.Equal(http.StatusCreated, responseRecorder.Code)
+	}
+
+	search := func(q string, query url.Values) (int, api.SearchPage) {
+		if query == nil {
+			query = url.Values{}
+		}
+		query.Set("q", q)
+		responseRecorder := httptest.NewRecorder()
+		service.search(responseRecorder, withUser(httptest.NewRequest(http.MethodGet, testURL+"/search?"+query.Encode(), nil), user.ID), nil)
+		page := api.SearchPage{}
+		if responseRecorder.Code == http.StatusOK {
+			assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&page))
+		}
+		return responseRecorder.Code, page
+	}
+	messages := func(page api.SearchPage) []string {
+		messages := []string{}
+		for _, result := range page.Results {
+			messages = append(messages, result.ToDo.Message)
+		}
+		return messages
+	}
+
+	// Stemming matches "mushrooms" for "mushroom", other users' todos stay hidden.
+	code, page := search("mushroom", nil)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(int64(2), page.Total)
+	assert.ElementsMatch([]string{"Bake mushroom pie for the party", "Buy mushrooms at the market"}, messages(page))
+	for _, result := range page.Results {
+		assert.Contains(result.Snippet, "<mark>")
+		assert.True(result.Rank > 0)
+	}
+
+	code, page = search(`"mushroom pie"`, nil)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal([]string{"Bake mushroom pie for the party"}, messages(page))
+
+	code, page = search(`"pie mushroom"`, nil)
+	assert.Equal(http.StatusOK, code)
+	assert.Empty(page.Results)
+
+	code, page = search("drag*", nil)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal([]string{"Sing in the green dragon"}, messages(page))
+
+	code, page = search("mushroom market", nil)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal([]string{"Buy mushrooms at the market"}, messages(page))
+
+	code, page = search("mushroom", url.Values{"limit": {"1"}})
+	assert.Equal(http.StatusOK, code)
+	assert.Len(page.Results, 1)
+	assert.NotEmpty(page.Next)
+	assert.Empty(page.Prev)
+
+	code, _ = search("", nil)
+	assert.Equal(http.StatusUnprocessableEntity, code)
+	code, _ = search(`"unterminated`, nil)
+	assert.Equal(http.StatusUnprocessableEntity, code)
+}
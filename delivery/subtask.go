@@ -0,0 +1,89 @@
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"to-do/api"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+func (s *httpService) createSubtask(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	parentID, err := strconv.ParseInt(params.ByName(ToDoIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	var newTodo api.ToDo
+	if err := json.NewDecoder(req.Body).Decode(&newTodo); err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	todo, err := s.todoService.CreateSubtask(req.Context(), parentID, newTodo)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/todo/%d", todo.ID))
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(todo)
+	if err != nil {
+		log.Error("cant encode created subtask: ", err)
+	}
+}
+
+func (s *httpService) listSubtasks(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	parentID, err := strconv.ParseInt(params.ByName(ToDoIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	subtasks, err := s.todoService.ListSubtasks(req.Context(), parentID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(subtasks)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+// toggleToDo checks a todo off or on again.
+func (s *httpService) toggleToDo(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	todoID, err := strconv.ParseInt(params.ByName(ToDoIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	cond, err := parsePrecondition(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	todo, err := s.todoService.ToggleToDo(req.Context(), todoID, cond)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(todo.Version))
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(todo)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
@@ -0,0 +1,106 @@
+// +build integration
+
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"to-do/api"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubtasks(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := newHttpTestService()
+	assert.NoError(err)
+
+	user := newTestUser(t, service, "Pippin")
+
+	authenticated := authenticatedAs(user)
+	get := func(todoID int64) api.ToDo {
+		responseRecorder := httptest.NewRecorder()
+		service.getToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodGet, testURL, nil)), todoParams(todoID))
+		assert.Equal(http.StatusOK, responseRecorder.Code)
+		todo := api.ToDo{}
+		assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&todo))
+		return todo
+	}
+	subtasks := func(parentID int64) []string {
+		responseRecorder := httptest.NewRecorder()
+		service.listSubtasks(responseRecorder, authenticated(httptest.NewRequest(http.MethodGet, testURL, nil)), todoParams(parentID))
+		assert.Equal(http.StatusOK, responseRecorder.Code)
+		todos := []api.ToDo{}
+		assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&todos))
+		messages := []string{}
+		for _, todo := range todos {
+			messages = append(messages, todo.Message)
+		}
+		return messages
+	}
+
+	body, err := json.Marshal(api.ToDo{Message: "Prepare for the feast"})
+	assert.NoError(err)
+	responseRecorder := httptest.NewRecorder()
+	service.createToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, bytes.NewReader(body))), nil)
+	assert.Equal(http.StatusCreated, responseRecorder.Code)
+	parent := api.ToDo{}
+	assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&parent))
+	assert.Nil(parent.Progress)
+
+	steps := []api.ToDo{}
+	for _, message := range []string{"Bake bread", "Roast mushrooms", "Tap the ale"} {
+		body, err := json.Marshal(api.ToDo{Message: message})
+		assert.NoError(err)
+		responseRecorder := httptest.NewRecorder()
+		service.createSubtask(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, bytes.NewReader(body))), todoParams(parent.ID))
+		assert.Equal(http.StatusCreated, responseRecorder.Code)
+		step := api.ToDo{}
+		assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&step))
+		assert.Equal(&parent.ID, step.ParentID)
+		steps = append(steps, step)
+	}
+	assert.Equal(&api.Progress{Done: 0, Total: 3}, get(parent.ID).Progress)
+
+	responseRecorder = httptest.NewRecorder()
+	service.toggleToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, nil)), todoParams(steps[1].ID))
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+	assert.Equal(&api.Progress{Done: 1, Total: 3}, get(parent.ID).Progress)
+
+	// Subtasks are reordered among their siblings only.
+	responseRecorder = httptest.NewRecorder()
+	service.moveToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, strings.NewReader(fmt.Sprintf(`{"before": %d}`, steps[0].ID)))), todoParams(steps[2].ID))
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+	assert.Equal([]string{"Tap the ale", "Bake bread", "Roast mushrooms"}, subtasks(parent.ID))
+
+	responseRecorder = httptest.NewRecorder()
+	service.moveToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, strings.NewReader(fmt.Sprintf(`{"before": %d}`, parent.ID)))), todoParams(steps[2].ID))
+	assert.Equal(http.StatusUnprocessableEntity, responseRecorder.Code, "move before a todo with another parent")
+
+	// The parent can not become a subtask of its own subtask.
+	request := authenticated(httptest.NewRequest(http.MethodPatch, testURL, strings.NewReader(fmt.Sprintf(`{"parent_id": %d}`, steps[0].ID))))
+	request.Header.Set("Content-Type", "application/merge-patch+json")
+	responseRecorder = httptest.NewRecorder()
+	service.patchToDo(responseRecorder, request, todoParams(parent.ID))
+	assert.Equal(http.StatusUnprocessableEntity, responseRecorder.Code, "cycle")
+
+	// Subtasks go to the trash and come back with their parent.
+	responseRecorder = httptest.NewRecorder()
+	service.deleteToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodDelete, testURL, nil)), todoParams(parent.ID))
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+
+	responseRecorder = httptest.NewRecorder()
+	service.getToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodGet, testURL, nil)), todoParams(steps[0].ID))
+	assert.Equal(http.StatusNotFound, responseRecorder.Code)
+
+	responseRecorder = httptest.NewRecorder()
+	service.restoreToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, nil)), todoParams(parent.ID))
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+	assert.Equal([]string{"Tap the ale", "Bake bread", "Roast mushrooms"}, subtasks(parent.ID))
+}
@@ -0,0 +1,80 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"to-do/api"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	TagParam = "tag"
+)
+
+// addToDoTags labels the todo with the tags of an api.ToDoTags body.
+func (s *httpService) addToDoTags(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	todoID, err := strconv.ParseInt(params.ByName(ToDoIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	var tags api.ToDoTags
+	if err := json.NewDecoder(req.Body).Decode(&tags); err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	todo, err := s.todoService.AddTags(req.Context(), todoID, tags.Tags)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(todo.Version))
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(todo)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+func (s *httpService) removeToDoTag(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	todoID, err := strconv.ParseInt(params.ByName(ToDoIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	todo, err := s.todoService.RemoveTag(req.Context(), todoID, params.ByName(TagParam))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(todo.Version))
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(todo)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+func (s *httpService) listTags(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	tags, err := s.todoService.ListTags(req.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(tags)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
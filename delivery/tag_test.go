@@ -0,0 +1,100 @@
+// +build integration
+
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"to-do/api"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToDoTags(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := newHttpTestService()
+	assert.NoError(err)
+
+	user := newTestUser(t, service, "Merry")
+
+	authenticated := authenticatedAs(user)
+	todoParams := func(todo api.ToDo, tag string) httprouter.Params {
+		return httprouter.Params{
+			httprouter.Param{Key: "todoid", Value: strconv.FormatInt(todo.ID, 10)},
+			httprouter.Param{Key: "tag", Value: tag},
+		}
+	}
+
+	todos := map[string]api.ToDo{}
+	for message, tags := range map[string]string{
+		"Eat a second breakfast": `{"tags": ["food", "shire"]}`,
+		"Steal mushrooms":        `{"tags": ["food", "farmer maggot"]}`,
+		"Light fireworks":        `{"tags": ["shire", "food"]}`,
+	} {
+		body, err := json.Marshal(api.ToDo{Message: message})
+		assert.NoError(err)
+		responseRecorder := httptest.NewRecorder()
+		service.createToDo(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, bytes.NewReader(body))), nil)
+		assert.Equal(http.StatusCreated, responseRecorder.Code)
+		created := api.ToDo{}
+		assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&created))
+
+		responseRecorder = httptest.NewRecorder()
+		service.addToDoTags(responseRecorder, authenticated(httptest.NewRequest(http.MethodPost, testURL, strings.NewReader(tags))), todoParams(created, ""))
+		assert.Equal(http.StatusOK, responseRecorder.Code)
+		tagged := api.ToDo{}
+		assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&tagged))
+		assert.Equal(created.Version+1, tagged.Version)
+		todos[message] = tagged
+	}
+	assert.Equal([]string{"food", "shire"}, todos["Light fireworks"].Tags)
+
+	responseRecorder := httptest.NewRecorder()
+	service.removeToDoTag(responseRecorder, authenticated(httptest.NewRequest(http.MethodDelete, testURL, nil)), todoParams(todos["Light fireworks"], "food"))
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+
+	responseRecorder = httptest.NewRecorder()
+	service.removeToDoTag(responseRecorder, authenticated(httptest.NewRequest(http.MethodDelete, testURL, nil)), todoParams(todos["Light fireworks"], "food"))
+	assert.Equal(http.StatusNotFound, responseRecorder.Code, "remove missing tag")
+
+	responseRecorder = httptest.NewRecorder()
+	service.addToDoTags(responseRecorder, withUser(httptest.NewRequest(http.MethodPost, testURL, strings.NewReader(`{"tags": ["mine"]}`)), 1), todoParams(todos["Steal mushrooms"], ""))
+	assert.Equal(http.StatusForbidden, responseRecorder.Code, "tag todo of another user")
+
+	responseRecorder = httptest.NewRecorder()
+	service.listTags(responseRecorder, authenticated(httptest.NewRequest(http.MethodGet, testURL, nil)), nil)
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+	tags := []api.Tag{}
+	assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&tags))
+	assert.Equal([]api.Tag{
+		{Name: "farmer maggot", Count: 1},
+		{Name: "food", Count: 2},
+		{Name: "shire", Count: 2},
+	}, tags)
+
+	listed := func(query string) []string {
+		responseRecorder := httptest.NewRecorder()
+		service.listToDos(responseRecorder, authenticated(httptest.NewRequest(http.MethodGet, testURL+"?"+query, nil)), httprouter.Params{
+			httprouter.Param{Key: "userid", Value: strconv.FormatInt(user.ID, 10)},
+		})
+		assert.Equal(http.StatusOK, responseRecorder.Code)
+		page := api.ToDoPage{}
+		assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&page))
+		messages := []string{}
+		for _, todo := range page.ToDos {
+			messages = append(messages, todo.Message)
+		}
+		return messages
+	}
+	assert.ElementsMatch([]string{"Eat a second breakfast", "Light fireworks"}, listed("tags_any=shire"))
+	assert.ElementsMatch([]string{"Eat a second breakfast", "Steal mushrooms", "Light fireworks"}, listed("tags_any=shire,food"))
+	assert.ElementsMatch([]string{"Eat a second breakfast"}, listed("tags_all=shire,food"))
+	assert.Empty(listed("tags_all=shire,farmer%20maggot"))
+}
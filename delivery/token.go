@@ -0,0 +1,68 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"to-do/api"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	TokenIDParam = "tokenid"
+)
+
+func (s *httpService) createAPIToken(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	ctx := req.Context()
+	var newToken api.APIToken
+	if err := json.NewDecoder(req.Body).Decode(&newToken); err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	token, err := s.authService.CreateAPIToken(ctx, newToken.Name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(token)
+	if err != nil {
+		log.Error("cant encode created api token: ", err)
+	}
+}
+
+func (s *httpService) listAPITokens(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	tokens, err := s.authService.ListAPITokens(req.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(tokens)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+func (s *httpService) deleteAPIToken(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	tokenID, err := strconv.ParseInt(params.ByName(TokenIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	err = s.authService.DeleteAPIToken(req.Context(), tokenID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
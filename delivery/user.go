@@ -0,0 +1,135 @@
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"to-do/api"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+func (s *httpService) createUser(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	ctx := req.Context()
+	var newUser api.User
+	if err := json.NewDecoder(req.Body).Decode(&newUser); err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	user, err := s.userService.CreateUser(ctx, newUser)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/users/%d", user.ID))
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(user)
+	if err != nil {
+		log.Error("cant encode created user: ", err)
+	}
+}
+
+func (s *httpService) getUser(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	ctx := req.Context()
+	userID, err := strconv.ParseInt(params.ByName(UserIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	user, err := s.userService.GetUser(ctx, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+func (s *httpService) listUsers(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	ctx := req.Context()
+	query := req.URL.Query()
+	limit, err := parseInt64Query(query, limitQuery)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+	offset, err := parseInt64Query(query, offsetQuery)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	page, err := s.userService.ListUsers(ctx, limit, offset)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if page.Offset+page.Limit < page.Total {
+		page.Next = offsetPageLink(req.URL, page.Limit, page.Offset+page.Limit)
+	}
+	if page.Offset > 0 {
+		prev := page.Offset - page.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		page.Prev = offsetPageLink(req.URL, page.Limit, prev)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(page)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+func (s *httpService) renameUser(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	ctx := req.Context()
+	userID, err := strconv.ParseInt(params.ByName(UserIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	var user api.User
+	if err := json.NewDecoder(req.Body).Decode(&user); err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+	user.ID = userID
+
+	err = s.userService.RenameUser(ctx, user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *httpService) deleteUser(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	ctx := req.Context()
+	userID, err := strconv.ParseInt(params.ByName(UserIDParam), 10, 64)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+
+	err = s.userService.DeleteUser(ctx, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,137 @@
+// +build integration
+
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"to-do/api"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateUser(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := newHttpTestService()
+	assert.NoError(err)
+
+	tt := []struct {
+		name       string
+		user       api.User
+		statusCode int
+	}{
+		{
+			name:       "Create user. 201",
+			user:       api.User{Name: uniqueName("Gimli")},
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "Empty name. 422",
+			user:       api.User{Name: ""},
+			statusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Name already taken. 409",
+			user:       api.User{Name: "Legolas"},
+			statusCode: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := json.Marshal(tc.user)
+			assert.NoError(err)
+			request := httptest.NewRequest(http.MethodPost, testURL+"/users", bytes.NewReader(body))
+			responseRecorder := httptest.NewRecorder()
+
+			service.createUser(responseRecorder, request, httprouter.Params{})
+
+			assert.Equal(tc.statusCode, responseRecorder.Code, tc.name)
+			if tc.statusCode != http.StatusCreated {
+				return
+			}
+			created := api.User{}
+			err = json.NewDecoder(responseRecorder.Body).Decode(&created)
+			assert.NoError(err)
+			assert.NotZero(created.ID, tc.name)
+			assert.Equal(tc.user.Name, created.Name, tc.name)
+		})
+	}
+}
+
+func TestUserLifecycle(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := newHttpTestService()
+	assert.NoError(err)
+
+	user := newTestUser(t, service, "Boromir")
+
+	authenticated := authenticatedAs(user)
+	params := httprouter.Params{
+		httprouter.Param{
+			Key:   "userid",
+			Value: strconv.FormatInt(user.ID, 10),
+		}}
+
+	newName := uniqueName("Faramir")
+	body, err := json.Marshal(api.User{Name: newName})
+	assert.NoError(err)
+	responseRecorder := httptest.NewRecorder()
+	service.renameUser(responseRecorder, authenticated(httptest.NewRequest(http.MethodPut, testURL, bytes.NewReader(body))), params)
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+
+	responseRecorder = httptest.NewRecorder()
+	service.getUser(responseRecorder, authenticated(httptest.NewRequest(http.MethodGet, testURL, nil)), params)
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+	renamed := api.User{}
+	assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&renamed))
+	assert.Equal(newName, renamed.Name)
+
+	responseRecorder = httptest.NewRecorder()
+	service.deleteUser(responseRecorder, authenticated(httptest.NewRequest(http.MethodDelete, testURL, nil)), params)
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+
+	responseRecorder = httptest.NewRecorder()
+	service.getUser(responseRecorder, authenticated(httptest.NewRequest(http.MethodGet, testURL, nil)), params)
+	assert.Equal(http.StatusNotFound, responseRecorder.Code)
+}
+
+func TestListUsers(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := newHttpTestService()
+	assert.NoError(err)
+
+	first := newTestUser(t, service, "Elrond")
+	second := newTestUser(t, service, "Arwen")
+
+	// A user sees only itself, the names of the others are not disclosed.
+	responseRecorder := httptest.NewRecorder()
+	service.listUsers(responseRecorder, withUser(httptest.NewRequest(http.MethodGet, testURL+"/users", nil), second.ID), nil)
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+	page := api.UserPage{}
+	assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&page))
+	assert.Equal([]api.User{second}, page.Users)
+	assert.Equal(int64(1), page.Total)
+	for _, user := range page.Users {
+		assert.NotEqual(first.ID, user.ID)
+	}
+
+	responseRecorder = httptest.NewRecorder()
+	service.listUsers(responseRecorder, withUser(httptest.NewRequest(http.MethodGet, testURL+"/users?offset=1", nil), second.ID), nil)
+	assert.Equal(http.StatusOK, responseRecorder.Code)
+	page = api.UserPage{}
+	assert.NoError(json.NewDecoder(responseRecorder.Body).Decode(&page))
+	assert.Empty(page.Users)
+
+	responseRecorder = httptest.NewRecorder()
+	service.listUsers(responseRecorder, httptest.NewRequest(http.MethodGet, testURL+"/users", nil), nil)
+	assert.Equal(http.StatusUnauthorized, responseRecorder.Code)
+}
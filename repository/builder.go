@@ -2,26 +2,201 @@ package repository
 
 import (
 	"context"
+	"time"
 	"to-do/api"
 )
 
+// ToDoFilter selects todos of a list, zero fields select everything.
+type ToDoFilter struct {
+	// DueFrom and DueTo limit due_at to [DueFrom, DueTo), the other ranges work the same way.
+	DueFrom       *time.Time
+	DueTo         *time.Time
+	CreatedFrom   *time.Time
+	CreatedTo     *time.Time
+	UpdatedFrom   *time.Time
+	UpdatedTo     *time.Time
+	CompletedFrom *time.Time
+	CompletedTo   *time.Time
+	// Statuses limits the todos to the given statuses.
+	Statuses []string
+	// Deleted selects the todos in the trash instead of the live ones.
+	Deleted bool
+	// ListID limits the todos to a list, Inbox to the todos without a list.
+	ListID *int64
+	Inbox  bool
+	// SeriesID limits the todos to the occurrences of a recurring todo.
+	SeriesID *int64
+	// AnyTags limits the todos to those with at least one of the tags, AllTags to those with every tag.
+	AnyTags []string
+	AllTags []string
+	// Priorities limits the todos to the given priorities.
+	Priorities []string
+	// Search limits the todos to those whose message matches all terms.
+	Search []SearchTerm
+	// Order is one of the Order constants, OrderCreated when empty.
+	Order string
+	// Sort replaces Order when set, ties are broken by id.
+	Sort []SortKey
+}
+
+// Fields of SortKey, named like the fields of a filter expression.
+const (
+	SortCreated   = "created"
+	SortUpdated   = "updated"
+	SortDue       = "due"
+	SortCompleted = "completed"
+	SortPriority  = "priority"
+	SortPosition  = "position"
+)
+
+// SortKey orders the todos by one of the Sort fields, todos without a value come last
+// in both directions.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// SearchTerm is a part of a full-text search, all terms of a search must match.
+type SearchTerm struct {
+	Text string
+	// Phrase matches the words of Text next to each other.
+	Phrase bool
+	// Prefix matches the words that start with Text, Text is a single word then.
+	Prefix bool
+}
+
+// Orders of the todo lists.
+const (
+	// OrderCreated sorts by (created_at, id).
+	OrderCreated = "created"
+	// OrderPosition sorts by (position, id).
+	OrderPosition = "position"
+	// OrderPriority sorts by priority from the most urgent, then like OrderPosition.
+	OrderPriority = "priority"
+)
+
+// ToDoKey is the place of a todo in the order of a list, only the fields of the order are used.
+type ToDoKey struct {
+	CreatedAt   time.Time
+	ID          int64
+	Position    string
+	Priority    string
+	UpdatedAt   time.Time
+	DueAt       *time.Time
+	CompletedAt *time.Time
+}
+
 type TODOStorage interface {
-	CreateToDo(ctx context.Context, todo api.ToDo) error
-	UpdateToDo(ctx context.Context, todo api.ToDo) error
-	DeleteToDo(ctx context.Context, todoID int64) error
+	CreateToDo(ctx context.Context, todo api.ToDo) (*api.ToDo, error)
+	// UpdateToDo replaces all mutable fields of the todo.
+	UpdateToDo(ctx context.Context, todo api.ToDo, cond api.Precondition) (*api.ToDo, error)
+	// PatchToDo changes only the fields set in update.
+	PatchToDo(ctx context.Context, todoID int64, update api.ToDoUpdate, cond api.Precondition) (*api.ToDo, error)
+	// DeleteToDo moves the todo and its subtasks to the trash, deleted todos are invisible to every
+	// other method except RestoreToDo, PurgeToDos and the lists with ToDoFilter.Deleted.
+	DeleteToDo(ctx context.Context, todoID int64, cond api.Precondition) error
+	// RestoreToDo moves the user's todo out of the trash together with the subtasks deleted with it.
+	// A todo whose parent is still in the trash becomes a top-level todo.
+	RestoreToDo(ctx context.Context, userID, todoID int64) (*api.ToDo, error)
+	// PurgeToDos permanently removes todos deleted before deletedBefore and returns their number.
+	PurgeToDos(ctx context.Context, deletedBefore time.Time) (int64, error)
 	GetToDo(ctx context.Context, todoID int64) (*api.ToDo, error)
+	GetToDos(ctx context.Context, userID int64, filter ToDoFilter, limit, offset int64) ([]api.ToDo, error)
+	CountToDos(ctx context.Context, userID int64, filter ToDoFilter) (int64, error)
+	// GetToDosAfter returns up to limit todos that follow the given key, or the first ones when after is nil.
+	GetToDosAfter(ctx context.Context, userID int64, filter ToDoFilter, after *ToDoKey, limit int64) ([]api.ToDo, error)
+	// LastPosition returns the greatest position of the user's todos, empty when the user has none.
+	LastPosition(ctx context.Context, userID int64) (string, error)
+	// AdjacentPosition returns the position of the user's todo nearest to position, before or after it,
+	// empty when there is none.
+	AdjacentPosition(ctx context.Context, userID int64, position string, before bool) (string, error)
+
+	// AddToDoTags labels the todo with tags, creating the user's missing tags.
+	AddToDoTags(ctx context.Context, todoID int64, tags []string) (*api.ToDo, error)
+	// RemoveToDoTag removes the tag from the todo, unused tags are deleted.
+	RemoveToDoTag(ctx context.Context, todoID int64, tag string) (*api.ToDo, error)
+	// GetTags returns the user's tags ordered by name.
+	GetTags(ctx context.Context, userID int64) ([]api.Tag, error)
+
+	// SearchToDos returns the user's live todos that match all terms, the best matches first,
+	// and the number of all matching todos.
+	SearchToDos(ctx context.Context, userID int64, terms []SearchTerm, limit, offset int64) ([]api.SearchResult, int64, error)
+
+	// UpdateSeries changes the open occurrences of the series and returns their number.
+	UpdateSeries(ctx context.Context, seriesID int64, update api.ToDoUpdate) (int64, error)
+
+	// GetSubtasks returns the direct subtasks of the todo ordered by position.
+	GetSubtasks(ctx context.Context, parentID int64) ([]api.ToDo, error)
+	// GetAncestorIDs returns the parent of the todo, the parent of the parent and so on.
+	GetAncestorIDs(ctx context.Context, todoID int64) ([]int64, error)
 }
 
 type UserStorage interface {
+	CreateUser(ctx context.Context, user api.User) (*api.User, error)
 	GetUser(ctx context.Context, id int64) (*api.User, error)
+	GetUsers(ctx context.Context, limit, offset int64) ([]api.User, error)
+	CountUsers(ctx context.Context) (int64, error)
+	UpdateUser(ctx context.Context, user api.User) error
+	// DeleteUser removes the user together with all of the user's todos.
+	DeleteUser(ctx context.Context, id int64) error
+}
+
+type ListStorage interface {
+	CreateList(ctx context.Context, list api.List) (*api.List, error)
+	GetList(ctx context.Context, listID int64) (*api.List, error)
+	// GetLists returns the user's lists ordered by name, archived ones only when archived is set.
+	GetLists(ctx context.Context, userID int64, archived bool) ([]api.List, error)
+	// UpdateList changes the name, color and archived flag of the list.
+	UpdateList(ctx context.Context, list api.List) (*api.List, error)
+	// DeleteList removes the list, its todos move to the inbox.
+	DeleteList(ctx context.Context, listID int64) error
 }
 
+// TokenStorage keeps API tokens. Only a hash of the token secret is stored.
+type TokenStorage interface {
+	CreateAPIToken(ctx context.Context, token api.APIToken, hash []byte) (*api.APIToken, error)
+	GetAPITokens(ctx context.Context, userID int64) ([]api.APIToken, error)
+	// GetAPITokenUser returns the owner of the token with the given hash.
+	GetAPITokenUser(ctx context.Context, hash []byte) (*api.User, error)
+	DeleteAPIToken(ctx context.Context, userID, tokenID int64) error
+}
+
+// TxStorage runs several changes in one transaction.
+type TxStorage interface {
+	// WithTx calls fn with a Storage bound to a serializable transaction, which is committed
+	// when fn succeeds and rolled back otherwise. The transaction is retried a few times when it
+	// conflicts with concurrent transactions, so fn must not have other side effects.
+	// WithTx of the bound Storage calls fn in the same transaction.
+	WithTx(ctx context.Context, fn func(Storage) error) error
+	// Batch runs ops in order with a Storage bound to a single transaction and returns their errors.
+	// A failed operation is undone alone and the others are committed. With atomic the first
+	// failure rolls back the whole batch instead and the operations after it are not run.
+	Batch(ctx context.Context, ops []func(Storage) error, atomic bool) ([]error, error)
+}
+
+// Storage methods report missing records with api.ErrNotFound, duplicates
+// with api.ErrConflict and references to missing records with api.ErrValidation.
+// Changes with a precondition that does not hold fail with api.ErrPreconditionFailed.
 type Storage interface {
 	UserStorage
 	TODOStorage
+	ListStorage
+	TokenStorage
+	TxStorage
 }
 
 func NewDBClient(ctx context.Context, cfg StorageConfig) (Storage, error) {
+	switch cfg.Driver {
+	case DriverMemory:
+		return newMemDatabase(), nil
+	case DriverSQLite:
+		db := sqliteDatabase{cfg: cfg}
+		if err := db.initializeDatabase(ctx); err != nil {
+			return nil, err
+		}
+		return &db, nil
+	}
+
 	db := pgDatabase{
 		cgf: cfg,
 	}
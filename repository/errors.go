@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"to-do/api"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"modernc.org/sqlite"
+)
+
+// PostgreSQL error codes, https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgForeignKeyViolation  = "23503"
+	pgUniqueViolation      = "23505"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+// SQLite extended result codes, https://www.sqlite.org/rescode.html
+const (
+	sqliteConstraintForeignKey = 787
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+)
+
+// translateError turns constraint violations into domain errors,
+// any other error is wrapped with msg.
+func translateError(err error, msg string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case pgForeignKeyViolation:
+			return api.Validationf("%s: %s", msg, pqErr.Detail)
+		case pgUniqueViolation:
+			return api.Conflictf("%s: %s", msg, pqErr.Detail)
+		}
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// SQLite names only the constraint, the message ends with the code.
+		detail := strings.TrimSuffix(sqliteErr.Error(), fmt.Sprintf(" (%d)", sqliteErr.Code()))
+		switch sqliteErr.Code() {
+		case sqliteConstraintForeignKey:
+			return api.Validationf("%s: %s", msg, detail)
+		case sqliteConstraintUnique, sqliteConstraintPrimaryKey:
+			return api.Conflictf("%s: %s", msg, detail)
+		}
+	}
+	return errors.Wrap(err, msg)
+}
+
+// isSerializationFailure reports whether err is a conflict with a concurrent transaction
+// that goes away when the transaction is retried.
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == pgSerializationFailure || pqErr.Code == pgDeadlockDetected
+}
@@ -0,0 +1,1381 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+	"to-do/api"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// DriverMemory keeps the data in the memory of the process, it is lost on exit.
+const DriverMemory = "memory"
+
+// memDatabase is a Storage that behaves like pgDatabase without a database:
+// ids come from sequences, timestamps are set on changes and references are checked
+// like the foreign keys of the schema.
+type memDatabase struct {
+	store *memStore
+	// data is set for the storage of WithTx, it is the copy of the store's data that WithTx commits.
+	data *memData
+}
+
+// memStore serializes the access to the data, a transaction holds the lock until it ends.
+type memStore struct {
+	mu   sync.Mutex
+	data *memData
+	// seq holds the last ids, like the sequences of Postgres they are not rolled back.
+	seq struct {
+		users, todos, lists, tags, tokens int64
+	}
+}
+
+type memData struct {
+	users map[int64]api.User
+	// todos hold the stored series_id in SeriesID and no Tags or Progress.
+	todos  map[int64]api.ToDo
+	lists  map[int64]api.List
+	tags   map[int64]memTag
+	tokens map[int64]memToken
+	// todoTags holds the tag ids of every todo.
+	todoTags map[int64]map[int64]bool
+}
+
+type memTag struct {
+	ID     int64
+	UserID int64
+	Name   string
+}
+
+type memToken struct {
+	api.APIToken
+	Hash []byte
+}
+
+func newMemDatabase() *memDatabase {
+	log.Info("Using the in-memory storage, the data is lost on exit.")
+	return &memDatabase{store: &memStore{data: &memData{
+		users:    map[int64]api.User{},
+		todos:    map[int64]api.ToDo{},
+		lists:    map[int64]api.List{},
+		tags:     map[int64]memTag{},
+		tokens:   map[int64]memToken{},
+		todoTags: map[int64]map[int64]bool{},
+	}}}
+}
+
+func (d *memData) clone() *memData {
+	c := &memData{
+		users:    make(map[int64]api.User, len(d.users)),
+		todos:    make(map[int64]api.ToDo, len(d.todos)),
+		lists:    make(map[int64]api.List, len(d.lists)),
+		tags:     make(map[int64]memTag, len(d.tags)),
+		tokens:   make(map[int64]memToken, len(d.tokens)),
+		todoTags: make(map[int64]map[int64]bool, len(d.todoTags)),
+	}
+	for id, user := range d.users {
+		c.users[id] = user
+	}
+	// The time pointers of the todos are never changed in place, so the copies share them.
+	for id, todo := range d.todos {
+		c.todos[id] = todo
+	}
+	for id, list := range d.lists {
+		c.lists[id] = list
+	}
+	for id, tag := range d.tags {
+		c.tags[id] = tag
+	}
+	for id, token := range d.tokens {
+		c.tokens[id] = token
+	}
+	for id, tags := range d.todoTags {
+		c.todoTags[id] = make(map[int64]bool, len(tags))
+		for tagID := range tags {
+			c.todoTags[id][tagID] = true
+		}
+	}
+	return c
+}
+
+// access runs fn with the data. The storage of WithTx holds the lock already, a failed change
+// without a transaction must fail before it changes the data.
+func (m *memDatabase) access(fn func(d *memData) error) error {
+	if m.data != nil {
+		return fn(m.data)
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	return fn(m.store.data)
+}
+
+// memNow returns the current time with the precision of Postgres.
+func memNow() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// WithTx runs fn on a copy of the data, which replaces the data when fn succeeds.
+// Transactions hold the lock of the store, so they never conflict.
+func (m *memDatabase) WithTx(ctx context.Context, fn func(Storage) error) error {
+	if m.data != nil {
+		return fn(m)
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data := m.store.data.clone()
+	if err := fn(&memDatabase{store: m.store, data: data}); err != nil {
+		return err
+	}
+	m.store.data = data
+	return nil
+}
+
+func (m *memDatabase) Batch(ctx context.Context, ops []func(Storage) error, atomic bool) ([]error, error) {
+	if m.data != nil {
+		return nil, errors.New("batches cant run in a transaction")
+	}
+	var errs []error
+	err := m.WithTx(ctx, func(storage Storage) error {
+		tx := storage.(*memDatabase)
+		errs = make([]error, len(ops))
+		for i, op := range ops {
+			if atomic {
+				if errs[i] = op(storage); errs[i] != nil {
+					return errBatchFailed
+				}
+				continue
+			}
+
+			// The copy undoes only the failed operation, like the savepoint of pgDatabase.
+			savepoint := tx.data.clone()
+			if errs[i] = op(storage); errs[i] != nil {
+				*tx.data = *savepoint
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchFailed) {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// missingKey is the error of a reference to a missing record, it has the detail of the Postgres error.
+func missingKey(msg, column string, id int64, table string) error {
+	return api.Validationf("%s: Key (%s)=(%d) is not present in table \"%s\".", msg, column, id, table)
+}
+
+// checkToDoReferences checks the foreign keys of a todo.
+func (d *memData) checkToDoReferences(msg string, todo api.ToDo) error {
+	if _, ok := d.users[todo.UserID]; !ok {
+		return missingKey(msg, "user_id", todo.UserID, "users")
+	}
+	if todo.ListID != nil {
+		if _, ok := d.lists[*todo.ListID]; !ok {
+			return missingKey(msg, "list_id", *todo.ListID, "lists")
+		}
+	}
+	if todo.ParentID != nil {
+		if _, ok := d.todos[*todo.ParentID]; !ok {
+			return missingKey(msg, "parent_id", *todo.ParentID, "todo_list")
+		}
+	}
+	switch todo.Status {
+	case api.StatusOpen, api.StatusInProgress, api.StatusDone, api.StatusCancelled:
+	default:
+		return errors.Errorf("%s: unknown status %q", msg, todo.Status)
+	}
+	return nil
+}
+
+// toDo returns a copy of the stored todo with the columns computed by toDoColumns.
+func (d *memData) toDo(row api.ToDo) *api.ToDo {
+	todo := row
+	todo.DueAt = copyTime(row.DueAt)
+	todo.ReminderAt = copyTime(row.ReminderAt)
+	todo.CompletedAt = copyTime(row.CompletedAt)
+	todo.DeletedAt = copyTime(row.DeletedAt)
+	todo.SeriesStart = copyTime(row.SeriesStart)
+	todo.ListID = copyID(row.ListID)
+	todo.ParentID = copyID(row.ParentID)
+
+	todo.Tags = []string{}
+	for tagID := range d.todoTags[row.ID] {
+		todo.Tags = append(todo.Tags, d.tags[tagID].Name)
+	}
+	sort.Strings(todo.Tags)
+
+	var done, total int64
+	for _, s := range d.todos {
+		if s.ParentID == nil || *s.ParentID != row.ID || s.DeletedAt != nil {
+			continue
+		}
+		if s.Status == api.StatusDone {
+			done++
+		}
+		if s.Status != api.StatusCancelled {
+			total++
+		}
+	}
+	todo.Progress = nil
+	if total > 0 {
+		todo.Progress = &api.Progress{Done: done, Total: total}
+	}
+
+	todo.SeriesID = nil
+	if row.Recurrence != "" {
+		seriesID := row.ID
+		if row.SeriesID != nil {
+			seriesID = *row.SeriesID
+		}
+		todo.SeriesID = &seriesID
+	}
+	return &todo
+}
+
+func (d *memData) toDos(rows []api.ToDo) []api.ToDo {
+	todos := make([]api.ToDo, 0, len(rows))
+	for _, row := range rows {
+		todos = append(todos, *d.toDo(row))
+	}
+	return todos
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func copyID(id *int64) *int64 {
+	if id == nil {
+		return nil
+	}
+	c := *id
+	return &c
+}
+
+// liveToDo returns the todo with the version matching cond, the error of missingOrChanged otherwise.
+func (d *memData) liveToDo(todoID int64, cond api.Precondition) (api.ToDo, error) {
+	row, ok := d.todos[todoID]
+	if !ok || row.DeletedAt != nil {
+		return row, api.NotFoundf("todo %d not found", todoID)
+	}
+	if !matchesPrecondition(cond, row.Version) {
+		return row, api.NewError(api.ErrPreconditionFailed, "todo %d has version %d", todoID, row.Version)
+	}
+	return row, nil
+}
+
+// changed bumps the version of the todo and sets updated_at like the update_time trigger.
+func changed(row api.ToDo, now time.Time) api.ToDo {
+	row.Version++
+	row.UpdatedAt = now
+	return row
+}
+
+func (m *memDatabase) CreateToDo(ctx context.Context, todo api.ToDo) (*api.ToDo, error) {
+	if _, err := priorityRank(todo.Priority); err != nil {
+		return nil, err
+	}
+	var created *api.ToDo
+	err := m.access(func(d *memData) error {
+		now := memNow()
+		row := api.ToDo{
+			UserID:      todo.UserID,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Message:     todo.Message,
+			Version:     1,
+			DueAt:       copyTime(todo.DueAt),
+			ReminderAt:  copyTime(todo.ReminderAt),
+			Status:      api.StatusOpen,
+			Priority:    todo.Priority,
+			Position:    todo.Position,
+			ListID:      copyID(todo.ListID),
+			ParentID:    copyID(todo.ParentID),
+			Recurrence:  todo.Recurrence,
+			SeriesID:    copyID(todo.SeriesID),
+			SeriesStart: copyTime(todo.SeriesStart),
+		}
+		if err := d.checkToDoReferences("insert todo to database", row); err != nil {
+			return err
+		}
+		m.store.seq.todos++
+		row.ID = m.store.seq.todos
+		d.todos[row.ID] = row
+		created = d.toDo(row)
+		return nil
+	})
+	return created, err
+}
+
+func (m *memDatabase) UpdateToDo(ctx context.Context, todo api.ToDo, cond api.Precondition) (*api.ToDo, error) {
+	if _, err := priorityRank(todo.Priority); err != nil {
+		return nil, err
+	}
+	var updated *api.ToDo
+	err := m.access(func(d *memData) error {
+		row, err := d.liveToDo(todo.ID, cond)
+		if err != nil {
+			return err
+		}
+		row.Message = todo.Message
+		row.DueAt = copyTime(todo.DueAt)
+		row.ReminderAt = copyTime(todo.ReminderAt)
+		row.Priority = todo.Priority
+		row.ListID = copyID(todo.ListID)
+		row.ParentID = copyID(todo.ParentID)
+		if err := d.checkToDoReferences("update todo in database", row); err != nil {
+			return err
+		}
+		row = changed(row, memNow())
+		d.todos[row.ID] = row
+		updated = d.toDo(row)
+		return nil
+	})
+	return updated, err
+}
+
+func (m *memDatabase) PatchToDo(ctx context.Context, todoID int64, update api.ToDoUpdate, cond api.Precondition) (*api.ToDo, error) {
+	if err := checkUpdate(update); err != nil {
+		return nil, err
+	}
+	if isEmptyUpdate(update) {
+		todo, err := m.GetToDo(ctx, todoID)
+		if err != nil {
+			return nil, err
+		}
+		return unchangedToDo(todo, cond)
+	}
+	var patched *api.ToDo
+	err := m.access(func(d *memData) error {
+		row, err := d.liveToDo(todoID, cond)
+		if err != nil {
+			return err
+		}
+		row = applyUpdate(row, update)
+		if err := d.checkToDoReferences("patch todo in database", row); err != nil {
+			return err
+		}
+		row = changed(row, memNow())
+		d.todos[row.ID] = row
+		patched = d.toDo(row)
+		return nil
+	})
+	return patched, err
+}
+
+func (m *memDatabase) UpdateSeries(ctx context.Context, seriesID int64, update api.ToDoUpdate) (int64, error) {
+	if err := checkUpdate(update); err != nil {
+		return 0, err
+	}
+	if isEmptyUpdate(update) {
+		return 0, nil
+	}
+	var count int64
+	err := m.access(func(d *memData) error {
+		rows := []api.ToDo{}
+		for _, row := range d.todos {
+			if row.Recurrence == "" || row.DeletedAt != nil || seriesOf(row) != seriesID ||
+				(row.Status != api.StatusOpen && row.Status != api.StatusInProgress) {
+				continue
+			}
+			row = applyUpdate(row, update)
+			if err := d.checkToDoReferences("update series in database", row); err != nil {
+				return err
+			}
+			rows = append(rows, row)
+		}
+		now := memNow()
+		for _, row := range rows {
+			d.todos[row.ID] = changed(row, now)
+		}
+		count = int64(len(rows))
+		return nil
+	})
+	return count, err
+}
+
+// seriesOf is coalesce(series_id, id).
+func seriesOf(row api.ToDo) int64 {
+	if row.SeriesID != nil {
+		return *row.SeriesID
+	}
+	return row.ID
+}
+
+// checkUpdate fails for the values updateSetSQL rejects.
+func checkUpdate(update api.ToDoUpdate) error {
+	if update.Priority != nil {
+		if _, err := priorityRank(*update.Priority); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isEmptyUpdate(update api.ToDoUpdate) bool {
+	return update == api.ToDoUpdate{}
+}
+
+// applyUpdate sets the fields of updateSetSQL.
+func applyUpdate(row api.ToDo, update api.ToDoUpdate) api.ToDo {
+	if update.Message != nil {
+		row.Message = *update.Message
+	}
+	if update.DueAt != nil {
+		row.DueAt = copyTime(update.DueAt.Time)
+	}
+	if update.ReminderAt != nil {
+		row.ReminderAt = copyTime(update.ReminderAt.Time)
+	}
+	if update.Priority != nil {
+		row.Priority = *update.Priority
+	}
+	if update.ListID != nil {
+		row.ListID = copyID(update.ListID.ID)
+	}
+	if update.ParentID != nil {
+		row.ParentID = copyID(update.ParentID.ID)
+	}
+	if update.Status != nil {
+		row.Status = *update.Status
+	}
+	if update.CompletedAt != nil {
+		row.CompletedAt = copyTime(update.CompletedAt.Time)
+	}
+	if update.Position != nil {
+		row.Position = *update.Position
+	}
+	if update.Recurrence != nil {
+		row.Recurrence = *update.Recurrence
+	}
+	if update.SeriesStart != nil {
+		row.SeriesStart = copyTime(update.SeriesStart.Time)
+	}
+	return row
+}
+
+func (m *memDatabase) DeleteToDo(ctx context.Context, todoID int64, cond api.Precondition) error {
+	return m.access(func(d *memData) error {
+		row, err := d.liveToDo(todoID, cond)
+		if err != nil {
+			return err
+		}
+		now := memNow()
+		// Subtasks go to the trash with the same deleted_at, so they can be restored together.
+		subtasks := d.subtaskIDs(todoID, nil)
+		row = changed(row, now)
+		row.DeletedAt = &now
+		d.todos[todoID] = row
+		for _, id := range subtasks {
+			subtask := changed(d.todos[id], now)
+			subtask.DeletedAt = &now
+			d.todos[id] = subtask
+		}
+		return nil
+	})
+}
+
+// subtaskIDs returns the subtasks of the todo at any depth that have deleted_at equal to deletedAt,
+// or are live when deletedAt is nil, like subtasksQuery.
+func (d *memData) subtaskIDs(parentID int64, deletedAt *time.Time) []int64 {
+	sameDeletedAt := func(t *time.Time) bool {
+		if t == nil || deletedAt == nil {
+			return t == nil && deletedAt == nil
+		}
+		return t.Equal(*deletedAt)
+	}
+
+	ids := []int64{}
+	seen := map[int64]bool{}
+	parents := []int64{parentID}
+	for len(parents) > 0 {
+		next := []int64{}
+		for _, row := range d.todos {
+			if row.ParentID == nil || seen[row.ID] || !sameDeletedAt(row.DeletedAt) {
+				continue
+			}
+			for _, parent := range parents {
+				if *row.ParentID == parent {
+					seen[row.ID] = true
+					ids = append(ids, row.ID)
+					next = append(next, row.ID)
+					break
+				}
+			}
+		}
+		parents = next
+	}
+	return ids
+}
+
+func (m *memDatabase) RestoreToDo(ctx context.Context, userID, todoID int64) (*api.ToDo, error) {
+	var restored *api.ToDo
+	err := m.access(func(d *memData) error {
+		row, ok := d.todos[todoID]
+		if !ok || row.UserID != userID || row.DeletedAt == nil {
+			return api.NotFoundf("todo %d not found in trash", todoID)
+		}
+		now := memNow()
+		for _, id := range d.subtaskIDs(todoID, row.DeletedAt) {
+			subtask := changed(d.todos[id], now)
+			subtask.DeletedAt = nil
+			d.todos[id] = subtask
+		}
+
+		row = changed(row, now)
+		row.DeletedAt = nil
+		if row.ParentID != nil {
+			if parent, ok := d.todos[*row.ParentID]; !ok || parent.DeletedAt != nil {
+				row.ParentID = nil
+			}
+		}
+		d.todos[todoID] = row
+		restored = d.toDo(row)
+		return nil
+	})
+	return restored, err
+}
+
+func (m *memDatabase) PurgeToDos(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var count int64
+	err := m.access(func(d *memData) error {
+		ids := []int64{}
+		for id, row := range d.todos {
+			if row.DeletedAt != nil && row.DeletedAt.Before(deletedBefore) {
+				ids = append(ids, id)
+			}
+		}
+		d.deleteToDos(ids)
+		count = int64(len(ids))
+		return nil
+	})
+	return count, err
+}
+
+// deleteToDos removes the todos with their tags, their subtasks become top-level todos.
+func (d *memData) deleteToDos(ids []int64) {
+	deleted := map[int64]bool{}
+	for _, id := range ids {
+		deleted[id] = true
+		delete(d.todos, id)
+		delete(d.todoTags, id)
+	}
+	now := memNow()
+	for id, row := range d.todos {
+		if row.ParentID != nil && deleted[*row.ParentID] {
+			row.ParentID = nil
+			row.UpdatedAt = now
+			d.todos[id] = row
+		}
+	}
+}
+
+func (m *memDatabase) GetSubtasks(ctx context.Context, parentID int64) ([]api.ToDo, error) {
+	var subtasks []api.ToDo
+	err := m.access(func(d *memData) error {
+		rows := []api.ToDo{}
+		for _, row := range d.todos {
+			if row.ParentID != nil && *row.ParentID == parentID && row.DeletedAt == nil {
+				rows = append(rows, row)
+			}
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].Position != rows[j].Position {
+				return rows[i].Position < rows[j].Position
+			}
+			return rows[i].ID < rows[j].ID
+		})
+		subtasks = d.toDos(rows)
+		return nil
+	})
+	return subtasks, err
+}
+
+func (m *memDatabase) GetAncestorIDs(ctx context.Context, todoID int64) ([]int64, error) {
+	ids := []int64{}
+	err := m.access(func(d *memData) error {
+		row, ok := d.todos[todoID]
+		// The depth guards against a cycle like in getAncestorIDsQuery.
+		for ok && row.ParentID != nil && len(ids) < 1000 {
+			ids = append(ids, *row.ParentID)
+			row, ok = d.todos[*row.ParentID]
+		}
+		return nil
+	})
+	return ids, err
+}
+
+func (m *memDatabase) GetToDo(ctx context.Context, todoID int64) (*api.ToDo, error) {
+	var todo *api.ToDo
+	err := m.access(func(d *memData) error {
+		row, ok := d.todos[todoID]
+		if !ok || row.DeletedAt != nil {
+			return api.NotFoundf("todo %d not found", todoID)
+		}
+		todo = d.toDo(row)
+		return nil
+	})
+	return todo, err
+}
+
+func (m *memDatabase) GetToDos(ctx context.Context, userID int64, filter ToDoFilter, limit, offset int64) ([]api.ToDo, error) {
+	var todos []api.ToDo
+	err := m.access(func(d *memData) error {
+		rows, err := d.filterToDos(userID, filter)
+		if err != nil {
+			return err
+		}
+		todos = d.toDos(page(rows, limit, offset))
+		return nil
+	})
+	return todos, err
+}
+
+func (m *memDatabase) GetToDosAfter(ctx context.Context, userID int64, filter ToDoFilter, after *ToDoKey, limit int64) ([]api.ToDo, error) {
+	var todos []api.ToDo
+	err := m.access(func(d *memData) error {
+		rows, err := d.filterToDos(userID, filter)
+		if err != nil {
+			return err
+		}
+		if after != nil {
+			following := []api.ToDo{}
+			for _, row := range rows {
+				cmp, err := compareKeys(filter, rowKey(row), *after)
+				if err != nil {
+					return err
+				}
+				if cmp > 0 {
+					following = append(following, row)
+				}
+			}
+			rows = following
+		}
+		todos = d.toDos(page(rows, limit, 0))
+		return nil
+	})
+	return todos, err
+}
+
+func page(rows []api.ToDo, limit, offset int64) []api.ToDo {
+	if offset >= int64(len(rows)) {
+		return []api.ToDo{}
+	}
+	rows = rows[offset:]
+	if limit < int64(len(rows)) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// filterToDos returns the user's todos that match filter in the order of the filter.
+func (d *memData) filterToDos(userID int64, filter ToDoFilter) ([]api.ToDo, error) {
+	for _, key := range filter.Sort {
+		if _, ok := sortColumns[key.Field]; !ok {
+			return nil, api.Validationf("unknown sort field %q", key.Field)
+		}
+	}
+
+	rows := []api.ToDo{}
+	for _, row := range d.todos {
+		if d.matchesFilter(userID, filter, row) {
+			rows = append(rows, row)
+		}
+	}
+	var err error
+	sort.Slice(rows, func(i, j int) bool {
+		cmp, cmpErr := compareKeys(filter, rowKey(rows[i]), rowKey(rows[j]))
+		if cmpErr != nil {
+			err = cmpErr
+		}
+		return cmp < 0
+	})
+	return rows, err
+}
+
+// matchesFilter is the condition of filterSQL.
+func (d *memData) matchesFilter(userID int64, filter ToDoFilter, row api.ToDo) bool {
+	// inRange is false for a missing value, like a comparison with NULL.
+	inRange := func(t *time.Time, from, to *time.Time) bool {
+		if from == nil && to == nil {
+			return true
+		}
+		return t != nil && (from == nil || !t.Before(*from)) && (to == nil || t.Before(*to))
+	}
+
+	if row.UserID != userID || (row.DeletedAt != nil) != filter.Deleted {
+		return false
+	}
+	if !inRange(row.DueAt, filter.DueFrom, filter.DueTo) ||
+		!inRange(&row.CreatedAt, filter.CreatedFrom, filter.CreatedTo) ||
+		!inRange(&row.UpdatedAt, filter.UpdatedFrom, filter.UpdatedTo) ||
+		!inRange(row.CompletedAt, filter.CompletedFrom, filter.CompletedTo) {
+		return false
+	}
+	if filter.Priorities != nil && !containsString(filter.Priorities, row.Priority) {
+		return false
+	}
+	if filter.Search != nil && !matchSearch(row.Message, filter.Search).found {
+		return false
+	}
+	if filter.Statuses != nil && !containsString(filter.Statuses, row.Status) {
+		return false
+	}
+	if filter.ListID != nil && (row.ListID == nil || *row.ListID != *filter.ListID) {
+		return false
+	}
+	if filter.SeriesID != nil && (row.Recurrence == "" || seriesOf(row) != *filter.SeriesID) {
+		return false
+	}
+	if filter.Inbox && row.ListID != nil {
+		return false
+	}
+	if filter.AnyTags != nil || filter.AllTags != nil {
+		tags := []string{}
+		for tagID := range d.todoTags[row.ID] {
+			tags = append(tags, d.tags[tagID].Name)
+		}
+		anyTag := false
+		for _, tag := range filter.AnyTags {
+			anyTag = anyTag || containsString(tags, tag)
+		}
+		if filter.AnyTags != nil && !anyTag {
+			return false
+		}
+		for _, tag := range filter.AllTags {
+			if !containsString(tags, tag) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// rowKey returns the key of the todo in every order.
+func rowKey(row api.ToDo) ToDoKey {
+	return ToDoKey{
+		CreatedAt:   row.CreatedAt,
+		ID:          row.ID,
+		Position:    row.Position,
+		Priority:    row.Priority,
+		UpdatedAt:   row.UpdatedAt,
+		DueAt:       row.DueAt,
+		CompletedAt: row.CompletedAt,
+	}
+}
+
+// compareKeys compares the keys in the order of orderSQL.
+func compareKeys(filter ToDoFilter, a, b ToDoKey) (int, error) {
+	if len(filter.Sort) > 0 {
+		for _, key := range filter.Sort {
+			cmp, err := compareSortField(key.Field, a, b)
+			if err != nil {
+				return 0, err
+			}
+			if cmp != 0 {
+				if key.Desc && cmp != nullsLast && cmp != -nullsLast {
+					cmp = -cmp
+				}
+				return sign(cmp), nil
+			}
+		}
+		return compareInt(a.ID, b.ID), nil
+	}
+
+	switch filter.Order {
+	case OrderPosition:
+	case OrderPriority:
+		aRank, err := priorityRank(a.Priority)
+		if err != nil {
+			return 0, err
+		}
+		bRank, err := priorityRank(b.Priority)
+		if err != nil {
+			return 0, err
+		}
+		if aRank != bRank {
+			return compareInt(int64(bRank), int64(aRank)), nil
+		}
+	default:
+		if cmp := compareTime(a.CreatedAt, b.CreatedAt); cmp != 0 {
+			return cmp, nil
+		}
+		return compareInt(a.ID, b.ID), nil
+	}
+	if cmp := compareString(a.Position, b.Position); cmp != 0 {
+		return cmp, nil
+	}
+	return compareInt(a.ID, b.ID), nil
+}
+
+// nullsLast is returned by compareSortField when only one of the values is missing,
+// the todo without the value comes last in both directions.
+const nullsLast = 2
+
+func compareSortField(field string, a, b ToDoKey) (int, error) {
+	nullable := func(a, b *time.Time) int {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return nullsLast
+		case b == nil:
+			return -nullsLast
+		}
+		return compareTime(*a, *b)
+	}
+
+	switch field {
+	case SortCreated:
+		return compareTime(a.CreatedAt, b.CreatedAt), nil
+	case SortUpdated:
+		return compareTime(a.UpdatedAt, b.UpdatedAt), nil
+	case SortDue:
+		return nullable(a.DueAt, b.DueAt), nil
+	case SortCompleted:
+		return nullable(a.CompletedAt, b.CompletedAt), nil
+	case SortPriority:
+		aRank, err := priorityRank(a.Priority)
+		if err != nil {
+			return 0, err
+		}
+		bRank, err := priorityRank(b.Priority)
+		if err != nil {
+			return 0, err
+		}
+		return compareInt(int64(aRank), int64(bRank)), nil
+	case SortPosition:
+		return compareString(a.Position, b.Position), nil
+	default:
+		return 0, api.Validationf("unknown sort field %q", field)
+	}
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareString compares bytes, like the "C" collation of position.
+func compareString(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func sign(cmp int) int {
+	return compareInt(int64(cmp), 0)
+}
+
+func (m *memDatabase) CountToDos(ctx context.Context, userID int64, filter ToDoFilter) (int64, error) {
+	var count int64
+	err := m.access(func(d *memData) error {
+		for _, row := range d.todos {
+			if d.matchesFilter(userID, filter, row) {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (m *memDatabase) LastPosition(ctx context.Context, userID int64) (string, error) {
+	var position string
+	err := m.access(func(d *memData) error {
+		for _, row := range d.todos {
+			if row.UserID == userID && row.DeletedAt == nil && row.Position > position {
+				position = row.Position
+			}
+		}
+		return nil
+	})
+	return position, err
+}
+
+func (m *memDatabase) AdjacentPosition(ctx context.Context, userID int64, position string, before bool) (string, error) {
+	var adjacent string
+	err := m.access(func(d *memData) error {
+		found := false
+		for _, row := range d.todos {
+			if row.UserID != userID || row.DeletedAt != nil {
+				continue
+			}
+			if before && row.Position < position && (!found || row.Position > adjacent) ||
+				!before && row.Position > position && (!found || row.Position < adjacent) {
+				adjacent, found = row.Position, true
+			}
+		}
+		return nil
+	})
+	return adjacent, err
+}
+
+func (m *memDatabase) AddToDoTags(ctx context.Context, todoID int64, tags []string) (*api.ToDo, error) {
+	var todo *api.ToDo
+	err := m.access(func(d *memData) error {
+		row, ok := d.todos[todoID]
+		if !ok || row.DeletedAt != nil {
+			return api.NotFoundf("todo %d not found", todoID)
+		}
+		row = changed(row, memNow())
+		d.todos[todoID] = row
+
+		for _, name := range tags {
+			tagID := d.tagID(row.UserID, name)
+			if tagID == 0 {
+				m.store.seq.tags++
+				tagID = m.store.seq.tags
+				d.tags[tagID] = memTag{ID: tagID, UserID: row.UserID, Name: name}
+			}
+			if d.todoTags[todoID] == nil {
+				d.todoTags[todoID] = map[int64]bool{}
+			}
+			d.todoTags[todoID][tagID] = true
+		}
+		todo = d.toDo(row)
+		return nil
+	})
+	return todo, err
+}
+
+// tagID returns the id of the user's tag, 0 when the user has no such tag.
+func (d *memData) tagID(userID int64, name string) int64 {
+	for id, tag := range d.tags {
+		if tag.UserID == userID && tag.Name == name {
+			return id
+		}
+	}
+	return 0
+}
+
+func (m *memDatabase) RemoveToDoTag(ctx context.Context, todoID int64, tag string) (*api.ToDo, error) {
+	var todo *api.ToDo
+	err := m.access(func(d *memData) error {
+		row, ok := d.todos[todoID]
+		if !ok || row.DeletedAt != nil {
+			return api.NotFoundf("todo %d not found", todoID)
+		}
+		tagID := d.tagID(row.UserID, tag)
+		if tagID == 0 || !d.todoTags[todoID][tagID] {
+			return api.NotFoundf("todo %d has no tag %q", todoID, tag)
+		}
+		row = changed(row, memNow())
+		d.todos[todoID] = row
+		delete(d.todoTags[todoID], tagID)
+
+		used := false
+		for _, tags := range d.todoTags {
+			used = used || tags[tagID]
+		}
+		if !used {
+			delete(d.tags, tagID)
+		}
+		todo = d.toDo(row)
+		return nil
+	})
+	return todo, err
+}
+
+func (m *memDatabase) GetTags(ctx context.Context, userID int64) ([]api.Tag, error) {
+	tags := []api.Tag{}
+	err := m.access(func(d *memData) error {
+		for id, tag := range d.tags {
+			if tag.UserID != userID {
+				continue
+			}
+			var count int64
+			for todoID, todoTags := range d.todoTags {
+				if todoTags[id] && d.todos[todoID].DeletedAt == nil {
+					count++
+				}
+			}
+			tags = append(tags, api.Tag{Name: tag.Name, Count: count})
+		}
+		sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+		return nil
+	})
+	return tags, err
+}
+
+func (m *memDatabase) SearchToDos(ctx context.Context, userID int64, terms []SearchTerm, limit, offset int64) ([]api.SearchResult, int64, error) {
+	results := []api.SearchResult{}
+	if len(terms) == 0 {
+		return results, 0, nil
+	}
+	var total int64
+	err := m.access(func(d *memData) error {
+		for _, row := range d.todos {
+			if row.UserID != userID || row.DeletedAt != nil {
+				continue
+			}
+			if match := matchSearch(row.Message, terms); match.found {
+				results = append(results, api.SearchResult{ToDo: *d.toDo(row), Snippet: match.snippet, Rank: match.rank})
+			}
+		}
+		sort.Slice(results, func(i, j int) bool {
+			if results[i].Rank != results[j].Rank {
+				return results[i].Rank > results[j].Rank
+			}
+			return results[i].ToDo.ID < results[j].ToDo.ID
+		})
+		total = int64(len(results))
+		if offset >= total {
+			results = []api.SearchResult{}
+			return nil
+		}
+		results = results[offset:]
+		if limit < int64(len(results)) {
+			results = results[:limit]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+func (m *memDatabase) CreateUser(ctx context.Context, user api.User) (*api.User, error) {
+	var created api.User
+	err := m.access(func(d *memData) error {
+		if d.userID(user.Name) != 0 {
+			return api.Conflictf("insert user to database: Key (username)=(%s) already exists.", user.Name)
+		}
+		m.store.seq.users++
+		created = api.User{ID: m.store.seq.users, Name: user.Name}
+		d.users[created.ID] = created
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// userID returns the id of the user with the name, 0 when there is none.
+func (d *memData) userID(name string) int64 {
+	for id, user := range d.users {
+		if user.Name == name {
+			return id
+		}
+	}
+	return 0
+}
+
+func (m *memDatabase) GetUser(ctx context.Context, id int64) (*api.User, error) {
+	var user api.User
+	err := m.access(func(d *memData) error {
+		var ok bool
+		if user, ok = d.users[id]; !ok {
+			return api.NotFoundf("user %d not found", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (m *memDatabase) GetUsers(ctx context.Context, limit, offset int64) ([]api.User, error) {
+	users := []api.User{}
+	err := m.access(func(d *memData) error {
+		for _, user := range d.users {
+			users = append(users, user)
+		}
+		return nil
+	})
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	if offset >= int64(len(users)) {
+		return []api.User{}, err
+	}
+	users = users[offset:]
+	if limit < int64(len(users)) {
+		users = users[:limit]
+	}
+	return users, err
+}
+
+func (m *memDatabase) CountUsers(ctx context.Context) (int64, error) {
+	var count int64
+	err := m.access(func(d *memData) error {
+		count = int64(len(d.users))
+		return nil
+	})
+	return count, err
+}
+
+func (m *memDatabase) UpdateUser(ctx context.Context, user api.User) error {
+	return m.access(func(d *memData) error {
+		if _, ok := d.users[user.ID]; !ok {
+			return api.NotFoundf("user %d not found", user.ID)
+		}
+		if id := d.userID(user.Name); id != 0 && id != user.ID {
+			return api.Conflictf("update user in database: Key (username)=(%s) already exists.", user.Name)
+		}
+		d.users[user.ID] = api.User{ID: user.ID, Name: user.Name}
+		return nil
+	})
+}
+
+// DeleteUser removes the user's todos, lists, tags and tokens like ON DELETE CASCADE.
+func (m *memDatabase) DeleteUser(ctx context.Context, id int64) error {
+	return m.access(func(d *memData) error {
+		if _, ok := d.users[id]; !ok {
+			return api.NotFoundf("user %d not found", id)
+		}
+		delete(d.users, id)
+
+		todos := []int64{}
+		for todoID, row := range d.todos {
+			if row.UserID == id {
+				todos = append(todos, todoID)
+			}
+		}
+		d.deleteToDos(todos)
+		for listID, list := range d.lists {
+			if list.UserID == id {
+				d.deleteList(listID)
+			}
+		}
+		for tagID, tag := range d.tags {
+			if tag.UserID == id {
+				delete(d.tags, tagID)
+				for _, tags := range d.todoTags {
+					delete(tags, tagID)
+				}
+			}
+		}
+		for tokenID, token := range d.tokens {
+			if token.UserID == id {
+				delete(d.tokens, tokenID)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *memDatabase) CreateList(ctx context.Context, list api.List) (*api.List, error) {
+	var created api.List
+	err := m.access(func(d *memData) error {
+		if d.listID(list.UserID, list.Name) != 0 {
+			return api.Conflictf("insert list to database: Key (user_id, name)=(%d, %s) already exists.", list.UserID, list.Name)
+		}
+		if _, ok := d.users[list.UserID]; !ok {
+			return missingKey("insert list to database", "user_id", list.UserID, "users")
+		}
+		m.store.seq.lists++
+		created = api.List{
+			ID:        m.store.seq.lists,
+			UserID:    list.UserID,
+			Name:      list.Name,
+			Color:     list.Color,
+			Archived:  list.Archived,
+			CreatedAt: memNow(),
+		}
+		d.lists[created.ID] = created
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// listID returns the id of the user's list with the name, 0 when there is none.
+func (d *memData) listID(userID int64, name string) int64 {
+	for id, list := range d.lists {
+		if list.UserID == userID && list.Name == name {
+			return id
+		}
+	}
+	return 0
+}
+
+func (m *memDatabase) GetList(ctx context.Context, listID int64) (*api.List, error) {
+	var list api.List
+	err := m.access(func(d *memData) error {
+		var ok bool
+		if list, ok = d.lists[listID]; !ok {
+			return api.NotFoundf("list %d not found", listID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (m *memDatabase) GetLists(ctx context.Context, userID int64, archived bool) ([]api.List, error) {
+	lists := []api.List{}
+	err := m.access(func(d *memData) error {
+		for _, list := range d.lists {
+			if list.UserID == userID && (!list.Archived || archived) {
+				lists = append(lists, list)
+			}
+		}
+		return nil
+	})
+	sort.Slice(lists, func(i, j int) bool {
+		if lists[i].Name != lists[j].Name {
+			return lists[i].Name < lists[j].Name
+		}
+		return lists[i].ID < lists[j].ID
+	})
+	return lists, err
+}
+
+func (m *memDatabase) UpdateList(ctx context.Context, list api.List) (*api.List, error) {
+	var updated api.List
+	err := m.access(func(d *memData) error {
+		var ok bool
+		if updated, ok = d.lists[list.ID]; !ok {
+			return api.NotFoundf("list %d not found", list.ID)
+		}
+		if id := d.listID(updated.UserID, list.Name); id != 0 && id != list.ID {
+			return api.Conflictf("update list in database: Key (user_id, name)=(%d, %s) already exists.", updated.UserID, list.Name)
+		}
+		updated.Name, updated.Color, updated.Archived = list.Name, list.Color, list.Archived
+		d.lists[list.ID] = updated
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (m *memDatabase) DeleteList(ctx context.Context, listID int64) error {
+	return m.access(func(d *memData) error {
+		if _, ok := d.lists[listID]; !ok {
+			return api.NotFoundf("list %d not found", listID)
+		}
+		d.deleteList(listID)
+		return nil
+	})
+}
+
+// deleteList removes the list, its todos move to the inbox like ON DELETE SET NULL.
+func (d *memData) deleteList(listID int64) {
+	delete(d.lists, listID)
+	now := memNow()
+	for id, row := range d.todos {
+		if row.ListID != nil && *row.ListID == listID {
+			row.ListID = nil
+			row.UpdatedAt = now
+			d.todos[id] = row
+		}
+	}
+}
+
+func (m *memDatabase) CreateAPIToken(ctx context.Context, token api.APIToken, hash []byte) (*api.APIToken, error) {
+	var created api.APIToken
+	err := m.access(func(d *memData) error {
+		if _, ok := d.tokenByHash(hash); ok {
+			return api.Conflictf("insert api token to database: Key (token_hash)=(\\x%x) already exists.", hash)
+		}
+		if _, ok := d.users[token.UserID]; !ok {
+			return missingKey("insert api token to database", "user_id", token.UserID, "users")
+		}
+		m.store.seq.tokens++
+		created = api.APIToken{ID: m.store.seq.tokens, UserID: token.UserID, Name: token.Name, CreatedAt: memNow()}
+		d.tokens[created.ID] = memToken{APIToken: created, Hash: append([]byte(nil), hash...)}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (d *memData) tokenByHash(hash []byte) (memToken, bool) {
+	for _, token := range d.tokens {
+		if string(token.Hash) == string(hash) {
+			return token, true
+		}
+	}
+	return memToken{}, false
+}
+
+func (m *memDatabase) GetAPITokens(ctx context.Context, userID int64) ([]api.APIToken, error) {
+	tokens := []api.APIToken{}
+	err := m.access(func(d *memData) error {
+		for _, token := range d.tokens {
+			if token.UserID == userID {
+				tokens = append(tokens, token.APIToken)
+			}
+		}
+		return nil
+	})
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	return tokens, err
+}
+
+func (m *memDatabase) GetAPITokenUser(ctx context.Context, hash []byte) (*api.User, error) {
+	var user api.User
+	err := m.access(func(d *memData) error {
+		token, ok := d.tokenByHash(hash)
+		if !ok {
+			return api.NotFoundf("api token not found")
+		}
+		user = d.users[token.UserID]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (m *memDatabase) DeleteAPIToken(ctx context.Context, userID, tokenID int64) error {
+	return m.access(func(d *memData) error {
+		token, ok := d.tokens[tokenID]
+		if !ok || token.UserID != userID {
+			return api.NotFoundf("api token %d not found", tokenID)
+		}
+		delete(d.tokens, tokenID)
+		return nil
+	})
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"strings"
+	"unicode"
+)
+
+// The search of memDatabase approximates the english text search configuration of Postgres:
+// words are lowercased, common suffixes are stripped and stop words match nothing.
+
+// stopWords are the most common words of the english stop word list of Postgres.
+var stopWords = map[string]bool{
+	"a": true, "about": true, "after": true, "all": true, "an": true, "and": true, "any": true, "are": true,
+	"as": true, "at": true, "be": true, "been": true, "before": true, "but": true, "by": true, "can": true,
+	"did": true, "do": true, "does": true, "for": true, "from": true, "had": true, "has": true, "have": true,
+	"he": true, "her": true, "his": true, "i": true, "if": true, "in": true, "into": true, "is": true,
+	"it": true, "its": true, "me": true, "my": true, "no": true, "not": true, "of": true, "on": true,
+	"or": true, "our": true, "she": true, "so": true, "than": true, "that": true, "the": true, "their": true,
+	"them": true, "then": true, "there": true, "these": true, "they": true, "this": true, "to": true, "up": true,
+	"was": true, "we": true, "were": true, "what": true, "when": true, "which": true, "who": true, "will": true,
+	"with": true, "you": true, "your": true,
+}
+
+// suffixes are replaced in the words that keep at least 3 letters, the first match wins.
+var suffixes = []struct {
+	suffix, replacement string
+}{
+	{"sses", "ss"},
+	{"ies", "y"},
+	{"ing", ""},
+	{"ed", ""},
+	{"s", ""},
+}
+
+// stem returns the lexeme of a lowercase word, empty for a stop word.
+func stem(word string) string {
+	if stopWords[word] {
+		return ""
+	}
+	if strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "sses") {
+		return word
+	}
+	for _, s := range suffixes {
+		if strings.HasSuffix(word, s.suffix) && len(word)-len(s.suffix) >= 3 {
+			return strings.TrimSuffix(word, s.suffix) + s.replacement
+		}
+	}
+	return word
+}
+
+// token is a word of a text, start and end are its byte offsets.
+type token struct {
+	lexeme     string
+	start, end int
+}
+
+func tokenize(text string) []token {
+	tokens := []token{}
+	start := -1
+	for i, r := range text + " " {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{lexeme: stem(strings.ToLower(text[start:i])), start: start, end: i})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+type searchMatch struct {
+	found   bool
+	snippet string
+	rank    float64
+}
+
+// matchSearch matches the text against all terms like tsQuerySQL. Terms without lexemes are
+// left out like the empty tsqueries of stop words. The rank is the share of the matching words
+// and the snippet is the text with the matching words in <mark> and </mark>.
+func matchSearch(text string, terms []SearchTerm) searchMatch {
+	tokens := tokenize(text)
+	marked := make([]bool, len(tokens))
+	empty := true
+	for _, term := range terms {
+		query := tokenize(term.Text)
+		if term.Prefix {
+			query = []token{{lexeme: stem(strings.ToLower(term.Text))}}
+		}
+		// Stop words around a phrase are dropped.
+		for len(query) > 0 && query[0].lexeme == "" {
+			query = query[1:]
+		}
+		for len(query) > 0 && query[len(query)-1].lexeme == "" {
+			query = query[:len(query)-1]
+		}
+		if len(query) == 0 {
+			continue
+		}
+		empty = false
+
+		matches := func(i int, q token) bool {
+			if term.Prefix {
+				return tokens[i].lexeme != "" && strings.HasPrefix(tokens[i].lexeme, q.lexeme)
+			}
+			return q.lexeme == "" || tokens[i].lexeme == q.lexeme
+		}
+		found := false
+		if term.Phrase {
+			// The words of a phrase follow each other, a stop word keeps the place of a word.
+			for start := 0; start+len(query) <= len(tokens); start++ {
+				phrase := true
+				for k, q := range query {
+					phrase = phrase && matches(start+k, q)
+				}
+				found = found || phrase
+			}
+		} else {
+			// Every word of the term is somewhere in the text.
+			found = true
+			for _, q := range query {
+				if q.lexeme == "" {
+					continue
+				}
+				word := false
+				for i := range tokens {
+					word = word || matches(i, q)
+				}
+				found = found && word
+			}
+		}
+		if !found {
+			return searchMatch{}
+		}
+
+		for i := range tokens {
+			for _, q := range query {
+				if q.lexeme != "" && matches(i, q) {
+					marked[i] = true
+				}
+			}
+		}
+	}
+	if empty {
+		return searchMatch{}
+	}
+
+	var snippet strings.Builder
+	count, last := 0, 0
+	for i, t := range tokens {
+		if !marked[i] {
+			continue
+		}
+		count++
+		snippet.WriteString(text[last:t.start])
+		snippet.WriteString("<mark>" + text[t.start:t.end] + "</mark>")
+		last = t.end
+	}
+	snippet.WriteString(text[last:])
+	return searchMatch{found: true, snippet: snippet.String(), rank: float64(count) / float64(len(tokens))}
+}
@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// schema_migrations is outside of todo_app, so the first migration can drop the schema.
+	createMigrationsTableQuery = `
+		CREATE TABLE IF NOT EXISTS public.schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`
+	getAppliedMigrationsQuery = `SELECT version, applied_at FROM public.schema_migrations`
+	addMigrationQuery         = `INSERT INTO public.schema_migrations (version, name) VALUES ($1, $2)`
+	deleteMigrationQuery      = `DELETE FROM public.schema_migrations WHERE version = $1`
+
+	sqliteCreateMigrationsTableQuery = `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT (` + sqliteNow + `)
+		)`
+	sqliteGetAppliedMigrationsQuery = `SELECT version, applied_at FROM schema_migrations`
+	sqliteAddMigrationQuery         = `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+	sqliteDeleteMigrationQuery      = `DELETE FROM schema_migrations WHERE version = $1`
+
+	tryLockMigrationsQuery = `SELECT pg_try_advisory_lock($1)`
+	lockMigrationsQuery    = `SELECT pg_advisory_lock($1)`
+	unlockMigrationsQuery  = `SELECT pg_advisory_unlock($1)`
+)
+
+// migrationsLockID is the key of the advisory lock that keeps migrations from running concurrently.
+const migrationsLockID = 7204031953
+
+// Migration is a versioned schema change, Down reverts Up.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus tells if a migration is applied. Applied migrations the binary does not know have no Up and Down.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrations reads the migrations <version>_<name>.up.sql and <version>_<name>.down.sql
+// of fsys ordered by version. Every migration needs both files.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.Wrap(err, "read migrations")
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, errors.Errorf("migration %s has an invalid version", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.Wrapf(err, "read migration %s", entry.Name())
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, errors.Errorf("migrations %s and %s have the same version", migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, errors.Errorf("migration %d_%s needs an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// migrationQueries are the queries of the migrations table of a driver.
+type migrationQueries struct {
+	createTable, getApplied, add, delete string
+}
+
+var (
+	pgMigrationQueries = migrationQueries{
+		createTable: createMigrationsTableQuery,
+		getApplied:  getAppliedMigrationsQuery,
+		add:         addMigrationQuery,
+		delete:      deleteMigrationQuery,
+	}
+	sqliteMigrationQueries = migrationQueries{
+		createTable: sqliteCreateMigrationsTableQuery,
+		getApplied:  sqliteGetAppliedMigrationsQuery,
+		add:         sqliteAddMigrationQuery,
+		delete:      sqliteDeleteMigrationQuery,
+	}
+)
+
+// Migrator applies and reverts migrations. Every migration runs in its own transaction
+// and is recorded in public.schema_migrations, or schema_migrations of SQLite.
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	queries    migrationQueries
+	migrations []Migration
+}
+
+func NewMigrator(ctx context.Context, cfg StorageConfig, migrations []Migration) (*Migrator, error) {
+	dsn := cfg.DSN
+	if cfg.Driver == DriverSQLite {
+		dsn = sqliteDSN(dsn)
+	}
+	db, err := sql.Open(cfg.Driver, dsn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open(%s) database connection", cfg.Driver)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "ping database")
+	}
+	return newMigrator(db, cfg.Driver, migrations), nil
+}
+
+// newMigrator returns a Migrator of an open database, closing it closes db.
+func newMigrator(db *sql.DB, driver string, migrations []Migration) *Migrator {
+	queries := pgMigrationQueries
+	if driver == DriverSQLite {
+		queries = sqliteMigrationQueries
+	}
+	return &Migrator{db: db, driver: driver, queries: queries, migrations: migrations}
+}
+
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// Status returns the known migrations and the applied ones ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var status []MigrationStatus
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		var err error
+		status, err = m.status(ctx, conn)
+		return err
+	})
+	return status, err
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+	return m.To(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down reverts the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		status, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(status) - 1; i >= 0; i-- {
+			if status[i].AppliedAt != nil {
+				return m.revert(ctx, conn, status[i])
+			}
+		}
+		return nil
+	})
+}
+
+// To applies the pending migrations up to version and reverts the applied ones after it.
+// Version 0 reverts every migration.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	known := version == 0
+	for _, migration := range m.migrations {
+		known = known || migration.Version == version
+	}
+	if !known {
+		return errors.Errorf("unknown migration %d", version)
+	}
+
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		status, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return m.migrate(ctx, conn, status, version)
+	})
+}
+
+func (m *Migrator) migrate(ctx context.Context, conn *sql.Conn, status []MigrationStatus, target int64) error {
+	for i := len(status) - 1; i >= 0; i-- {
+		s := status[i]
+		if s.Version <= target || s.AppliedAt == nil {
+			continue
+		}
+		if err := m.revert(ctx, conn, s); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range status {
+		if s.Version > target || s.AppliedAt != nil {
+			continue
+		}
+		if err := m.run(ctx, conn, s.Up, m.queries.add, s.Version, s.Name); err != nil {
+			return errors.Wrapf(err, "apply migration %d_%s", s.Version, s.Name)
+		}
+		log.Infof("Applied migration %d_%s.", s.Version, s.Name)
+	}
+	return nil
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, s MigrationStatus) error {
+	if s.Down == "" {
+		return errors.Errorf("migration %d is unknown and cant be reverted", s.Version)
+	}
+	if err := m.run(ctx, conn, s.Down, m.queries.delete, s.Version); err != nil {
+		return errors.Wrapf(err, "revert migration %d_%s", s.Version, s.Name)
+	}
+	log.Infof("Reverted migration %d_%s.", s.Version, s.Name)
+	return nil
+}
+
+// run executes the migration SQL and records it with the record query in one transaction.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, migration, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return errors.Wrap(err, "record migration")
+	}
+	return errors.Wrap(tx.Commit(), "commit transaction")
+}
+
+func (m *Migrator) status(ctx context.Context, conn *sql.Conn) ([]MigrationStatus, error) {
+	rows, err := conn.QueryContext(ctx, m.queries.getApplied)
+	if err != nil {
+		return nil, errors.Wrap(err, "query applied migrations")
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, errors.Wrap(err, "scan applied migration")
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate applied migrations")
+	}
+
+	status := []MigrationStatus{}
+	for _, migration := range m.migrations {
+		s := MigrationStatus{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			s.AppliedAt = &appliedAt
+			delete(applied, migration.Version)
+		}
+		status = append(status, s)
+	}
+	// Migrations of a newer binary.
+	for version, appliedAt := range applied {
+		appliedAt := appliedAt
+		status = append(status, MigrationStatus{Migration: Migration{Version: version}, AppliedAt: &appliedAt})
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+	return status, nil
+}
+
+// locked runs fn on a connection that holds the migrations lock. SQLite has no advisory locks,
+// its databases are meant for a single process.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get connection")
+	}
+	defer conn.Close()
+
+	if m.driver == DriverSQLite {
+		if _, err := conn.ExecContext(ctx, m.queries.createTable); err != nil {
+			return errors.Wrap(err, "create migrations table")
+		}
+		return fn(conn)
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, tryLockMigrationsQuery, migrationsLockID).Scan(&locked); err != nil {
+		return errors.Wrap(err, "lock migrations")
+	}
+	if !locked {
+		log.Info("Waiting for another migration to finish.")
+		if _, err := conn.ExecContext(ctx, lockMigrationsQuery, migrationsLockID); err != nil {
+			return errors.Wrap(err, "lock migrations")
+		}
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), unlockMigrationsQuery, migrationsLockID); err != nil {
+			log.Error("cant unlock migrations: ", err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, m.queries.createTable); err != nil {
+		return errors.Wrap(err, "create migrations table")
+	}
+	return fn(conn)
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"testing"
+	"testing/fstest"
+	"to-do/database"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	migrations, err := LoadMigrations(fstest.MapFS{
+		"0002_tags.up.sql":     {Data: []byte("CREATE TABLE tags ();")},
+		"0002_tags.down.sql":   {Data: []byte("DROP TABLE tags;")},
+		"0001_schema.up.sql":   {Data: []byte("CREATE SCHEMA app;")},
+		"0001_schema.down.sql": {Data: []byte("DROP SCHEMA app;")},
+		"README.md":            {Data: []byte("not a migration")},
+	})
+	assert.NoError(err)
+	assert.Equal([]Migration{
+		{Version: 1, Name: "schema", Up: "CREATE SCHEMA app;", Down: "DROP SCHEMA app;"},
+		{Version: 2, Name: "tags", Up: "CREATE TABLE tags ();", Down: "DROP TABLE tags;"},
+	}, migrations)
+
+	_, err = LoadMigrations(fstest.MapFS{
+		"0001_schema.up.sql": {Data: []byte("CREATE SCHEMA app;")},
+	})
+	assert.Error(err, "missing down file")
+
+	_, err = LoadMigrations(fstest.MapFS{
+		"0001_schema.up.sql":   {Data: []byte("CREATE SCHEMA app;")},
+		"0001_schema.down.sql": {Data: []byte("DROP SCHEMA app;")},
+		"0001_tags.up.sql":     {Data: []byte("CREATE TABLE tags ();")},
+		"0001_tags.down.sql":   {Data: []byte("DROP TABLE tags;")},
+	})
+	assert.Error(err, "duplicate version")
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	migrations, err := LoadMigrations(database.Migrations())
+	assert.NoError(err)
+	assert.NotEmpty(migrations)
+	for i, migration := range migrations {
+		assert.Equal(int64(i+1), migration.Version, "versions have no gaps")
+	}
+}
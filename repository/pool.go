@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxConnectRetryDelay caps the doubling delay between the connection attempts.
+const maxConnectRetryDelay = 30 * time.Second
+
+// PoolStorage is implemented by the storages with a database connection pool.
+type PoolStorage interface {
+	// Ping checks that the database is reachable.
+	Ping(ctx context.Context) error
+	// Stats returns the statistics of the connection pool.
+	Stats() sql.DBStats
+}
+
+// connect pings db until it answers or cfg.ConnectRetries retries failed,
+// so the service can start before its database.
+func connect(ctx context.Context, db *sql.DB, cfg StorageConfig) error {
+	delay := cfg.ConnectRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= cfg.ConnectRetries {
+			return errors.Wrap(err, "ping database")
+		}
+		log.Warnf("Cant connect to database, retry %d/%d in %v: %v", attempt+1, cfg.ConnectRetries, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "ping database")
+		}
+		if delay *= 2; delay > maxConnectRetryDelay {
+			delay = maxConnectRetryDelay
+		}
+	}
+}
+
+// sessionConnector runs init on every new connection of the pool.
+type sessionConnector struct {
+	driver.Connector
+	init string
+}
+
+func (c sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		conn.Close()
+		return nil, errors.New("the database driver cannot run session statements")
+	}
+	if _, err := execer.ExecContext(ctx, c.init, nil); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "set up database session")
+	}
+	return conn, nil
+}
+
+// withStatementTimeout sets the PostgreSQL statement_timeout of the connections of connector.
+func withStatementTimeout(connector driver.Connector, timeout time.Duration) driver.Connector {
+	if timeout <= 0 {
+		return connector
+	}
+	return sessionConnector{
+		Connector: connector,
+		init:      fmt.Sprintf("SET statement_timeout = %d", timeout.Milliseconds()),
+	}
+}
@@ -4,37 +4,255 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math/rand"
+	"strings"
+	"time"
 	"to-do/api"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
 	// TODO_LIST table query
-	deleteTODOQuery = `DELETE FROM todo_app.todo_list WHERE id = $1`
+	toDoColumns = `id, user_id, created_at, updated_at, message, version, due_at, reminder_at, status, completed_at, deleted_at, priority, position, list_id,
+		(SELECT coalesce(array_agg(g.name ORDER BY g.name), '{}') FROM todo_app.todo_tags tt
+			JOIN todo_app.tags g ON g.id = tt.tag_id
+			WHERE tt.todo_id = todo_list.id),
+		parent_id,
+		(SELECT count(*) FROM todo_app.todo_list s
+			WHERE s.parent_id = todo_list.id AND s.deleted_at IS NULL AND s.status = 'done'),
+		(SELECT count(*) FROM todo_app.todo_list s
+			WHERE s.parent_id = todo_list.id AND s.deleted_at IS NULL AND s.status <> 'cancelled'),
+		recurrence,
+		CASE WHEN recurrence <> '' THEN coalesce(series_id, id) END,
+		series_start`
+
+	// deleteTODOQuery moves the todo to the trash, it is completed with the precondition.
+	deleteTODOQuery = `
+		UPDATE todo_app.todo_list SET deleted_at = now(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL%s
+		RETURNING deleted_at`
+
+	// subtasksQuery selects the subtasks of $1 at any depth that have deleted_at equal to $2,
+	// or are live when $2 is NULL.
+	subtasksQuery = `
+		WITH RECURSIVE subtasks AS (
+			SELECT id FROM todo_app.todo_list
+			WHERE parent_id = $1 AND deleted_at IS NOT DISTINCT FROM $2
+			UNION
+			SELECT t.id FROM todo_app.todo_list t JOIN subtasks s ON t.parent_id = s.id
+			WHERE t.deleted_at IS NOT DISTINCT FROM $2
+		)
+		SELECT id FROM subtasks`
+
+	trashSubtasksQuery = `
+		UPDATE todo_app.todo_list SET deleted_at = $3, version = version + 1
+		WHERE id IN (` + subtasksQuery + `)`
+
+	restoreSubtasksQuery = `
+		UPDATE todo_app.todo_list SET deleted_at = NULL, version = version + 1
+		WHERE id IN (` + subtasksQuery + `)`
+
+	getDeletedAtQuery = `
+		SELECT deleted_at FROM todo_app.todo_list
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+		FOR UPDATE`
+
+	restoreToDoQuery = `
+		UPDATE todo_app.todo_list SET deleted_at = NULL, version = version + 1,
+			parent_id = (SELECT p.id FROM todo_app.todo_list p WHERE p.id = todo_list.parent_id AND p.deleted_at IS NULL)
+		WHERE id = $1
+		RETURNING ` + toDoColumns
+
+	getSubtasksQuery = `
+		SELECT ` + toDoColumns + ` FROM todo_app.todo_list
+		WHERE parent_id = $1 AND deleted_at IS NULL
+		ORDER BY position, id`
+
+	getAncestorIDsQuery = `
+		WITH RECURSIVE ancestors AS (
+			SELECT parent_id AS id, 1 AS depth FROM todo_app.todo_list WHERE id = $1 AND parent_id IS NOT NULL
+			UNION ALL
+			SELECT t.parent_id, a.depth + 1 FROM todo_app.todo_list t JOIN ancestors a ON t.id = a.id
+			-- the depth guards against a cycle that slipped into the table
+			WHERE t.parent_id IS NOT NULL AND a.depth < 1000
+		)
+		SELECT id FROM ancestors ORDER BY depth`
+
+	purgeToDosQuery = `DELETE FROM todo_app.todo_list WHERE deleted_at < $1`
 
 	addToDoQuery = `
 		INSERT INTO todo_app.todo_list 
-			(id, user_id, created_at, updated_at, message)
+			(id, user_id, created_at, updated_at, message, due_at, reminder_at, priority, position, list_id, parent_id,
+			recurrence, series_id, series_start)
     	VALUES 
-			(DEFAULT, $1, DEFAULT, DEFAULT, $2)`
+			(DEFAULT, $1, DEFAULT, DEFAULT, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING ` + toDoColumns
+
+	getToDoQuery = `SELECT ` + toDoColumns + ` FROM todo_app.todo_list WHERE id = $1 AND deleted_at IS NULL`
+
+	// updateToDoQuery is completed with the precondition.
+	updateToDoQuery = `
+		UPDATE todo_app.todo_list
+		SET message=$2, due_at=$3, reminder_at=$4, priority=$5, list_id=$6, parent_id=$7, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL%s
+		RETURNING ` + toDoColumns
+
+	// patchToDoQuery is completed with the SET list of changed fields and the precondition.
+	patchToDoQuery = `
+		UPDATE todo_app.todo_list SET %s, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL%s
+		RETURNING ` + toDoColumns
+
+	// searchToDosQuery is completed with the tsquery of the search terms.
+	searchToDosQuery = `
+		SELECT ` + toDoColumns + `,
+			ts_headline('english', message, query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2'),
+			ts_rank(search_vector, query) AS rank
+		FROM todo_app.todo_list, (SELECT %s AS query) q
+		WHERE user_id = $1 AND deleted_at IS NULL AND search_vector @@ query
+		ORDER BY rank DESC, id
+		LIMIT $2 OFFSET $3`
+
+	// countSearchQuery is completed with the tsquery of the search terms.
+	countSearchQuery = `
+		SELECT count(*) FROM todo_app.todo_list, (SELECT %s AS query) q
+		WHERE user_id = $1 AND deleted_at IS NULL AND search_vector @@ query`
+
+	// updateSeriesQuery is completed with the SET list of changed fields.
+	updateSeriesQuery = `
+		UPDATE todo_app.todo_list SET %s, version = version + 1
+		WHERE coalesce(series_id, id) = $1 AND recurrence <> ''
+			AND deleted_at IS NULL AND status IN ('open', 'in_progress')`
 
-	getToDoQuery = `SELECT id, user_id, created_at, updated_at, message FROM todo_app.todo_list WHERE id = $1`
+	// listToDosQuery is completed with the WHERE conditions, the ORDER BY list and the LIMIT/OFFSET clause.
+	listToDosQuery = `
+		SELECT ` + toDoColumns + ` FROM todo_app.todo_list
+		WHERE %s
+		ORDER BY %s
+		%s`
 
-	updateToDoQuery = `UPDATE todo_app.todo_list SET message=$1  WHERE id = $2`
+	lastPositionQuery = `
+		SELECT coalesce(max(position), '') FROM todo_app.todo_list
+		WHERE user_id = $1 AND deleted_at IS NULL`
 
-	// TODO: implement
-	getTODOsQuery = `SELECT * FROM todo_app.todo_list WHERE user_id = $1 LIMIT $2 OFFSET $3`
+	positionBeforeQuery = `
+		SELECT coalesce(max(position), '') FROM todo_app.todo_list
+		WHERE user_id = $1 AND deleted_at IS NULL AND position < $2`
+
+	positionAfterQuery = `
+		SELECT coalesce(min(position), '') FROM todo_app.todo_list
+		WHERE user_id = $1 AND deleted_at IS NULL AND position > $2`
+
+	// countToDosQuery is completed with the WHERE conditions.
+	countToDosQuery = `SELECT count(*) FROM todo_app.todo_list WHERE %s`
 
 	// USERS Query
-	getUserQuery = `SELECT * FROM todo_app.users WHERE id = $1`
+	addUserQuery = `INSERT INTO todo_app.users (user_id, username) VALUES (DEFAULT, $1) RETURNING user_id, username`
+
+	getUserQuery = `SELECT user_id, username FROM todo_app.users WHERE user_id = $1`
+
+	getUsersQuery = `SELECT user_id, username FROM todo_app.users ORDER BY user_id LIMIT $1 OFFSET $2`
+
+	countUsersQuery = `SELECT count(*) FROM todo_app.users`
+
+	updateUserQuery = `UPDATE todo_app.users SET username = $1 WHERE user_id = $2`
+
+	// todos are removed by ON DELETE CASCADE
+	deleteUserQuery = `DELETE FROM todo_app.users WHERE user_id = $1`
+
+	// TAGS Query
+	touchToDoQuery = `
+		UPDATE todo_app.todo_list SET version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING user_id`
+
+	addTagsQuery = `
+		INSERT INTO todo_app.tags (user_id, name)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT (user_id, name) DO NOTHING`
+
+	addToDoTagsQuery = `
+		INSERT INTO todo_app.todo_tags (todo_id, tag_id)
+		SELECT $1, id FROM todo_app.tags WHERE user_id = $2 AND name = ANY($3)
+		ON CONFLICT DO NOTHING`
+
+	removeToDoTagQuery = `
+		DELETE FROM todo_app.todo_tags tt USING todo_app.tags g
+		WHERE tt.tag_id = g.id AND tt.todo_id = $1 AND g.user_id = $2 AND g.name = $3`
+
+	removeUnusedTagQuery = `
+		DELETE FROM todo_app.tags g
+		WHERE g.user_id = $1 AND g.name = $2
+			AND NOT EXISTS (SELECT 1 FROM todo_app.todo_tags tt WHERE tt.tag_id = g.id)`
+
+	getTagsQuery = `
+		SELECT g.name, count(t.id) FROM todo_app.tags g
+		LEFT JOIN todo_app.todo_tags tt ON tt.tag_id = g.id
+		LEFT JOIN todo_app.todo_list t ON t.id = tt.todo_id AND t.deleted_at IS NULL
+		WHERE g.user_id = $1
+		GROUP BY g.name
+		ORDER BY g.name`
+
+	// LISTS Query
+	listColumns = `id, user_id, name, color, archived, created_at`
+
+	addListQuery = `
+		INSERT INTO todo_app.lists
+			(id, user_id, name, color, archived, created_at)
+		VALUES
+			(DEFAULT, $1, $2, $3, $4, DEFAULT)
+		RETURNING ` + listColumns
+
+	getListQuery = `SELECT ` + listColumns + ` FROM todo_app.lists WHERE id = $1`
+
+	getListsQuery = `
+		SELECT ` + listColumns + ` FROM todo_app.lists
+		WHERE user_id = $1 AND (NOT archived OR $2)
+		ORDER BY name, id`
+
+	updateListQuery = `
+		UPDATE todo_app.lists SET name = $2, color = $3, archived = $4
+		WHERE id = $1
+		RETURNING ` + listColumns
+
+	// todos of the list move to the inbox by ON DELETE SET NULL
+	deleteListQuery = `DELETE FROM todo_app.lists WHERE id = $1`
+
+	// API_TOKENS Query
+	addAPITokenQuery = `
+		INSERT INTO todo_app.api_tokens
+			(id, user_id, name, token_hash, created_at)
+		VALUES
+			(DEFAULT, $1, $2, $3, DEFAULT)
+		RETURNING id, user_id, name, created_at`
+
+	getAPITokensQuery = `SELECT id, user_id, name, created_at FROM todo_app.api_tokens WHERE user_id = $1 ORDER BY id`
+
+	getAPITokenUserQuery = `
+		SELECT u.user_id, u.username FROM todo_app.api_tokens t
+		JOIN todo_app.users u ON u.user_id = t.user_id
+		WHERE t.token_hash = $1`
+
+	deleteAPITokenQuery = `DELETE FROM todo_app.api_tokens WHERE id = $1 AND user_id = $2`
 )
 
 type StorageConfig struct {
 	Driver string `json:"driver"`
 	DSN    string `json:"dsn"`
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime size the connection pool,
+	// zero keeps the default of database/sql. SQLite uses a single connection.
+	MaxOpenConns    int           `json:"max_open_conns"`
+	MaxIdleConns    int           `json:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `json:"conn_max_lifetime"`
+	// StatementTimeout cancels the PostgreSQL statements that run longer, zero disables it.
+	StatementTimeout time.Duration `json:"statement_timeout"`
+	// ConnectRetries is how many times a failed first connection is retried,
+	// the delay between the attempts starts at ConnectRetryDelay and doubles.
+	ConnectRetries    int           `json:"connect_retries"`
+	ConnectRetryDelay time.Duration `json:"connect_retry_delay"`
 }
 
 func (c StorageConfig) Validate() error {
@@ -43,10 +261,26 @@ func (c StorageConfig) Validate() error {
 		errs = append(errs, errors.New("db driver cannot be empty"))
 	}
 
-	if len(c.DSN) == 0 {
+	if len(c.DSN) == 0 && c.Driver != DriverMemory {
 		errs = append(errs, errors.New("db DSN cannot be empty"))
 	}
 
+	if c.MaxOpenConns < 0 || c.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("db connection limits cannot be negative"))
+	}
+
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		errs = append(errs, errors.New("db max idle connections cannot exceed max open connections"))
+	}
+
+	if c.ConnMaxLifetime < 0 || c.StatementTimeout < 0 || c.ConnectRetryDelay < 0 {
+		errs = append(errs, errors.New("db durations cannot be negative"))
+	}
+
+	if c.ConnectRetries < 0 {
+		errs = append(errs, errors.New("db connect retries cannot be negative"))
+	}
+
 	if len(errs) != 0 {
 		return errors.Errorf("validate errors - %v", errs)
 	}
@@ -56,96 +290,1225 @@ func (c StorageConfig) Validate() error {
 type pgDatabase struct {
 	cgf StorageConfig
 	db  *sql.DB
+	// conn runs the queries, it is db or tx.
+	conn querier
+	// tx is set for the storage of WithTx.
+	tx *sql.Tx
+}
+
+// querier runs queries on a database or in a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txn is the transaction of a method that changes several rows.
+type txn interface {
+	querier
+	Commit() error
+	Rollback() error
+}
+
+// boundTx runs the transactions of the methods in the transaction of WithTx,
+// WithTx commits or rolls it back.
+type boundTx struct {
+	*sql.Tx
+}
+
+func (boundTx) Commit() error   { return nil }
+func (boundTx) Rollback() error { return nil }
+
+func (pg *pgDatabase) begin(ctx context.Context) (txn, error) {
+	if pg.tx != nil {
+		return boundTx{pg.tx}, nil
+	}
+	return pg.db.BeginTx(ctx, nil)
+}
+
+// Retries of WithTx, the delay grows with every attempt.
+const (
+	maxTxAttempts = 5
+	txRetryDelay  = 20 * time.Millisecond
+)
+
+func (pg *pgDatabase) WithTx(ctx context.Context, fn func(Storage) error) error {
+	if pg.tx != nil {
+		return fn(pg)
+	}
+	for attempt := 1; ; attempt++ {
+		err := pg.runTx(ctx, fn)
+		if !isSerializationFailure(err) {
+			return err
+		}
+		if attempt == maxTxAttempts {
+			log.Warn("transaction failed after retries: ", err)
+			return api.Conflictf("the change conflicted with concurrent changes, try again")
+		}
+		log.Debugf("Retrying transaction after %v.", err)
+		// The jitter keeps the conflicting transactions from retrying in lockstep.
+		delay := time.Duration(attempt)*txRetryDelay + time.Duration(rand.Int63n(int64(txRetryDelay)))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (pg *pgDatabase) runTx(ctx context.Context, fn func(Storage) error) error {
+	tx, err := pg.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	if err := fn(&pgDatabase{cgf: pg.cgf, db: pg.db, conn: tx, tx: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return translateError(err, "commit transaction")
+	}
+	return nil
+}
+
+// errBatchFailed rolls back an atomic batch.
+var errBatchFailed = errors.New("batch operation failed")
+
+func (pg *pgDatabase) Batch(ctx context.Context, ops []func(Storage) error, atomic bool) ([]error, error) {
+	if pg.tx != nil {
+		return nil, errors.New("batches cant run in a transaction")
+	}
+	var errs []error
+	err := pg.WithTx(ctx, func(storage Storage) error {
+		tx := storage.(*pgDatabase).tx
+		errs = make([]error, len(ops))
+		for i, op := range ops {
+			if atomic {
+				if errs[i] = op(storage); errs[i] != nil {
+					if isSerializationFailure(errs[i]) {
+						return errs[i]
+					}
+					return errBatchFailed
+				}
+				continue
+			}
+
+			// A failed statement aborts the transaction, the savepoint undoes only the failed operation.
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_operation"); err != nil {
+				return errors.Wrap(err, "create savepoint")
+			}
+			undo := "RELEASE SAVEPOINT batch_operation"
+			if errs[i] = op(storage); errs[i] != nil {
+				// Rolling back to the savepoint does not resolve a serialization failure, the whole batch is retried.
+				if isSerializationFailure(errs[i]) {
+					return errs[i]
+				}
+				undo = "ROLLBACK TO SAVEPOINT batch_operation"
+			}
+			if _, err := tx.ExecContext(ctx, undo); err != nil {
+				return errors.Wrap(err, "end savepoint")
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchFailed) {
+		return nil, err
+	}
+	return errs, nil
 }
 
 func (pg *pgDatabase) initializeDatabase(ctx context.Context) error {
-	db, err := sql.Open(pg.cgf.Driver, pg.cgf.DSN)
+	connector, err := pq.NewConnector(pg.cgf.DSN)
 	if err != nil {
 		return errors.Wrapf(err, "open(%s) database connection", pg.cgf.Driver)
 	}
-	pg.db = db
-	if err := pg.db.PingContext(ctx); err != nil {
-		return errors.Wrap(err, "ping database")
+	db := sql.OpenDB(withStatementTimeout(connector, pg.cgf.StatementTimeout))
+	db.SetMaxOpenConns(pg.cgf.MaxOpenConns)
+	if pg.cgf.MaxIdleConns > 0 {
+		// Zero would close every idle connection instead of keeping the default.
+		db.SetMaxIdleConns(pg.cgf.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(pg.cgf.ConnMaxLifetime)
+	pg.db, pg.conn = db, db
+	if err := connect(ctx, pg.db, pg.cgf); err != nil {
+		return err
 	}
 	log.Info("Successfully connected to database.")
 	return nil
 }
 
-func (pg *pgDatabase) CreateToDo(ctx context.Context, todo api.ToDo) error {
-	result, err := pg.db.ExecContext(ctx, addToDoQuery, todo.UserID, todo.Message)
+func (pg *pgDatabase) Ping(ctx context.Context) error {
+	return pg.db.PingContext(ctx)
+}
+
+func (pg *pgDatabase) Stats() sql.DBStats {
+	return pg.db.Stats()
+}
+
+func (pg *pgDatabase) CreateToDo(ctx context.Context, todo api.ToDo) (*api.ToDo, error) {
+	priority, err := priorityRank(todo.Priority)
 	if err != nil {
-		return errors.Wrap(err, "insert todo to database")
+		return nil, err
 	}
+	created, err := scanToDo(pg.conn.QueryRowContext(ctx, addToDoQuery,
+		todo.UserID, todo.Message, todo.DueAt, todo.ReminderAt, priority, todo.Position, todo.ListID, todo.ParentID,
+		todo.Recurrence, todo.SeriesID, todo.SeriesStart))
+	if err != nil {
+		return nil, translateError(err, "insert todo to database")
+	}
+	log.Debugf("Successfully inserted materialization instance to database.")
+	return created, nil
+}
 
-	rows, err := result.RowsAffected()
+func (pg *pgDatabase) UpdateToDo(ctx context.Context, todo api.ToDo, cond api.Precondition) (*api.ToDo, error) {
+	priority, err := priorityRank(todo.Priority)
 	if err != nil {
-		return errors.Wrap(err, "insert todo to database, cant return rows affected")
+		return nil, err
 	}
+	args := []interface{}{todo.ID, todo.Message, todo.DueAt, todo.ReminderAt, priority, todo.ListID, todo.ParentID}
+	query := fmt.Sprintf(updateToDoQuery, pgDialect.preconditionSQL(cond, &args))
+	updated, err := scanToDo(pg.conn.QueryRowContext(ctx, query, args...))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, pg.missingOrChanged(ctx, todo.ID)
+	case err != nil:
+		return nil, translateError(err, "update todo in database")
+	}
+	log.Debugf("Successfully updated materialization instance to database.")
+	return updated, nil
+}
 
-	if rows != 1 {
-		return fmt.Errorf("expected to affect 1 row, affected %d", rows)
+// PatchToDo updates only the fields set in update, so concurrent changes
+// of other fields are kept.
+func (pg *pgDatabase) PatchToDo(ctx context.Context, todoID int64, update api.ToDoUpdate, cond api.Precondition) (*api.ToDo, error) {
+	args := []interface{}{todoID}
+	sets, err := updateSetSQL(update, &args)
+	if err != nil {
+		return nil, err
 	}
-	log.Debugf("Successfully inserted materialization instance to database.")
-	return nil
+	if len(sets) == 0 {
+		todo, err := pg.GetToDo(ctx, todoID)
+		if err != nil {
+			return nil, err
+		}
+		return unchangedToDo(todo, cond)
+	}
+
+	query := fmt.Sprintf(patchToDoQuery, strings.Join(sets, ", "), pgDialect.preconditionSQL(cond, &args))
+	patched, err := scanToDo(pg.conn.QueryRowContext(ctx, query, args...))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, pg.missingOrChanged(ctx, todoID)
+	case err != nil:
+		return nil, translateError(err, "patch todo in database")
+	}
+	log.Debugf("Successfully patched todo in database.")
+	return patched, nil
 }
 
-func (pg *pgDatabase) UpdateToDo(ctx context.Context, todo api.ToDo) error {
-	result, err := pg.db.ExecContext(ctx, updateToDoQuery, todo.Message, todo.ID)
+func (pg *pgDatabase) UpdateSeries(ctx context.Context, seriesID int64, update api.ToDoUpdate) (int64, error) {
+	args := []interface{}{seriesID}
+	sets, err := updateSetSQL(update, &args)
 	if err != nil {
-		return errors.Wrap(err, "update todo in database")
+		return 0, err
+	}
+	if len(sets) == 0 {
+		return 0, nil
 	}
 
+	result, err := pg.conn.ExecContext(ctx, fmt.Sprintf(updateSeriesQuery, strings.Join(sets, ", ")), args...)
+	if err != nil {
+		return 0, translateError(err, "update series in database")
+	}
 	rows, err := result.RowsAffected()
 	if err != nil {
-		return errors.Wrap(err, "update todo in database, cant return rows affected")
+		return 0, errors.Wrap(err, "update series in database, cant return rows affected")
 	}
+	log.Debugf("Successfully updated series in database.")
+	return rows, nil
+}
 
-	if rows != 1 {
-		return fmt.Errorf("expected to affect 1 row, affected %d", rows)
+// updateSetSQL returns the SET list of the fields set in update and appends their values to args.
+func updateSetSQL(update api.ToDoUpdate, args *[]interface{}) ([]string, error) {
+	sets := []string{}
+	set := func(column string, value interface{}) {
+		*args = append(*args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(*args)))
 	}
-	log.Debugf("Successfully updated materialization instance to database.")
-	return nil
+	if update.Message != nil {
+		set("message", *update.Message)
+	}
+	if update.DueAt != nil {
+		set("due_at", update.DueAt.Time)
+	}
+	if update.ReminderAt != nil {
+		set("reminder_at", update.ReminderAt.Time)
+	}
+	if update.Priority != nil {
+		priority, err := priorityRank(*update.Priority)
+		if err != nil {
+			return nil, err
+		}
+		set("priority", priority)
+	}
+	if update.ListID != nil {
+		set("list_id", update.ListID.ID)
+	}
+	if update.ParentID != nil {
+		set("parent_id", update.ParentID.ID)
+	}
+	if update.Status != nil {
+		set("status", *update.Status)
+	}
+	if update.CompletedAt != nil {
+		set("completed_at", update.CompletedAt.Time)
+	}
+	if update.Position != nil {
+		set("position", *update.Position)
+	}
+	if update.Recurrence != nil {
+		set("recurrence", *update.Recurrence)
+	}
+	if update.SeriesStart != nil {
+		set("series_start", update.SeriesStart.Time)
+	}
+	return sets, nil
 }
 
-func (pg *pgDatabase) DeleteToDo(ctx context.Context, todoID int64) error {
-	_, err := pg.db.ExecContext(ctx, deleteTODOQuery, todoID)
+func (pg *pgDatabase) DeleteToDo(ctx context.Context, todoID int64, cond api.Precondition) error {
+	tx, err := pg.begin(ctx)
 	if err != nil {
-		return errors.Wrap(err, "delete spec data in database")
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{todoID}
+	query := fmt.Sprintf(deleteTODOQuery, pgDialect.preconditionSQL(cond, &args))
+	var deletedAt time.Time
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&deletedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return pg.missingOrChanged(ctx, todoID)
+	case err != nil:
+		return errors.Wrap(err, "delete todo in database")
+	}
+	// Subtasks go to the trash with the same deleted_at, so they can be restored together.
+	if _, err := tx.ExecContext(ctx, trashSubtasksQuery, todoID, nil, deletedAt); err != nil {
+		return errors.Wrap(err, "delete subtasks in database")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit transaction")
 	}
 	log.Debugf("Successfully deleted todo in database.")
 
 	return nil
 }
 
+func (pg *pgDatabase) RestoreToDo(ctx context.Context, userID, todoID int64) (*api.ToDo, error) {
+	tx, err := pg.begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	err = tx.QueryRowContext(ctx, getDeletedAtQuery, todoID, userID).Scan(&deletedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("todo %d not found in trash", todoID)
+	case err != nil:
+		return nil, errors.Wrap(err, "query error")
+	}
+	if _, err := tx.ExecContext(ctx, restoreSubtasksQuery, todoID, deletedAt); err != nil {
+		return nil, errors.Wrap(err, "restore subtasks in database")
+	}
+	todo, err := scanToDo(tx.QueryRowContext(ctx, restoreToDoQuery, todoID))
+	if err != nil {
+		return nil, errors.Wrap(err, "restore todo in database")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit transaction")
+	}
+	log.Debugf("Successfully restored todo in database.")
+	return todo, nil
+}
+
+func (pg *pgDatabase) GetSubtasks(ctx context.Context, parentID int64) ([]api.ToDo, error) {
+	rows, err := pg.conn.QueryContext(ctx, getSubtasksQuery, parentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	return scanToDos(rows)
+}
+
+func (pg *pgDatabase) GetAncestorIDs(ctx context.Context, todoID int64) ([]int64, error) {
+	rows, err := pg.conn.QueryContext(ctx, getAncestorIDsQuery, todoID)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "scan todo id")
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate todo ids")
+	}
+	return ids, nil
+}
+
+func (pg *pgDatabase) PurgeToDos(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := pg.conn.ExecContext(ctx, purgeToDosQuery, deletedBefore)
+	if err != nil {
+		return 0, errors.Wrap(err, "purge todos in database")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "purge todos in database, cant return rows affected")
+	}
+	return rows, nil
+}
+
 func (pg *pgDatabase) GetToDo(ctx context.Context, todoID int64) (*api.ToDo, error) {
-	var todo api.ToDo
-	err := pg.db.QueryRowContext(ctx, getToDoQuery, todoID).Scan(
+	todo, err := scanToDo(pg.conn.QueryRowContext(ctx, getToDoQuery, todoID))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("todo %d not found", todoID)
+	case err != nil:
+		return nil, errors.Wrap(err, "query error")
+	}
+	return todo, nil
+}
+
+func (pg *pgDatabase) GetToDos(ctx context.Context, userID int64, filter ToDoFilter, limit, offset int64) ([]api.ToDo, error) {
+	args := []interface{}{}
+	where := pgDialect.filterSQL(userID, filter, &args)
+	args = append(args, limit, offset)
+	page := fmt.Sprintf("LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := pg.conn.QueryContext(ctx, fmt.Sprintf(listToDosQuery, where, orderSQL(filter), page), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	return scanToDos(rows)
+}
+
+func (pg *pgDatabase) GetToDosAfter(ctx context.Context, userID int64, filter ToDoFilter, after *ToDoKey, limit int64) ([]api.ToDo, error) {
+	args := []interface{}{}
+	where := pgDialect.filterSQL(userID, filter, &args)
+	if after != nil {
+		afterSQL, err := afterKeySQL(filter, *after, &args)
+		if err != nil {
+			return nil, err
+		}
+		where += afterSQL
+	}
+	args = append(args, limit)
+	page := fmt.Sprintf("LIMIT $%d", len(args))
+
+	rows, err := pg.conn.QueryContext(ctx, fmt.Sprintf(listToDosQuery, where, orderSQL(filter), page), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	return scanToDos(rows)
+}
+
+func (pg *pgDatabase) LastPosition(ctx context.Context, userID int64) (string, error) {
+	var position string
+	if err := pg.conn.QueryRowContext(ctx, lastPositionQuery, userID).Scan(&position); err != nil {
+		return "", errors.Wrap(err, "query last position")
+	}
+	return position, nil
+}
+
+func (pg *pgDatabase) AdjacentPosition(ctx context.Context, userID int64, position string, before bool) (string, error) {
+	query := positionAfterQuery
+	if before {
+		query = positionBeforeQuery
+	}
+	var adjacent string
+	if err := pg.conn.QueryRowContext(ctx, query, userID, position).Scan(&adjacent); err != nil {
+		return "", errors.Wrap(err, "query adjacent position")
+	}
+	return adjacent, nil
+}
+
+func (pg *pgDatabase) AddToDoTags(ctx context.Context, todoID int64, tags []string) (*api.ToDo, error) {
+	tx, err := pg.begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, touchToDoQuery, todoID).Scan(&userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("todo %d not found", todoID)
+	case err != nil:
+		return nil, errors.Wrap(err, "update todo version")
+	}
+	if _, err := tx.ExecContext(ctx, addTagsQuery, userID, pq.Array(tags)); err != nil {
+		return nil, translateError(err, "insert tags to database")
+	}
+	if _, err := tx.ExecContext(ctx, addToDoTagsQuery, todoID, userID, pq.Array(tags)); err != nil {
+		return nil, translateError(err, "insert todo tags to database")
+	}
+	todo, err := scanToDo(tx.QueryRowContext(ctx, getToDoQuery, todoID))
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit transaction")
+	}
+	log.Debugf("Successfully added todo tags in database.")
+	return todo, nil
+}
+
+func (pg *pgDatabase) RemoveToDoTag(ctx context.Context, todoID int64, tag string) (*api.ToDo, error) {
+	tx, err := pg.begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, touchToDoQuery, todoID).Scan(&userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("todo %d not found", todoID)
+	case err != nil:
+		return nil, errors.Wrap(err, "update todo version")
+	}
+	result, err := tx.ExecContext(ctx, removeToDoTagQuery, todoID, userID, tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "delete todo tag in database")
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, errors.Wrap(err, "delete todo tag in database, cant return rows affected")
+	}
+	if rows != 1 {
+		return nil, api.NotFoundf("todo %d has no tag %q", todoID, tag)
+	}
+	if _, err := tx.ExecContext(ctx, removeUnusedTagQuery, userID, tag); err != nil {
+		return nil, errors.Wrap(err, "delete unused tag in database")
+	}
+	todo, err := scanToDo(tx.QueryRowContext(ctx, getToDoQuery, todoID))
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit transaction")
+	}
+	log.Debugf("Successfully removed todo tag in database.")
+	return todo, nil
+}
+
+func (pg *pgDatabase) GetTags(ctx context.Context, userID int64) ([]api.Tag, error) {
+	rows, err := pg.conn.QueryContext(ctx, getTagsQuery, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	tags := []api.Tag{}
+	for rows.Next() {
+		var tag api.Tag
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, errors.Wrap(err, "scan tag")
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate tags")
+	}
+	return tags, nil
+}
+
+func (pg *pgDatabase) SearchToDos(ctx context.Context, userID int64, terms []SearchTerm, limit, offset int64) ([]api.SearchResult, int64, error) {
+	if len(terms) == 0 {
+		return []api.SearchResult{}, 0, nil
+	}
+
+	var total int64
+	countArgs := []interface{}{userID}
+	err := pg.conn.QueryRowContext(ctx, fmt.Sprintf(countSearchQuery, tsQuerySQL(terms, &countArgs)), countArgs...).Scan(&total)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "count search results")
+	}
+
+	args := []interface{}{userID, limit, offset}
+	rows, err := pg.conn.QueryContext(ctx, fmt.Sprintf(searchToDosQuery, tsQuerySQL(terms, &args)), args...)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	results := []api.SearchResult{}
+	for rows.Next() {
+		var result api.SearchResult
+		todo, err := scanToDo(searchRow{rows: rows, extra: []interface{}{&result.Snippet, &result.Rank}})
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "scan search result")
+		}
+		result.ToDo = *todo
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, errors.Wrap(err, "iterate search results")
+	}
+	return results, total, nil
+}
+
+// tsQuerySQL returns the tsquery matching all terms and appends the term texts to args.
+func tsQuerySQL(terms []SearchTerm, args *[]interface{}) string {
+	queries := []string{}
+	for _, term := range terms {
+		*args = append(*args, term.Text)
+		switch {
+		case term.Phrase:
+			queries = append(queries, fmt.Sprintf("phraseto_tsquery('english', $%d)", len(*args)))
+		case term.Prefix:
+			queries = append(queries, fmt.Sprintf("to_tsquery('english', $%d || ':*')", len(*args)))
+		default:
+			queries = append(queries, fmt.Sprintf("plainto_tsquery('english', $%d)", len(*args)))
+		}
+	}
+	return strings.Join(queries, " && ")
+}
+
+// searchRow scans the columns that follow toDoColumns into extra.
+type searchRow struct {
+	rows  *sql.Rows
+	extra []interface{}
+}
+
+func (r searchRow) Scan(dest ...interface{}) error {
+	return r.rows.Scan(append(dest, r.extra...)...)
+}
+
+// sortColumns are the columns of the Sort fields.
+var sortColumns = map[string]string{
+	SortCreated:   "created_at",
+	SortUpdated:   "updated_at",
+	SortDue:       "due_at",
+	SortCompleted: "completed_at",
+	SortPriority:  "priority",
+	SortPosition:  "position",
+}
+
+// orderSQL returns the ORDER BY list of the filter's order.
+func orderSQL(filter ToDoFilter) string {
+	if len(filter.Sort) > 0 {
+		keys := []string{}
+		for _, key := range filter.Sort {
+			direction := "ASC"
+			if key.Desc {
+				direction = "DESC"
+			}
+			keys = append(keys, fmt.Sprintf("%s %s NULLS LAST", sortColumns[key.Field], direction))
+		}
+		return strings.Join(append(keys, "id"), ", ")
+	}
+
+	switch filter.Order {
+	case OrderPosition:
+		return "position, id"
+	case OrderPriority:
+		return "priority DESC, position, id"
+	default:
+		return "created_at, id"
+	}
+}
+
+// afterKeySQL returns the condition selecting the todos that follow key in order
+// and appends its arguments to args.
+func afterKeySQL(filter ToDoFilter, key ToDoKey, args *[]interface{}) (string, error) {
+	if len(filter.Sort) > 0 {
+		return afterSortKeySQL(filter.Sort, key, args)
+	}
+
+	switch filter.Order {
+	case OrderPosition:
+		*args = append(*args, key.Position, key.ID)
+		return fmt.Sprintf(" AND (position, id) > ($%d, $%d)", len(*args)-1, len(*args)), nil
+	case OrderPriority:
+		priority, err := priorityRank(key.Priority)
+		if err != nil {
+			return "", err
+		}
+		*args = append(*args, priority, key.Position, key.ID)
+		n := len(*args)
+		return fmt.Sprintf(" AND (priority < $%d OR priority = $%d AND (position, id) > ($%d, $%d))", n-2, n-2, n-1, n), nil
+	default:
+		*args = append(*args, key.CreatedAt, key.ID)
+		return fmt.Sprintf(" AND (created_at, id) > ($%d, $%d)", len(*args)-1, len(*args)), nil
+	}
+}
+
+// afterSortKeySQL is afterKeySQL for ToDoFilter.Sort. A todo follows key when it has the same
+// values for the first sort fields and a later one for the next field, or the same values and a greater id.
+func afterSortKeySQL(sort []SortKey, key ToDoKey, args *[]interface{}) (string, error) {
+	alternatives := []string{}
+	equal := []string{}
+	for _, s := range sort {
+		column, ok := sortColumns[s.Field]
+		if !ok {
+			return "", api.Validationf("unknown sort field %q", s.Field)
+		}
+		value, err := sortValue(s.Field, key)
+		if err != nil {
+			return "", err
+		}
+		if value == nil {
+			// Missing values come last, only the todos that miss it too can follow.
+			equal = append(equal, column+" IS NULL")
+			continue
+		}
+
+		*args = append(*args, value)
+		op := ">"
+		if s.Desc {
+			op = "<"
+		}
+		later := fmt.Sprintf("(%s %s $%d OR %s IS NULL)", column, op, len(*args), column)
+		alternatives = append(alternatives, "("+strings.Join(append(equal, later), " AND ")+")")
+		equal = append(equal, fmt.Sprintf("%s = $%d", column, len(*args)))
+	}
+	*args = append(*args, key.ID)
+	alternatives = append(alternatives, "("+strings.Join(append(equal, fmt.Sprintf("id > $%d", len(*args))), " AND ")+")")
+	return " AND (" + strings.Join(alternatives, " OR ") + ")", nil
+}
+
+// sortValue returns the value of the sort field in key, nil when the todo has none.
+func sortValue(field string, key ToDoKey) (interface{}, error) {
+	switch field {
+	case SortCreated:
+		return key.CreatedAt, nil
+	case SortUpdated:
+		return key.UpdatedAt, nil
+	case SortDue:
+		if key.DueAt == nil {
+			return nil, nil
+		}
+		return *key.DueAt, nil
+	case SortCompleted:
+		if key.CompletedAt == nil {
+			return nil, nil
+		}
+		return *key.CompletedAt, nil
+	case SortPriority:
+		return priorityRank(key.Priority)
+	default:
+		return key.Position, nil
+	}
+}
+
+// priorities are stored by their index, so they sort by urgency.
+var priorities = []string{api.PriorityLow, api.PriorityNormal, api.PriorityHigh, api.PriorityUrgent}
+
+func priorityRank(priority string) (int, error) {
+	for rank, p := range priorities {
+		if p == priority {
+			return rank, nil
+		}
+	}
+	return 0, api.Validationf("unknown priority %q", priority)
+}
+
+// dialect writes the parts of the filters and preconditions that differ between the databases.
+type dialect struct {
+	// schema prefixes the table names.
+	schema string
+	// in returns the condition that expr is one of values, a slice, and appends the values to args.
+	in func(expr string, values interface{}, args *[]interface{}) string
+	// notIn returns the condition that expr is none of values like in.
+	notIn func(expr string, values interface{}, args *[]interface{}) string
+	// search returns the condition that the message matches all terms and appends its arguments to args.
+	search func(terms []SearchTerm, args *[]interface{}) string
+}
+
+var pgDialect = dialect{
+	schema: "todo_app.",
+	in: func(expr string, values interface{}, args *[]interface{}) string {
+		*args = append(*args, pq.Array(values))
+		return fmt.Sprintf("%s = ANY($%d)", expr, len(*args))
+	},
+	notIn: func(expr string, values interface{}, args *[]interface{}) string {
+		*args = append(*args, pq.Array(values))
+		return fmt.Sprintf("%s <> ALL($%d)", expr, len(*args))
+	},
+	search: func(terms []SearchTerm, args *[]interface{}) string {
+		return fmt.Sprintf("search_vector @@ (%s)", tsQuerySQL(terms, args))
+	},
+}
+
+// filterSQL returns the WHERE conditions selecting the user's todos that match filter
+// and appends their arguments to args.
+func (d dialect) filterSQL(userID int64, filter ToDoFilter, args *[]interface{}) string {
+	cond := func(format string, value interface{}) string {
+		*args = append(*args, value)
+		return fmt.Sprintf(format, len(*args))
+	}
+
+	conditions := []string{cond("user_id = $%d", userID)}
+	if filter.Deleted {
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if filter.DueFrom != nil {
+		conditions = append(conditions, cond("due_at >= $%d", *filter.DueFrom))
+	}
+	if filter.DueTo != nil {
+		conditions = append(conditions, cond("due_at < $%d", *filter.DueTo))
+	}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, cond("created_at >= $%d", *filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		conditions = append(conditions, cond("created_at < $%d", *filter.CreatedTo))
+	}
+	if filter.UpdatedFrom != nil {
+		conditions = append(conditions, cond("updated_at >= $%d", *filter.UpdatedFrom))
+	}
+	if filter.UpdatedTo != nil {
+		conditions = append(conditions, cond("updated_at < $%d", *filter.UpdatedTo))
+	}
+	if filter.CompletedFrom != nil {
+		conditions = append(conditions, cond("completed_at >= $%d", *filter.CompletedFrom))
+	}
+	if filter.CompletedTo != nil {
+		conditions = append(conditions, cond("completed_at < $%d", *filter.CompletedTo))
+	}
+	if filter.Priorities != nil {
+		// Unknown priorities match nothing.
+		ranks := []int64{}
+		for _, priority := range filter.Priorities {
+			if rank, err := priorityRank(priority); err == nil {
+				ranks = append(ranks, int64(rank))
+			}
+		}
+		conditions = append(conditions, d.in("priority", ranks, args))
+	}
+	if filter.Search != nil {
+		conditions = append(conditions, d.search(filter.Search, args))
+	}
+	if filter.Statuses != nil {
+		conditions = append(conditions, d.in("status", filter.Statuses, args))
+	}
+	if filter.ListID != nil {
+		conditions = append(conditions, cond("list_id = $%d", *filter.ListID))
+	}
+	if filter.SeriesID != nil {
+		conditions = append(conditions, cond("coalesce(series_id, id) = $%d AND recurrence <> ''", *filter.SeriesID))
+	}
+	if filter.Inbox {
+		conditions = append(conditions, "list_id IS NULL")
+	}
+	if filter.AnyTags != nil {
+		conditions = append(conditions, fmt.Sprintf(`id IN (
+			SELECT tt.todo_id FROM %[1]stodo_tags tt
+			JOIN %[1]stags g ON g.id = tt.tag_id
+			WHERE %[2]s)`, d.schema, d.in("g.name", filter.AnyTags, args)))
+	}
+	if filter.AllTags != nil {
+		// AllTags has no duplicates, so the todo must have as many matching tags as there are tags.
+		conditions = append(conditions, fmt.Sprintf(`(
+			SELECT count(*) FROM %[1]stodo_tags tt
+			JOIN %[1]stags g ON g.id = tt.tag_id
+			WHERE tt.todo_id = todo_list.id AND %[2]s) = %[3]d`, d.schema, d.in("g.name", filter.AllTags, args), len(filter.AllTags)))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// preconditionSQL returns the condition on the version column for cond and appends its arguments to args.
+func (d dialect) preconditionSQL(cond api.Precondition, args *[]interface{}) string {
+	var where strings.Builder
+	if cond.IfMatch != nil && !containsVersion(cond.IfMatch, api.AnyVersion) {
+		where.WriteString(" AND " + d.in("version", cond.IfMatch, args))
+	}
+	if cond.IfNoneMatch != nil {
+		if containsVersion(cond.IfNoneMatch, api.AnyVersion) {
+			where.WriteString(" AND FALSE")
+		} else {
+			where.WriteString(" AND " + d.notIn("version", cond.IfNoneMatch, args))
+		}
+	}
+	return where.String()
+}
+
+// matchesPrecondition is the condition of preconditionSQL.
+func matchesPrecondition(cond api.Precondition, version int64) bool {
+	if cond.IfMatch != nil && !containsVersion(cond.IfMatch, api.AnyVersion) && !containsVersion(cond.IfMatch, version) {
+		return false
+	}
+	if cond.IfNoneMatch != nil && (containsVersion(cond.IfNoneMatch, api.AnyVersion) || containsVersion(cond.IfNoneMatch, version)) {
+		return false
+	}
+	return true
+}
+
+// unchangedToDo returns todo for a change that sets nothing, the precondition still has to hold.
+func unchangedToDo(todo *api.ToDo, cond api.Precondition) (*api.ToDo, error) {
+	if !matchesPrecondition(cond, todo.Version) {
+		return nil, api.NewError(api.ErrPreconditionFailed, "todo %d has version %d", todo.ID, todo.Version)
+	}
+	return todo, nil
+}
+
+func containsVersion(versions []int64, version int64) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// missingOrChanged explains why a change of the todo touched no rows.
+func (pg *pgDatabase) missingOrChanged(ctx context.Context, todoID int64) error {
+	todo, err := pg.GetToDo(ctx, todoID)
+	if err != nil {
+		return err
+	}
+	return api.NewError(api.ErrPreconditionFailed, "todo %d has version %d", todoID, todo.Version)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanToDo reads a todo selected with toDoColumns.
+func scanToDo(row rowScanner) (*api.ToDo, error) {
+	return scanToDoWith(row, func(todo *api.ToDo) interface{} { return pq.Array(&todo.Tags) })
+}
+
+// scanToDoWith reads a todo, tags returns the destination of the tags column.
+func scanToDoWith(row rowScanner, tags func(todo *api.ToDo) interface{}) (*api.ToDo, error) {
+	var (
+		todo        api.ToDo
+		priority    int
+		done, total int64
+	)
+	err := row.Scan(
 		&todo.ID,
 		&todo.UserID,
 		&todo.CreatedAt,
 		&todo.UpdatedAt,
-		&todo.Message)
+		&todo.Message,
+		&todo.Version,
+		&todo.DueAt,
+		&todo.ReminderAt,
+		&todo.Status,
+		&todo.CompletedAt,
+		&todo.DeletedAt,
+		&priority,
+		&todo.Position,
+		&todo.ListID,
+		tags(&todo),
+		&todo.ParentID,
+		&done,
+		&total,
+		&todo.Recurrence,
+		&todo.SeriesID,
+		&todo.SeriesStart)
+	if err != nil {
+		return nil, err
+	}
+	if priority < 0 || priority >= len(priorities) {
+		return nil, errors.Errorf("unknown priority %d of todo %d", priority, todo.ID)
+	}
+	todo.Priority = priorities[priority]
+	if total > 0 {
+		todo.Progress = &api.Progress{Done: done, Total: total}
+	}
+	return &todo, nil
+}
+
+func scanToDos(rows *sql.Rows) ([]api.ToDo, error) {
+	defer rows.Close()
+
+	todos := []api.ToDo{}
+	for rows.Next() {
+		todo, err := scanToDo(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan todo")
+		}
+		todos = append(todos, *todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate todos")
+	}
+	return todos, nil
+}
+
+func (pg *pgDatabase) CountToDos(ctx context.Context, userID int64, filter ToDoFilter) (int64, error) {
+	args := []interface{}{}
+	query := fmt.Sprintf(countToDosQuery, pgDialect.filterSQL(userID, filter, &args))
+
+	var total int64
+	if err := pg.conn.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, errors.Wrap(err, "count todos")
+	}
+	return total, nil
+}
+
+func (pg *pgDatabase) CreateUser(ctx context.Context, user api.User) (*api.User, error) {
+	var created api.User
+	err := pg.conn.QueryRowContext(ctx, addUserQuery, user.Name).Scan(
+		&created.ID,
+		&created.Name)
+	if err != nil {
+		return nil, translateError(err, "insert user to database")
+	}
+	log.Debugf("Successfully inserted user to database.")
+	return &created, nil
+}
+
+func (pg *pgDatabase) GetUser(ctx context.Context, id int64) (*api.User, error) {
+	var user api.User
+	err := pg.conn.QueryRowContext(ctx, getUserQuery, id).Scan(
+		&user.ID,
+		&user.Name)
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
-		log.Printf("no todo with id %d\n", todoID)
-		return nil, nil
+		return nil, api.NotFoundf("user %d not found", id)
 	case err != nil:
 		return nil, errors.Wrap(err, "query error")
 	}
-	return &todo, nil
+	return &user, nil
 }
 
-func (pg *pgDatabase) GetUser(ctx context.Context, id int64) (*api.User, error) {
+func (pg *pgDatabase) GetUsers(ctx context.Context, limit, offset int64) ([]api.User, error) {
+	rows, err := pg.conn.QueryContext(ctx, getUsersQuery, limit, offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	users := []api.User{}
+	for rows.Next() {
+		var user api.User
+		if err := rows.Scan(&user.ID, &user.Name); err != nil {
+			return nil, errors.Wrap(err, "scan user")
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate users")
+	}
+	return users, nil
+}
+
+func (pg *pgDatabase) CountUsers(ctx context.Context) (int64, error) {
+	var total int64
+	if err := pg.conn.QueryRowContext(ctx, countUsersQuery).Scan(&total); err != nil {
+		return 0, errors.Wrap(err, "count users")
+	}
+	return total, nil
+}
+
+func (pg *pgDatabase) UpdateUser(ctx context.Context, user api.User) error {
+	result, err := pg.conn.ExecContext(ctx, updateUserQuery, user.Name, user.ID)
+	if err != nil {
+		return translateError(err, "update user in database")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "update user in database, cant return rows affected")
+	}
+
+	if rows != 1 {
+		return api.NotFoundf("user %d not found", user.ID)
+	}
+	log.Debugf("Successfully updated user in database.")
+	return nil
+}
+
+func (pg *pgDatabase) DeleteUser(ctx context.Context, id int64) error {
+	result, err := pg.conn.ExecContext(ctx, deleteUserQuery, id)
+	if err != nil {
+		return errors.Wrap(err, "delete user in database")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete user in database, cant return rows affected")
+	}
+
+	if rows != 1 {
+		return api.NotFoundf("user %d not found", id)
+	}
+	log.Debugf("Successfully deleted user in database.")
+
+	return nil
+}
+
+func (pg *pgDatabase) CreateList(ctx context.Context, list api.List) (*api.List, error) {
+	created, err := scanList(pg.conn.QueryRowContext(ctx, addListQuery, list.UserID, list.Name, list.Color, list.Archived))
+	if err != nil {
+		return nil, translateError(err, "insert list to database")
+	}
+	log.Debugf("Successfully inserted list to database.")
+	return created, nil
+}
+
+func (pg *pgDatabase) GetList(ctx context.Context, listID int64) (*api.List, error) {
+	list, err := scanList(pg.conn.QueryRowContext(ctx, getListQuery, listID))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("list %d not found", listID)
+	case err != nil:
+		return nil, errors.Wrap(err, "query error")
+	}
+	return list, nil
+}
+
+func (pg *pgDatabase) GetLists(ctx context.Context, userID int64, archived bool) ([]api.List, error) {
+	rows, err := pg.conn.QueryContext(ctx, getListsQuery, userID, archived)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	lists := []api.List{}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan list")
+		}
+		lists = append(lists, *list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate lists")
+	}
+	return lists, nil
+}
+
+func (pg *pgDatabase) UpdateList(ctx context.Context, list api.List) (*api.List, error) {
+	updated, err := scanList(pg.conn.QueryRowContext(ctx, updateListQuery, list.ID, list.Name, list.Color, list.Archived))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("list %d not found", list.ID)
+	case err != nil:
+		return nil, translateError(err, "update list in database")
+	}
+	log.Debugf("Successfully updated list in database.")
+	return updated, nil
+}
+
+func (pg *pgDatabase) DeleteList(ctx context.Context, listID int64) error {
+	result, err := pg.conn.ExecContext(ctx, deleteListQuery, listID)
+	if err != nil {
+		return errors.Wrap(err, "delete list in database")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete list in database, cant return rows affected")
+	}
+
+	if rows != 1 {
+		return api.NotFoundf("list %d not found", listID)
+	}
+	log.Debugf("Successfully deleted list in database.")
+	return nil
+}
+
+// scanList reads a list selected with listColumns.
+func scanList(row rowScanner) (*api.List, error) {
+	var list api.List
+	err := row.Scan(
+		&list.ID,
+		&list.UserID,
+		&list.Name,
+		&list.Color,
+		&list.Archived,
+		&list.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (pg *pgDatabase) CreateAPIToken(ctx context.Context, token api.APIToken, hash []byte) (*api.APIToken, error) {
+	var created api.APIToken
+	err := pg.conn.QueryRowContext(ctx, addAPITokenQuery, token.UserID, token.Name, hash).Scan(
+		&created.ID,
+		&created.UserID,
+		&created.Name,
+		&created.CreatedAt)
+	if err != nil {
+		return nil, translateError(err, "insert api token to database")
+	}
+	log.Debugf("Successfully inserted api token to database.")
+	return &created, nil
+}
+
+func (pg *pgDatabase) GetAPITokens(ctx context.Context, userID int64) ([]api.APIToken, error) {
+	rows, err := pg.conn.QueryContext(ctx, getAPITokensQuery, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	tokens := []api.APIToken{}
+	for rows.Next() {
+		var token api.APIToken
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &token.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, "scan api token")
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate api tokens")
+	}
+	return tokens, nil
+}
+
+func (pg *pgDatabase) GetAPITokenUser(ctx context.Context, hash []byte) (*api.User, error) {
 	var user api.User
-	err := pg.db.QueryRowContext(ctx, getUserQuery, id).Scan(
+	err := pg.conn.QueryRowContext(ctx, getAPITokenUserQuery, hash).Scan(
 		&user.ID,
 		&user.Name)
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
-		log.Printf("no todo with id %d\n", user.ID)
-		return nil, nil
+		return nil, api.NotFoundf("api token not found")
 	case err != nil:
 		return nil, errors.Wrap(err, "query error")
 	}
 	return &user, nil
 }
+
+func (pg *pgDatabase) DeleteAPIToken(ctx context.Context, userID, tokenID int64) error {
+	result, err := pg.conn.ExecContext(ctx, deleteAPITokenQuery, tokenID, userID)
+	if err != nil {
+		return errors.Wrap(err, "delete api token in database")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete api token in database, cant return rows affected")
+	}
+
+	if rows != 1 {
+		return api.NotFoundf("api token %d not found", tokenID)
+	}
+	log.Debugf("Successfully deleted api token in database.")
+
+	return nil
+}
@@ -0,0 +1,1211 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"to-do/api"
+	"to-do/database"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"modernc.org/sqlite"
+)
+
+// DriverSQLite keeps the data in a SQLite database file, the DSN is its path.
+const DriverSQLite = "sqlite"
+
+// sqliteNow is the current time in the text format of the times the driver writes,
+// the migrations use it too.
+const sqliteNow = `rtrim(rtrim(strftime('%Y-%m-%d %H:%M:%f', 'now'), '0'), '.') || '+00:00'`
+
+// The queries of sqliteDatabase mirror the ones of pgDatabase. The updated_at trigger runs after
+// the statement, so changed todos are read again instead of being returned by RETURNING.
+const (
+	sqliteToDoColumns = `id, user_id, created_at, updated_at, message, version, due_at, reminder_at, status, completed_at, deleted_at, priority, position, list_id,
+		(SELECT group_concat(g.name, char(31)) FROM todo_tags tt
+			JOIN tags g ON g.id = tt.tag_id
+			WHERE tt.todo_id = todo_list.id),
+		parent_id,
+		(SELECT count(*) FROM todo_list s
+			WHERE s.parent_id = todo_list.id AND s.deleted_at IS NULL AND s.status = 'done'),
+		(SELECT count(*) FROM todo_list s
+			WHERE s.parent_id = todo_list.id AND s.deleted_at IS NULL AND s.status <> 'cancelled'),
+		recurrence,
+		CASE WHEN recurrence <> '' THEN coalesce(series_id, id) END,
+		series_start`
+
+	// sqliteDeleteToDoQuery is completed with the precondition.
+	sqliteDeleteToDoQuery = `
+		UPDATE todo_list SET deleted_at = $2, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL%s`
+
+	sqliteSubtasksQuery = `
+		WITH RECURSIVE subtasks AS (
+			SELECT id FROM todo_list
+			WHERE parent_id = $1 AND deleted_at IS $2
+			UNION
+			SELECT t.id FROM todo_list t JOIN subtasks s ON t.parent_id = s.id
+			WHERE t.deleted_at IS $2
+		)
+		SELECT id FROM subtasks`
+
+	sqliteTrashSubtasksQuery = `
+		UPDATE todo_list SET deleted_at = $3, version = version + 1
+		WHERE id IN (` + sqliteSubtasksQuery + `)`
+
+	sqliteRestoreSubtasksQuery = `
+		UPDATE todo_list SET deleted_at = NULL, version = version + 1
+		WHERE id IN (` + sqliteSubtasksQuery + `)`
+
+	sqliteGetDeletedAtQuery = `
+		SELECT deleted_at FROM todo_list
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`
+
+	sqliteRestoreToDoQuery = `
+		UPDATE todo_list SET deleted_at = NULL, version = version + 1,
+			parent_id = (SELECT p.id FROM todo_list p WHERE p.id = todo_list.parent_id AND p.deleted_at IS NULL)
+		WHERE id = $1`
+
+	sqliteGetSubtasksQuery = `
+		SELECT ` + sqliteToDoColumns + ` FROM todo_list
+		WHERE parent_id = $1 AND deleted_at IS NULL
+		ORDER BY position, id`
+
+	sqliteGetAncestorIDsQuery = `
+		WITH RECURSIVE ancestors AS (
+			SELECT parent_id AS id, 1 AS depth FROM todo_list WHERE id = $1 AND parent_id IS NOT NULL
+			UNION ALL
+			SELECT t.parent_id, a.depth + 1 FROM todo_list t JOIN ancestors a ON t.id = a.id
+			-- the depth guards against a cycle that slipped into the table
+			WHERE t.parent_id IS NOT NULL AND a.depth < 1000
+		)
+		SELECT id FROM ancestors ORDER BY depth`
+
+	sqlitePurgeToDosQuery = `DELETE FROM todo_list WHERE deleted_at < $1`
+
+	sqliteAddToDoQuery = `
+		INSERT INTO todo_list
+			(user_id, message, due_at, reminder_at, priority, position, list_id, parent_id,
+			recurrence, series_id, series_start)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id`
+
+	sqliteGetToDoQuery = `SELECT ` + sqliteToDoColumns + ` FROM todo_list WHERE id = $1 AND deleted_at IS NULL`
+
+	// sqliteUpdateToDoQuery is completed with the precondition.
+	sqliteUpdateToDoQuery = `
+		UPDATE todo_list
+		SET message=$2, due_at=$3, reminder_at=$4, priority=$5, list_id=$6, parent_id=$7, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL%s`
+
+	// sqlitePatchToDoQuery is completed with the SET list of changed fields and the precondition.
+	sqlitePatchToDoQuery = `
+		UPDATE todo_list SET %s, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL%s`
+
+	// sqliteSearchToDosQuery matches the todos with the functions of registerSQLiteFunctions,
+	// $4 holds the search terms in JSON.
+	sqliteSearchToDosQuery = `
+		SELECT ` + sqliteToDoColumns + `,
+			todo_search_snippet(message, $4),
+			todo_search_rank(message, $4) AS rank
+		FROM todo_list
+		WHERE user_id = $1 AND deleted_at IS NULL AND todo_search_rank(message, $4) > 0
+		ORDER BY rank DESC, id
+		LIMIT $2 OFFSET $3`
+
+	sqliteCountSearchQuery = `
+		SELECT count(*) FROM todo_list
+		WHERE user_id = $1 AND deleted_at IS NULL AND todo_search_rank(message, $2) > 0`
+
+	// sqliteUpdateSeriesQuery is completed with the SET list of changed fields.
+	sqliteUpdateSeriesQuery = `
+		UPDATE todo_list SET %s, version = version + 1
+		WHERE coalesce(series_id, id) = $1 AND recurrence <> ''
+			AND deleted_at IS NULL AND status IN ('open', 'in_progress')`
+
+	// sqliteListToDosQuery is completed with the WHERE conditions, the ORDER BY list and the LIMIT/OFFSET clause.
+	sqliteListToDosQuery = `
+		SELECT ` + sqliteToDoColumns + ` FROM todo_list
+		WHERE %s
+		ORDER BY %s
+		%s`
+
+	sqliteLastPositionQuery = `
+		SELECT coalesce(max(position), '') FROM todo_list
+		WHERE user_id = $1 AND deleted_at IS NULL`
+
+	sqlitePositionBeforeQuery = `
+		SELECT coalesce(max(position), '') FROM todo_list
+		WHERE user_id = $1 AND deleted_at IS NULL AND position < $2`
+
+	sqlitePositionAfterQuery = `
+		SELECT coalesce(min(position), '') FROM todo_list
+		WHERE user_id = $1 AND deleted_at IS NULL AND position > $2`
+
+	// sqliteCountToDosQuery is completed with the WHERE conditions.
+	sqliteCountToDosQuery = `SELECT count(*) FROM todo_list WHERE %s`
+
+	sqliteAddUserQuery = `INSERT INTO users (username) VALUES ($1) RETURNING user_id, username`
+
+	sqliteGetUserQuery = `SELECT user_id, username FROM users WHERE user_id = $1`
+
+	sqliteGetUsersQuery = `SELECT user_id, username FROM users ORDER BY user_id LIMIT $1 OFFSET $2`
+
+	sqliteCountUsersQuery = `SELECT count(*) FROM users`
+
+	sqliteUpdateUserQuery = `UPDATE users SET username = $1 WHERE user_id = $2`
+
+	// todos are removed by ON DELETE CASCADE
+	sqliteDeleteUserQuery = `DELETE FROM users WHERE user_id = $1`
+
+	sqliteTouchToDoQuery = `
+		UPDATE todo_list SET version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING user_id`
+
+	// The WHERE clause keeps ON CONFLICT from being read as a join constraint.
+	sqliteAddTagsQuery = `
+		INSERT INTO tags (user_id, name)
+		SELECT $1, value FROM json_each($2) WHERE true
+		ON CONFLICT (user_id, name) DO NOTHING`
+
+	sqliteAddToDoTagsQuery = `
+		INSERT INTO todo_tags (todo_id, tag_id)
+		SELECT $1, id FROM tags WHERE user_id = $2 AND name IN (SELECT value FROM json_each($3))
+		ON CONFLICT DO NOTHING`
+
+	sqliteRemoveToDoTagQuery = `
+		DELETE FROM todo_tags
+		WHERE todo_id = $1 AND tag_id IN (SELECT id FROM tags WHERE user_id = $2 AND name = $3)`
+
+	sqliteRemoveUnusedTagQuery = `
+		DELETE FROM tags
+		WHERE user_id = $1 AND name = $2
+			AND NOT EXISTS (SELECT 1 FROM todo_tags tt WHERE tt.tag_id = tags.id)`
+
+	sqliteGetTagsQuery = `
+		SELECT g.name, count(t.id) FROM tags g
+		LEFT JOIN todo_tags tt ON tt.tag_id = g.id
+		LEFT JOIN todo_list t ON t.id = tt.todo_id AND t.deleted_at IS NULL
+		WHERE g.user_id = $1
+		GROUP BY g.name
+		ORDER BY g.name`
+
+	sqliteAddListQuery = `
+		INSERT INTO lists (user_id, name, color, archived)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + listColumns
+
+	sqliteGetListQuery = `SELECT ` + listColumns + ` FROM lists WHERE id = $1`
+
+	sqliteGetListsQuery = `
+		SELECT ` + listColumns + ` FROM lists
+		WHERE user_id = $1 AND (NOT archived OR $2)
+		ORDER BY name, id`
+
+	sqliteUpdateListQuery = `
+		UPDATE lists SET name = $2, color = $3, archived = $4
+		WHERE id = $1
+		RETURNING ` + listColumns
+
+	// todos of the list move to the inbox by ON DELETE SET NULL
+	sqliteDeleteListQuery = `DELETE FROM lists WHERE id = $1`
+
+	sqliteAddAPITokenQuery = `
+		INSERT INTO api_tokens (user_id, name, token_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, user_id, name, created_at`
+
+	sqliteGetAPITokensQuery = `SELECT id, user_id, name, created_at FROM api_tokens WHERE user_id = $1 ORDER BY id`
+
+	sqliteGetAPITokenUserQuery = `
+		SELECT u.user_id, u.username FROM api_tokens t
+		JOIN users u ON u.user_id = t.user_id
+		WHERE t.token_hash = $1`
+
+	sqliteDeleteAPITokenQuery = `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`
+)
+
+// sqliteDialect matches lists of values with json_each, the values are passed as one JSON array.
+var sqliteDialect = dialect{
+	in: func(expr string, values interface{}, args *[]interface{}) string {
+		*args = append(*args, jsonArray(values))
+		return fmt.Sprintf("%s IN (SELECT value FROM json_each($%d))", expr, len(*args))
+	},
+	notIn: func(expr string, values interface{}, args *[]interface{}) string {
+		*args = append(*args, jsonArray(values))
+		return fmt.Sprintf("%s NOT IN (SELECT value FROM json_each($%d))", expr, len(*args))
+	},
+	search: func(terms []SearchTerm, args *[]interface{}) string {
+		*args = append(*args, jsonArray(terms))
+		return fmt.Sprintf("todo_search_rank(message, $%d) > 0", len(*args))
+	},
+}
+
+// jsonArray encodes a slice of strings, numbers or search terms, nil is encoded as an empty array.
+func jsonArray(values interface{}) string {
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		// The values are plain slices, they always encode.
+		panic(err)
+	}
+	if string(encoded) == "null" {
+		return "[]"
+	}
+	return string(encoded)
+}
+
+func init() {
+	registerSQLiteFunctions()
+}
+
+// registerSQLiteFunctions adds the full-text search of memDatabase to SQLite, which has none
+// that approximates the one of Postgres. The functions take the message and the JSON of the terms.
+func registerSQLiteFunctions() {
+	search := func(args []driver.Value) (searchMatch, error) {
+		text, _ := args[0].(string)
+		encoded, _ := args[1].(string)
+		var terms []SearchTerm
+		if err := json.Unmarshal([]byte(encoded), &terms); err != nil {
+			return searchMatch{}, errors.Wrap(err, "decode search terms")
+		}
+		return matchSearch(text, terms), nil
+	}
+	err := sqlite.RegisterDeterministicScalarFunction("todo_search_rank", 2,
+		func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			match, err := search(args)
+			return match.rank, err
+		})
+	if err == nil {
+		err = sqlite.RegisterDeterministicScalarFunction("todo_search_snippet", 2,
+			func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+				match, err := search(args)
+				return match.snippet, err
+			})
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// sqliteDatabase is a Storage in a SQLite database, it behaves like pgDatabase.
+type sqliteDatabase struct {
+	cfg StorageConfig
+	db  *sql.DB
+	// conn runs the queries, it is db or tx.
+	conn querier
+	// tx is set for the storage of WithTx.
+	tx *sql.Tx
+}
+
+// sqliteQuerier passes times in UTC. SQLite stores times as text,
+// which compares in time order only with the same offset.
+type sqliteQuerier struct {
+	q querier
+}
+
+func (s sqliteQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.q.ExecContext(ctx, query, utcArgs(args)...)
+}
+
+func (s sqliteQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.q.QueryContext(ctx, query, utcArgs(args)...)
+}
+
+func (s sqliteQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.q.QueryRowContext(ctx, query, utcArgs(args)...)
+}
+
+func utcArgs(args []interface{}) []interface{} {
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch t := arg.(type) {
+		case time.Time:
+			converted[i] = t.UTC()
+		case *time.Time:
+			if t != nil {
+				converted[i] = t.UTC()
+			} else {
+				converted[i] = nil
+			}
+		default:
+			converted[i] = arg
+		}
+	}
+	return converted
+}
+
+type sqliteTxn struct {
+	sqliteQuerier
+	tx txn
+}
+
+func (t sqliteTxn) Commit() error   { return t.tx.Commit() }
+func (t sqliteTxn) Rollback() error { return t.tx.Rollback() }
+
+func (s *sqliteDatabase) begin(ctx context.Context) (txn, error) {
+	if s.tx != nil {
+		return sqliteTxn{sqliteQuerier{s.tx}, boundTx{s.tx}}, nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteTxn{sqliteQuerier{tx}, tx}, nil
+}
+
+// WithTx needs no retries, the transactions take the write lock when they begin
+// and the single connection runs them one after another.
+func (s *sqliteDatabase) WithTx(ctx context.Context, fn func(Storage) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	if err := fn(&sqliteDatabase{cfg: s.cfg, db: s.db, conn: sqliteQuerier{tx}, tx: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return translateError(err, "commit transaction")
+	}
+	return nil
+}
+
+func (s *sqliteDatabase) Batch(ctx context.Context, ops []func(Storage) error, atomic bool) ([]error, error) {
+	if s.tx != nil {
+		return nil, errors.New("batches cant run in a transaction")
+	}
+	var errs []error
+	err := s.WithTx(ctx, func(storage Storage) error {
+		tx := storage.(*sqliteDatabase).tx
+		errs = make([]error, len(ops))
+		for i, op := range ops {
+			if atomic {
+				if errs[i] = op(storage); errs[i] != nil {
+					return errBatchFailed
+				}
+				continue
+			}
+
+			// The savepoint undoes the statements of the failed operation that succeeded.
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_operation"); err != nil {
+				return errors.Wrap(err, "create savepoint")
+			}
+			if errs[i] = op(storage); errs[i] != nil {
+				if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_operation"); err != nil {
+					return errors.Wrap(err, "roll back savepoint")
+				}
+			}
+			// Unlike in Postgres the savepoint stays after a rollback to it.
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_operation"); err != nil {
+				return errors.Wrap(err, "end savepoint")
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchFailed) {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// sqliteDSN adds the settings of the storage to the DSN, a file name or a file: URI.
+// Transactions take the write lock when they begin, so they never fail to upgrade
+// a read lock, and times are written in a format that sorts.
+func sqliteDSN(dsn string) string {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate&_time_format=sqlite"
+}
+
+// initializeDatabase opens the database and applies the pending migrations of database.SQLiteMigrations.
+func (s *sqliteDatabase) initializeDatabase(ctx context.Context) error {
+	db, err := sql.Open(DriverSQLite, sqliteDSN(s.cfg.DSN))
+	if err != nil {
+		return errors.Wrapf(err, "open(%s) database connection", DriverSQLite)
+	}
+	// SQLite has a single writer, one connection queues the statements instead of failing
+	// them as busy. It also keeps a :memory: database, which belongs to its connection.
+	db.SetMaxOpenConns(1)
+	s.db, s.conn = db, sqliteQuerier{db}
+	if err := connect(ctx, s.db, s.cfg); err != nil {
+		return err
+	}
+
+	migrations, err := LoadMigrations(database.SQLiteMigrations())
+	if err != nil {
+		return err
+	}
+	if err := newMigrator(db, DriverSQLite, migrations).Up(ctx); err != nil {
+		return errors.Wrap(err, "migrate database")
+	}
+	log.Info("Successfully opened SQLite database.")
+	return nil
+}
+
+func (s *sqliteDatabase) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *sqliteDatabase) Stats() sql.DBStats {
+	return s.db.Stats()
+}
+
+func (s *sqliteDatabase) CreateToDo(ctx context.Context, todo api.ToDo) (*api.ToDo, error) {
+	priority, err := priorityRank(todo.Priority)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, sqliteAddToDoQuery,
+		todo.UserID, todo.Message, todo.DueAt, todo.ReminderAt, priority, todo.Position, todo.ListID, todo.ParentID,
+		todo.Recurrence, todo.SeriesID, todo.SeriesStart).Scan(&id)
+	if err != nil {
+		return nil, translateError(err, "insert todo to database")
+	}
+	created, err := scanSQLiteToDo(tx.QueryRowContext(ctx, sqliteGetToDoQuery, id))
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit transaction")
+	}
+	log.Debugf("Successfully inserted todo to database.")
+	return created, nil
+}
+
+func (s *sqliteDatabase) UpdateToDo(ctx context.Context, todo api.ToDo, cond api.Precondition) (*api.ToDo, error) {
+	priority, err := priorityRank(todo.Priority)
+	if err != nil {
+		return nil, err
+	}
+	args := []interface{}{todo.ID, todo.Message, todo.DueAt, todo.ReminderAt, priority, todo.ListID, todo.ParentID}
+	query := fmt.Sprintf(sqliteUpdateToDoQuery, sqliteDialect.preconditionSQL(cond, &args))
+	updated, err := s.updateToDo(ctx, todo.ID, query, args)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Successfully updated todo in database.")
+	return updated, nil
+}
+
+// PatchToDo updates only the fields set in update, so concurrent changes
+// of other fields are kept.
+func (s *sqliteDatabase) PatchToDo(ctx context.Context, todoID int64, update api.ToDoUpdate, cond api.Precondition) (*api.ToDo, error) {
+	args := []interface{}{todoID}
+	sets, err := updateSetSQL(update, &args)
+	if err != nil {
+		return nil, err
+	}
+	if len(sets) == 0 {
+		todo, err := s.GetToDo(ctx, todoID)
+		if err != nil {
+			return nil, err
+		}
+		return unchangedToDo(todo, cond)
+	}
+
+	query := fmt.Sprintf(sqlitePatchToDoQuery, strings.Join(sets, ", "), sqliteDialect.preconditionSQL(cond, &args))
+	patched, err := s.updateToDo(ctx, todoID, query, args)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Successfully patched todo in database.")
+	return patched, nil
+}
+
+// updateToDo runs the update query of the todo and reads the todo with the updated_at of the trigger.
+func (s *sqliteDatabase) updateToDo(ctx context.Context, todoID int64, query string, args []interface{}) (*api.ToDo, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, translateError(err, "update todo in database")
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, errors.Wrap(err, "update todo in database, cant return rows affected")
+	}
+	if rows != 1 {
+		return nil, sqliteMissingOrChanged(ctx, tx, todoID)
+	}
+	updated, err := scanSQLiteToDo(tx.QueryRowContext(ctx, sqliteGetToDoQuery, todoID))
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit transaction")
+	}
+	return updated, nil
+}
+
+func (s *sqliteDatabase) UpdateSeries(ctx context.Context, seriesID int64, update api.ToDoUpdate) (int64, error) {
+	args := []interface{}{seriesID}
+	sets, err := updateSetSQL(update, &args)
+	if err != nil {
+		return 0, err
+	}
+	if len(sets) == 0 {
+		return 0, nil
+	}
+
+	result, err := s.conn.ExecContext(ctx, fmt.Sprintf(sqliteUpdateSeriesQuery, strings.Join(sets, ", ")), args...)
+	if err != nil {
+		return 0, translateError(err, "update series in database")
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "update series in database, cant return rows affected")
+	}
+	log.Debugf("Successfully updated series in database.")
+	return rows, nil
+}
+
+func (s *sqliteDatabase) DeleteToDo(ctx context.Context, todoID int64, cond api.Precondition) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	// deleted_at has the precision of Postgres.
+	deletedAt := time.Now().UTC().Truncate(time.Microsecond)
+	args := []interface{}{todoID, deletedAt}
+	query := fmt.Sprintf(sqliteDeleteToDoQuery, sqliteDialect.preconditionSQL(cond, &args))
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "delete todo in database")
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete todo in database, cant return rows affected")
+	}
+	if rows != 1 {
+		return sqliteMissingOrChanged(ctx, tx, todoID)
+	}
+	// Subtasks go to the trash with the same deleted_at, so they can be restored together.
+	if _, err := tx.ExecContext(ctx, sqliteTrashSubtasksQuery, todoID, nil, deletedAt); err != nil {
+		return errors.Wrap(err, "delete subtasks in database")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit transaction")
+	}
+	log.Debugf("Successfully deleted todo in database.")
+
+	return nil
+}
+
+func (s *sqliteDatabase) RestoreToDo(ctx context.Context, userID, todoID int64) (*api.ToDo, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	err = tx.QueryRowContext(ctx, sqliteGetDeletedAtQuery, todoID, userID).Scan(&deletedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("todo %d not found in trash", todoID)
+	case err != nil:
+		return nil, errors.Wrap(err, "query error")
+	}
+	if _, err := tx.ExecContext(ctx, sqliteRestoreSubtasksQuery, todoID, deletedAt); err != nil {
+		return nil, errors.Wrap(err, "restore subtasks in database")
+	}
+	if _, err := tx.ExecContext(ctx, sqliteRestoreToDoQuery, todoID); err != nil {
+		return nil, errors.Wrap(err, "restore todo in database")
+	}
+	todo, err := scanSQLiteToDo(tx.QueryRowContext(ctx, sqliteGetToDoQuery, todoID))
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit transaction")
+	}
+	log.Debugf("Successfully restored todo in database.")
+	return todo, nil
+}
+
+func (s *sqliteDatabase) GetSubtasks(ctx context.Context, parentID int64) ([]api.ToDo, error) {
+	rows, err := s.conn.QueryContext(ctx, sqliteGetSubtasksQuery, parentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	return scanSQLiteToDos(rows)
+}
+
+func (s *sqliteDatabase) GetAncestorIDs(ctx context.Context, todoID int64) ([]int64, error) {
+	rows, err := s.conn.QueryContext(ctx, sqliteGetAncestorIDsQuery, todoID)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "scan todo id")
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate todo ids")
+	}
+	return ids, nil
+}
+
+func (s *sqliteDatabase) PurgeToDos(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := s.conn.ExecContext(ctx, sqlitePurgeToDosQuery, deletedBefore)
+	if err != nil {
+		return 0, errors.Wrap(err, "purge todos in database")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "purge todos in database, cant return rows affected")
+	}
+	return rows, nil
+}
+
+func (s *sqliteDatabase) GetToDo(ctx context.Context, todoID int64) (*api.ToDo, error) {
+	return sqliteGetToDo(ctx, s.conn, todoID)
+}
+
+func sqliteGetToDo(ctx context.Context, q querier, todoID int64) (*api.ToDo, error) {
+	todo, err := scanSQLiteToDo(q.QueryRowContext(ctx, sqliteGetToDoQuery, todoID))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("todo %d not found", todoID)
+	case err != nil:
+		return nil, errors.Wrap(err, "query error")
+	}
+	return todo, nil
+}
+
+// sqliteMissingOrChanged explains why a change of the todo touched no rows. It reads the todo
+// with the querier of the change, the transaction holds the only connection.
+func sqliteMissingOrChanged(ctx context.Context, q querier, todoID int64) error {
+	todo, err := sqliteGetToDo(ctx, q, todoID)
+	if err != nil {
+		return err
+	}
+	return api.NewError(api.ErrPreconditionFailed, "todo %d has version %d", todoID, todo.Version)
+}
+
+func (s *sqliteDatabase) GetToDos(ctx context.Context, userID int64, filter ToDoFilter, limit, offset int64) ([]api.ToDo, error) {
+	args := []interface{}{}
+	where := sqliteDialect.filterSQL(userID, filter, &args)
+	args = append(args, limit, offset)
+	page := fmt.Sprintf("LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := s.conn.QueryContext(ctx, fmt.Sprintf(sqliteListToDosQuery, where, orderSQL(filter), page), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	return scanSQLiteToDos(rows)
+}
+
+func (s *sqliteDatabase) GetToDosAfter(ctx context.Context, userID int64, filter ToDoFilter, after *ToDoKey, limit int64) ([]api.ToDo, error) {
+	args := []interface{}{}
+	where := sqliteDialect.filterSQL(userID, filter, &args)
+	if after != nil {
+		afterSQL, err := afterKeySQL(filter, *after, &args)
+		if err != nil {
+			return nil, err
+		}
+		where += afterSQL
+	}
+	args = append(args, limit)
+	page := fmt.Sprintf("LIMIT $%d", len(args))
+
+	rows, err := s.conn.QueryContext(ctx, fmt.Sprintf(sqliteListToDosQuery, where, orderSQL(filter), page), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	return scanSQLiteToDos(rows)
+}
+
+func (s *sqliteDatabase) CountToDos(ctx context.Context, userID int64, filter ToDoFilter) (int64, error) {
+	args := []interface{}{}
+	query := fmt.Sprintf(sqliteCountToDosQuery, sqliteDialect.filterSQL(userID, filter, &args))
+
+	var total int64
+	if err := s.conn.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, errors.Wrap(err, "count todos")
+	}
+	return total, nil
+}
+
+func (s *sqliteDatabase) LastPosition(ctx context.Context, userID int64) (string, error) {
+	var position string
+	if err := s.conn.QueryRowContext(ctx, sqliteLastPositionQuery, userID).Scan(&position); err != nil {
+		return "", errors.Wrap(err, "query last position")
+	}
+	return position, nil
+}
+
+func (s *sqliteDatabase) AdjacentPosition(ctx context.Context, userID int64, position string, before bool) (string, error) {
+	query := sqlitePositionAfterQuery
+	if before {
+		query = sqlitePositionBeforeQuery
+	}
+	var adjacent string
+	if err := s.conn.QueryRowContext(ctx, query, userID, position).Scan(&adjacent); err != nil {
+		return "", errors.Wrap(err, "query adjacent position")
+	}
+	return adjacent, nil
+}
+
+func (s *sqliteDatabase) AddToDoTags(ctx context.Context, todoID int64, tags []string) (*api.ToDo, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, sqliteTouchToDoQuery, todoID).Scan(&userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("todo %d not found", todoID)
+	case err != nil:
+		return nil, errors.Wrap(err, "update todo version")
+	}
+	if _, err := tx.ExecContext(ctx, sqliteAddTagsQuery, userID, jsonArray(tags)); err != nil {
+		return nil, translateError(err, "insert tags to database")
+	}
+	if _, err := tx.ExecContext(ctx, sqliteAddToDoTagsQuery, todoID, userID, jsonArray(tags)); err != nil {
+		return nil, translateError(err, "insert todo tags to database")
+	}
+	todo, err := scanSQLiteToDo(tx.QueryRowContext(ctx, sqliteGetToDoQuery, todoID))
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit transaction")
+	}
+	log.Debugf("Successfully added todo tags in database.")
+	return todo, nil
+}
+
+func (s *sqliteDatabase) RemoveToDoTag(ctx context.Context, todoID int64, tag string) (*api.ToDo, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, sqliteTouchToDoQuery, todoID).Scan(&userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("todo %d not found", todoID)
+	case err != nil:
+		return nil, errors.Wrap(err, "update todo version")
+	}
+	result, err := tx.ExecContext(ctx, sqliteRemoveToDoTagQuery, todoID, userID, tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "delete todo tag in database")
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, errors.Wrap(err, "delete todo tag in database, cant return rows affected")
+	}
+	if rows != 1 {
+		return nil, api.NotFoundf("todo %d has no tag %q", todoID, tag)
+	}
+	if _, err := tx.ExecContext(ctx, sqliteRemoveUnusedTagQuery, userID, tag); err != nil {
+		return nil, errors.Wrap(err, "delete unused tag in database")
+	}
+	todo, err := scanSQLiteToDo(tx.QueryRowContext(ctx, sqliteGetToDoQuery, todoID))
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit transaction")
+	}
+	log.Debugf("Successfully removed todo tag in database.")
+	return todo, nil
+}
+
+func (s *sqliteDatabase) GetTags(ctx context.Context, userID int64) ([]api.Tag, error) {
+	rows, err := s.conn.QueryContext(ctx, sqliteGetTagsQuery, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	tags := []api.Tag{}
+	for rows.Next() {
+		var tag api.Tag
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, errors.Wrap(err, "scan tag")
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate tags")
+	}
+	return tags, nil
+}
+
+func (s *sqliteDatabase) SearchToDos(ctx context.Context, userID int64, terms []SearchTerm, limit, offset int64) ([]api.SearchResult, int64, error) {
+	if len(terms) == 0 {
+		return []api.SearchResult{}, 0, nil
+	}
+
+	var total int64
+	err := s.conn.QueryRowContext(ctx, sqliteCountSearchQuery, userID, jsonArray(terms)).Scan(&total)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "count search results")
+	}
+
+	rows, err := s.conn.QueryContext(ctx, sqliteSearchToDosQuery, userID, limit, offset, jsonArray(terms))
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	results := []api.SearchResult{}
+	for rows.Next() {
+		var result api.SearchResult
+		todo, err := scanSQLiteToDo(searchRow{rows: rows, extra: []interface{}{&result.Snippet, &result.Rank}})
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "scan search result")
+		}
+		result.ToDo = *todo
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, errors.Wrap(err, "iterate search results")
+	}
+	return results, total, nil
+}
+
+// sqliteTags scans the tag names that group_concat joins with the unit separator.
+type sqliteTags []string
+
+func (t *sqliteTags) Scan(src interface{}) error {
+	*t = []string{}
+	var names string
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		names = v
+	case []byte:
+		names = string(v)
+	default:
+		return errors.Errorf("cant scan %T into tags", src)
+	}
+	*t = strings.Split(names, "\x1f")
+	sort.Strings(*t)
+	return nil
+}
+
+// scanSQLiteToDo reads a todo selected with sqliteToDoColumns.
+func scanSQLiteToDo(row rowScanner) (*api.ToDo, error) {
+	todo, err := scanToDoWith(row, func(todo *api.ToDo) interface{} { return (*sqliteTags)(&todo.Tags) })
+	if err != nil {
+		return nil, err
+	}
+	todo.CreatedAt, todo.UpdatedAt = todo.CreatedAt.UTC(), todo.UpdatedAt.UTC()
+	for _, t := range []**time.Time{&todo.DueAt, &todo.ReminderAt, &todo.CompletedAt, &todo.DeletedAt, &todo.SeriesStart} {
+		if *t != nil {
+			utc := (*t).UTC()
+			*t = &utc
+		}
+	}
+	return todo, nil
+}
+
+func scanSQLiteToDos(rows *sql.Rows) ([]api.ToDo, error) {
+	defer rows.Close()
+
+	todos := []api.ToDo{}
+	for rows.Next() {
+		todo, err := scanSQLiteToDo(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan todo")
+		}
+		todos = append(todos, *todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate todos")
+	}
+	return todos, nil
+}
+
+func (s *sqliteDatabase) CreateUser(ctx context.Context, user api.User) (*api.User, error) {
+	var created api.User
+	err := s.conn.QueryRowContext(ctx, sqliteAddUserQuery, user.Name).Scan(
+		&created.ID,
+		&created.Name)
+	if err != nil {
+		return nil, translateError(err, "insert user to database")
+	}
+	log.Debugf("Successfully inserted user to database.")
+	return &created, nil
+}
+
+func (s *sqliteDatabase) GetUser(ctx context.Context, id int64) (*api.User, error) {
+	var user api.User
+	err := s.conn.QueryRowContext(ctx, sqliteGetUserQuery, id).Scan(
+		&user.ID,
+		&user.Name)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("user %d not found", id)
+	case err != nil:
+		return nil, errors.Wrap(err, "query error")
+	}
+	return &user, nil
+}
+
+func (s *sqliteDatabase) GetUsers(ctx context.Context, limit, offset int64) ([]api.User, error) {
+	rows, err := s.conn.QueryContext(ctx, sqliteGetUsersQuery, limit, offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	users := []api.User{}
+	for rows.Next() {
+		var user api.User
+		if err := rows.Scan(&user.ID, &user.Name); err != nil {
+			return nil, errors.Wrap(err, "scan user")
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate users")
+	}
+	return users, nil
+}
+
+func (s *sqliteDatabase) CountUsers(ctx context.Context) (int64, error) {
+	var total int64
+	if err := s.conn.QueryRowContext(ctx, sqliteCountUsersQuery).Scan(&total); err != nil {
+		return 0, errors.Wrap(err, "count users")
+	}
+	return total, nil
+}
+
+func (s *sqliteDatabase) UpdateUser(ctx context.Context, user api.User) error {
+	result, err := s.conn.ExecContext(ctx, sqliteUpdateUserQuery, user.Name, user.ID)
+	if err != nil {
+		return translateError(err, "update user in database")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "update user in database, cant return rows affected")
+	}
+
+	if rows != 1 {
+		return api.NotFoundf("user %d not found", user.ID)
+	}
+	log.Debugf("Successfully updated user in database.")
+	return nil
+}
+
+func (s *sqliteDatabase) DeleteUser(ctx context.Context, id int64) error {
+	result, err := s.conn.ExecContext(ctx, sqliteDeleteUserQuery, id)
+	if err != nil {
+		return errors.Wrap(err, "delete user in database")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete user in database, cant return rows affected")
+	}
+
+	if rows != 1 {
+		return api.NotFoundf("user %d not found", id)
+	}
+	log.Debugf("Successfully deleted user in database.")
+
+	return nil
+}
+
+func (s *sqliteDatabase) CreateList(ctx context.Context, list api.List) (*api.List, error) {
+	created, err := scanSQLiteList(s.conn.QueryRowContext(ctx, sqliteAddListQuery, list.UserID, list.Name, list.Color, list.Archived))
+	if err != nil {
+		return nil, translateError(err, "insert list to database")
+	}
+	log.Debugf("Successfully inserted list to database.")
+	return created, nil
+}
+
+func (s *sqliteDatabase) GetList(ctx context.Context, listID int64) (*api.List, error) {
+	list, err := scanSQLiteList(s.conn.QueryRowContext(ctx, sqliteGetListQuery, listID))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("list %d not found", listID)
+	case err != nil:
+		return nil, errors.Wrap(err, "query error")
+	}
+	return list, nil
+}
+
+func (s *sqliteDatabase) GetLists(ctx context.Context, userID int64, archived bool) ([]api.List, error) {
+	rows, err := s.conn.QueryContext(ctx, sqliteGetListsQuery, userID, archived)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	lists := []api.List{}
+	for rows.Next() {
+		list, err := scanSQLiteList(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan list")
+		}
+		lists = append(lists, *list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate lists")
+	}
+	return lists, nil
+}
+
+func (s *sqliteDatabase) UpdateList(ctx context.Context, list api.List) (*api.List, error) {
+	updated, err := scanSQLiteList(s.conn.QueryRowContext(ctx, sqliteUpdateListQuery, list.ID, list.Name, list.Color, list.Archived))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("list %d not found", list.ID)
+	case err != nil:
+		return nil, translateError(err, "update list in database")
+	}
+	log.Debugf("Successfully updated list in database.")
+	return updated, nil
+}
+
+func (s *sqliteDatabase) DeleteList(ctx context.Context, listID int64) error {
+	result, err := s.conn.ExecContext(ctx, sqliteDeleteListQuery, listID)
+	if err != nil {
+		return errors.Wrap(err, "delete list in database")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete list in database, cant return rows affected")
+	}
+
+	if rows != 1 {
+		return api.NotFoundf("list %d not found", listID)
+	}
+	log.Debugf("Successfully deleted list in database.")
+	return nil
+}
+
+func scanSQLiteList(row rowScanner) (*api.List, error) {
+	list, err := scanList(row)
+	if err != nil {
+		return nil, err
+	}
+	list.CreatedAt = list.CreatedAt.UTC()
+	return list, nil
+}
+
+func (s *sqliteDatabase) CreateAPIToken(ctx context.Context, token api.APIToken, hash []byte) (*api.APIToken, error) {
+	var created api.APIToken
+	err := s.conn.QueryRowContext(ctx, sqliteAddAPITokenQuery, token.UserID, token.Name, hash).Scan(
+		&created.ID,
+		&created.UserID,
+		&created.Name,
+		&created.CreatedAt)
+	if err != nil {
+		return nil, translateError(err, "insert api token to database")
+	}
+	created.CreatedAt = created.CreatedAt.UTC()
+	log.Debugf("Successfully inserted api token to database.")
+	return &created, nil
+}
+
+func (s *sqliteDatabase) GetAPITokens(ctx context.Context, userID int64) ([]api.APIToken, error) {
+	rows, err := s.conn.QueryContext(ctx, sqliteGetAPITokensQuery, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	tokens := []api.APIToken{}
+	for rows.Next() {
+		var token api.APIToken
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &token.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, "scan api token")
+		}
+		token.CreatedAt = token.CreatedAt.UTC()
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate api tokens")
+	}
+	return tokens, nil
+}
+
+func (s *sqliteDatabase) GetAPITokenUser(ctx context.Context, hash []byte) (*api.User, error) {
+	var user api.User
+	err := s.conn.QueryRowContext(ctx, sqliteGetAPITokenUserQuery, hash).Scan(
+		&user.ID,
+		&user.Name)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, api.NotFoundf("api token not found")
+	case err != nil:
+		return nil, errors.Wrap(err, "query error")
+	}
+	return &user, nil
+}
+
+func (s *sqliteDatabase) DeleteAPIToken(ctx context.Context, userID, tokenID int64) error {
+	result, err := s.conn.ExecContext(ctx, sqliteDeleteAPITokenQuery, tokenID, userID)
+	if err != nil {
+		return errors.Wrap(err, "delete api token in database")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete api token in database, cant return rows affected")
+	}
+
+	if rows != 1 {
+		return api.NotFoundf("api token %d not found", tokenID)
+	}
+	log.Debugf("Successfully deleted api token in database.")
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+	"to-do/repository"
+	"to-do/repository/storagetest"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStorage(t *testing.T, cfg repository.StorageConfig) repository.Storage {
+	t.Helper()
+	storage, err := repository.NewDBClient(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+	return storage
+}
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) repository.Storage {
+		return newStorage(t, repository.StorageConfig{Driver: repository.DriverMemory})
+	})
+}
+
+func TestSQLiteStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) repository.Storage {
+		return newStorage(t, repository.StorageConfig{
+			Driver: repository.DriverSQLite,
+			DSN:    filepath.Join(t.TempDir(), "todo.db"),
+		})
+	})
+}
+
+func TestStorageConfigValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := repository.StorageConfig{Driver: "postgres", DSN: "postgresql://localhost/postgres", MaxOpenConns: 10, MaxIdleConns: 5}
+	assert.NoError(cfg.Validate())
+
+	for _, change := range []func(*repository.StorageConfig){
+		func(c *repository.StorageConfig) { c.MaxOpenConns = -1 },
+		func(c *repository.StorageConfig) { c.MaxIdleConns = 11 },
+		func(c *repository.StorageConfig) { c.ConnMaxLifetime = -time.Second },
+		func(c *repository.StorageConfig) { c.StatementTimeout = -time.Second },
+		func(c *repository.StorageConfig) { c.ConnectRetries = -1 },
+	} {
+		invalid := cfg
+		change(&invalid)
+		assert.Error(invalid.Validate())
+	}
+}
+
+func TestConnectRetries(t *testing.T) {
+	assert := assert.New(t)
+
+	// The directory of the database file is missing, every connection fails.
+	cfg := repository.StorageConfig{
+		Driver:            repository.DriverSQLite,
+		DSN:               filepath.Join(t.TempDir(), "missing", "todo.db"),
+		ConnectRetries:    2,
+		ConnectRetryDelay: 20 * time.Millisecond,
+	}
+	start := time.Now()
+	_, err := repository.NewDBClient(context.Background(), cfg)
+	assert.Error(err)
+	assert.True(time.Since(start) >= 60*time.Millisecond, "retried after 20ms and 40ms")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	cfg.ConnectRetries, cfg.ConnectRetryDelay = 5, time.Minute
+	_, err = repository.NewDBClient(ctx, cfg)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+}
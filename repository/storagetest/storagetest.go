@@ -0,0 +1,1160 @@
+// Package storagetest checks that a repository.Storage behaves like the Postgres storage.
+//
+// The tests create their own users and todos, so the storage may hold other data and may be
+// shared between tests. Only the PurgeToDos test touches other data: it purges the trash.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"to-do/api"
+	"to-do/repository"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// Factory returns the storage to test, it is called once for every test.
+type Factory func(t *testing.T) repository.Storage
+
+// Run runs every conformance test as a subtest of t.
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s repository.Storage)
+	}{
+		{"CreateUser", testCreateUser},
+		{"GetUsers", testGetUsers},
+		{"UpdateUser", testUpdateUser},
+		{"DeleteUser", testDeleteUser},
+		{"CreateToDo", testCreateToDo},
+		{"CreateToDoReferences", testCreateToDoReferences},
+		{"UpdateToDo", testUpdateToDo},
+		{"PatchToDo", testPatchToDo},
+		{"Preconditions", testPreconditions},
+		{"ConcurrentPatches", testConcurrentPatches},
+		{"ConcurrentConditionalPatches", testConcurrentConditionalPatches},
+		{"DeleteAndRestoreToDo", testDeleteAndRestoreToDo},
+		{"PurgeToDos", testPurgeToDos},
+		{"GetToDos", testGetToDos},
+		{"GetToDosAfter", testGetToDosAfter},
+		{"Positions", testPositions},
+		{"Tags", testTags},
+		{"SearchToDos", testSearchToDos},
+		{"UpdateSeries", testUpdateSeries},
+		{"Subtasks", testSubtasks},
+		{"Lists", testLists},
+		{"APITokens", testAPITokens},
+		{"WithTx", testWithTx},
+		{"NestedWithTx", testNestedWithTx},
+		{"Batch", testBatch},
+		{"AtomicBatch", testAtomicBatch},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, factory(t))
+		})
+	}
+}
+
+// missingID is an id that no record has.
+const missingID = 1 << 40
+
+// errFailed is returned by the transactions and batch operations that have to fail.
+var errFailed = errors.New("failed on purpose")
+
+// userSeq keeps the names of the users unique when the tests run in the same nanosecond.
+var userSeq int64
+
+func newUser(t *testing.T, s repository.Storage) *api.User {
+	t.Helper()
+	name := fmt.Sprintf("storagetest-%d-%d", time.Now().UnixNano(), atomic.AddInt64(&userSeq, 1))
+	user, err := s.CreateUser(context.Background(), api.User{Name: name})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func newToDo(t *testing.T, s repository.Storage, todo api.ToDo) *api.ToDo {
+	t.Helper()
+	if todo.Priority == "" {
+		todo.Priority = api.PriorityNormal
+	}
+	created, err := s.CreateToDo(context.Background(), todo)
+	if err != nil {
+		t.Fatalf("create todo: %v", err)
+	}
+	return created
+}
+
+// isError reports whether err is of the kind of api.Error, it reports the failure to t.
+func isError(t *testing.T, err, kind error) bool {
+	t.Helper()
+	return assert.True(t, errors.Is(err, kind), "want %v, got %v", kind, err)
+}
+
+// tick waits until the clock of the storage has moved on, SQLite keeps milliseconds.
+func tick() {
+	time.Sleep(5 * time.Millisecond)
+}
+
+// ids returns the ids of todos in order.
+func ids(todos []api.ToDo) []int64 {
+	ids := []int64{}
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+func testCreateUser(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	assert.NotZero(user.ID)
+	got, err := s.GetUser(ctx, user.ID)
+	assert.NoError(err)
+	assert.Equal(user, got)
+
+	_, err = s.CreateUser(ctx, api.User{Name: user.Name})
+	isError(t, err, api.ErrConflict)
+
+	_, err = s.GetUser(ctx, missingID)
+	isError(t, err, api.ErrNotFound)
+}
+
+func testGetUsers(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	first, second := newUser(t, s), newUser(t, s)
+	total, err := s.CountUsers(ctx)
+	assert.NoError(err)
+	assert.True(total >= 2, "count %d, want at least the 2 new users", total)
+
+	// Other tests share the table, so page through all users until an empty page past
+	// the last one and check only the order of the pages and the new users in them.
+	found := []api.User{}
+	var offset, lastID int64
+	for {
+		users, err := s.GetUsers(ctx, 10, offset)
+		if !assert.NoError(err) || len(users) == 0 {
+			break
+		}
+		for _, user := range users {
+			assert.True(user.ID > lastID, "user %d after user %d, want users ordered by id", user.ID, lastID)
+			lastID = user.ID
+			if user.ID == first.ID || user.ID == second.ID {
+				found = append(found, user)
+			}
+		}
+		offset += int64(len(users))
+	}
+	assert.Equal([]api.User{*first, *second}, found)
+}
+
+func testUpdateUser(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user, other := newUser(t, s), newUser(t, s)
+	user.Name += "-renamed"
+	assert.NoError(s.UpdateUser(ctx, *user))
+	got, err := s.GetUser(ctx, user.ID)
+	assert.NoError(err)
+	assert.Equal(user.Name, got.Name)
+
+	isError(t, s.UpdateUser(ctx, api.User{ID: user.ID, Name: other.Name}), api.ErrConflict)
+	isError(t, s.UpdateUser(ctx, api.User{ID: missingID, Name: user.Name + "-missing"}), api.ErrNotFound)
+}
+
+func testDeleteUser(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	todo := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "left behind", Position: "a"})
+
+	assert.NoError(s.DeleteUser(ctx, user.ID))
+	_, err := s.GetUser(ctx, user.ID)
+	isError(t, err, api.ErrNotFound)
+	// The todos of the user are deleted with the user.
+	_, err = s.GetToDo(ctx, todo.ID)
+	isError(t, err, api.ErrNotFound)
+
+	isError(t, s.DeleteUser(ctx, user.ID), api.ErrNotFound)
+}
+
+func testCreateToDo(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	start := time.Now()
+	due := time.Date(2031, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	todo := newToDo(t, s, api.ToDo{
+		UserID:   user.ID,
+		Message:  "water the plants",
+		DueAt:    &due,
+		Priority: api.PriorityHigh,
+		Position: "m",
+	})
+
+	assert.NotZero(todo.ID)
+	assert.Equal(user.ID, todo.UserID)
+	assert.Equal("water the plants", todo.Message)
+	assert.Equal(int64(1), todo.Version)
+	assert.Equal(api.StatusOpen, todo.Status)
+	assert.Equal(api.PriorityHigh, todo.Priority)
+	assert.Equal("m", todo.Position)
+	assert.Empty(todo.Tags)
+	assert.Nil(todo.DeletedAt)
+	assert.Nil(todo.CompletedAt)
+	assert.Nil(todo.ListID)
+	assert.Nil(todo.Progress)
+	if assert.NotNil(todo.DueAt) {
+		assert.True(due.Equal(*todo.DueAt), "due_at %v, want %v", todo.DueAt, due)
+	}
+
+	// The storage sets the timestamps, a new todo has not been updated yet.
+	assert.Equal(todo.CreatedAt, todo.UpdatedAt)
+	assert.WithinDuration(start, todo.CreatedAt, time.Minute)
+
+	got, err := s.GetToDo(ctx, todo.ID)
+	assert.NoError(err)
+	assert.Equal(todo, got)
+
+	_, err = s.GetToDo(ctx, missingID)
+	isError(t, err, api.ErrNotFound)
+
+	_, err = s.CreateToDo(ctx, api.ToDo{UserID: user.ID, Message: "x", Priority: "someday", Position: "n"})
+	isError(t, err, api.ErrValidation)
+}
+
+func testCreateToDoReferences(t *testing.T, s repository.Storage) {
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	missing := int64(missingID)
+	_, err := s.CreateToDo(ctx, api.ToDo{UserID: missingID, Message: "x", Priority: api.PriorityNormal, Position: "a"})
+	isError(t, err, api.ErrValidation)
+	_, err = s.CreateToDo(ctx, api.ToDo{UserID: user.ID, Message: "x", Priority: api.PriorityNormal, Position: "a", ListID: &missing})
+	isError(t, err, api.ErrValidation)
+	_, err = s.CreateToDo(ctx, api.ToDo{UserID: user.ID, Message: "x", Priority: api.PriorityNormal, Position: "a", ParentID: &missing})
+	isError(t, err, api.ErrValidation)
+
+	list, err := s.CreateList(ctx, api.List{UserID: user.ID, Name: "garden"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	todo := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "rake the leaves", Position: "a", ListID: &list.ID})
+	assert.Equal(t, &list.ID, todo.ListID)
+
+	// Deleting the list moves its todos to the inbox, which changes them.
+	tick()
+	assert.NoError(t, s.DeleteList(ctx, list.ID))
+	got, err := s.GetToDo(ctx, todo.ID)
+	if assert.NoError(t, err) {
+		assert.Nil(t, got.ListID)
+		assert.True(t, got.UpdatedAt.After(todo.UpdatedAt), "updated_at %v is not after %v", got.UpdatedAt, todo.UpdatedAt)
+	}
+}
+
+func testUpdateToDo(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	todo := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "paint the fence", Position: "a"})
+
+	tick()
+	todo.Message = "paint the fence green"
+	todo.Priority = api.PriorityUrgent
+	updated, err := s.UpdateToDo(ctx, *todo, api.Precondition{})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal("paint the fence green", updated.Message)
+	assert.Equal(api.PriorityUrgent, updated.Priority)
+	assert.Equal(todo.Version+1, updated.Version)
+	assert.Equal(todo.CreatedAt, updated.CreatedAt)
+	assert.True(updated.UpdatedAt.After(todo.UpdatedAt), "updated_at %v is not after %v", updated.UpdatedAt, todo.UpdatedAt)
+
+	got, err := s.GetToDo(ctx, todo.ID)
+	assert.NoError(err)
+	assert.Equal(updated, got)
+
+	missing := int64(missingID)
+	todo.ListID = &missing
+	_, err = s.UpdateToDo(ctx, *todo, api.Precondition{})
+	isError(t, err, api.ErrValidation)
+
+	todo.ListID = nil
+	todo.ID = missingID
+	_, err = s.UpdateToDo(ctx, *todo, api.Precondition{})
+	isError(t, err, api.ErrNotFound)
+}
+
+func testPatchToDo(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	todo := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "fix the bike", Position: "a"})
+
+	tick()
+	status := api.StatusDone
+	completed := time.Date(2030, 5, 6, 7, 8, 9, 0, time.UTC)
+	patched, err := s.PatchToDo(ctx, todo.ID, api.ToDoUpdate{
+		Status:      &status,
+		CompletedAt: &api.TimeUpdate{Time: &completed},
+	}, api.Precondition{})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal("fix the bike", patched.Message)
+	assert.Equal(api.StatusDone, patched.Status)
+	if assert.NotNil(patched.CompletedAt) {
+		assert.True(completed.Equal(*patched.CompletedAt))
+	}
+	assert.Equal(todo.Version+1, patched.Version)
+	assert.True(patched.UpdatedAt.After(todo.UpdatedAt), "updated_at %v is not after %v", patched.UpdatedAt, todo.UpdatedAt)
+
+	// An empty update changes nothing.
+	same, err := s.PatchToDo(ctx, todo.ID, api.ToDoUpdate{}, api.Precondition{})
+	assert.NoError(err)
+	assert.Equal(patched, same)
+
+	// A nil time clears the field.
+	cleared, err := s.PatchToDo(ctx, todo.ID, api.ToDoUpdate{CompletedAt: &api.TimeUpdate{}}, api.Precondition{})
+	assert.NoError(err)
+	assert.Nil(cleared.CompletedAt)
+
+	invalid := "maybe"
+	_, err = s.PatchToDo(ctx, todo.ID, api.ToDoUpdate{Status: &invalid}, api.Precondition{})
+	assert.Error(err)
+
+	message := "fix the car"
+	_, err = s.PatchToDo(ctx, missingID, api.ToDoUpdate{Message: &message}, api.Precondition{})
+	isError(t, err, api.ErrNotFound)
+}
+
+func testPreconditions(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	todo := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "bake bread", Position: "a"})
+	message := "bake a cake"
+	patch := func(cond api.Precondition) error {
+		_, err := s.PatchToDo(ctx, todo.ID, api.ToDoUpdate{Message: &message}, cond)
+		return err
+	}
+
+	isError(t, patch(api.Precondition{IfMatch: []int64{todo.Version + 1}}), api.ErrPreconditionFailed)
+	isError(t, patch(api.Precondition{IfNoneMatch: []int64{todo.Version}}), api.ErrPreconditionFailed)
+	isError(t, patch(api.Precondition{IfNoneMatch: []int64{api.AnyVersion}}), api.ErrPreconditionFailed)
+	_, err := s.UpdateToDo(ctx, *todo, api.Precondition{IfMatch: []int64{todo.Version + 1}})
+	isError(t, err, api.ErrPreconditionFailed)
+	isError(t, s.DeleteToDo(ctx, todo.ID, api.Precondition{IfMatch: []int64{todo.Version + 1}}), api.ErrPreconditionFailed)
+
+	// An empty patch changes nothing but checks the precondition.
+	_, err = s.PatchToDo(ctx, todo.ID, api.ToDoUpdate{}, api.Precondition{IfMatch: []int64{todo.Version + 1}})
+	isError(t, err, api.ErrPreconditionFailed)
+	_, err = s.PatchToDo(ctx, todo.ID, api.ToDoUpdate{}, api.Precondition{IfNoneMatch: []int64{api.AnyVersion}})
+	isError(t, err, api.ErrPreconditionFailed)
+	same, err := s.PatchToDo(ctx, todo.ID, api.ToDoUpdate{}, api.Precondition{IfMatch: []int64{todo.Version}})
+	assert.NoError(err)
+	assert.Equal(todo, same)
+
+	// Failed changes leave the todo alone.
+	got, err := s.GetToDo(ctx, todo.ID)
+	assert.NoError(err)
+	assert.Equal(todo, got)
+
+	assert.NoError(patch(api.Precondition{IfMatch: []int64{todo.Version + 5, todo.Version}}))
+	assert.NoError(patch(api.Precondition{IfMatch: []int64{api.AnyVersion}}))
+	assert.NoError(patch(api.Precondition{IfNoneMatch: []int64{todo.Version}}))
+	assert.NoError(s.DeleteToDo(ctx, todo.ID, api.Precondition{IfMatch: []int64{todo.Version + 3}}))
+
+	// A missing todo is not found whatever the precondition.
+	isError(t, patch(api.Precondition{IfMatch: []int64{todo.Version}}), api.ErrNotFound)
+}
+
+// testConcurrentPatches checks that concurrent changes are not lost.
+func testConcurrentPatches(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	todo := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "count", Position: "a"})
+
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			message := fmt.Sprintf("count %d", i)
+			_, errs[i] = s.PatchToDo(ctx, todo.ID, api.ToDoUpdate{Message: &message}, api.Precondition{})
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(err)
+	}
+
+	got, err := s.GetToDo(ctx, todo.ID)
+	assert.NoError(err)
+	assert.Equal(todo.Version+writers, got.Version)
+}
+
+// testConcurrentConditionalPatches checks that only one of the changes of the same version wins.
+func testConcurrentConditionalPatches(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	todo := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "race", Position: "a"})
+
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			message := fmt.Sprintf("won by %d", i)
+			_, errs[i] = s.PatchToDo(ctx, todo.ID, api.ToDoUpdate{Message: &message}, api.Precondition{IfMatch: []int64{todo.Version}})
+		}(i)
+	}
+	wg.Wait()
+
+	won := 0
+	for _, err := range errs {
+		if err == nil {
+			won++
+			continue
+		}
+		isError(t, err, api.ErrPreconditionFailed)
+	}
+	assert.Equal(1, won)
+
+	got, err := s.GetToDo(ctx, todo.ID)
+	assert.NoError(err)
+	assert.Equal(todo.Version+1, got.Version)
+}
+
+func testDeleteAndRestoreToDo(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	parent := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "move house", Position: "a"})
+	child := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "pack the books", Position: "b", ParentID: &parent.ID})
+
+	start := time.Now()
+	tick()
+	assert.NoError(s.DeleteToDo(ctx, parent.ID, api.Precondition{}))
+
+	// Deleted todos are invisible, their subtasks go to the trash with them.
+	_, err := s.GetToDo(ctx, parent.ID)
+	isError(t, err, api.ErrNotFound)
+	_, err = s.GetToDo(ctx, child.ID)
+	isError(t, err, api.ErrNotFound)
+	isError(t, s.DeleteToDo(ctx, parent.ID, api.Precondition{}), api.ErrNotFound)
+	message := "move flat"
+	_, err = s.PatchToDo(ctx, parent.ID, api.ToDoUpdate{Message: &message}, api.Precondition{})
+	isError(t, err, api.ErrNotFound)
+
+	trash, err := s.GetToDos(ctx, user.ID, repository.ToDoFilter{Deleted: true}, 10, 0)
+	assert.NoError(err)
+	if assert.Len(trash, 2) {
+		for _, todo := range trash {
+			if assert.NotNil(todo.DeletedAt) {
+				assert.WithinDuration(start, *todo.DeletedAt, time.Minute)
+				assert.True(trash[0].DeletedAt.Equal(*todo.DeletedAt), "subtasks are deleted with the same deleted_at")
+			}
+		}
+	}
+
+	_, err = s.RestoreToDo(ctx, user.ID+1, parent.ID)
+	isError(t, err, api.ErrNotFound)
+	restored, err := s.RestoreToDo(ctx, user.ID, parent.ID)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Nil(restored.DeletedAt)
+	assert.Equal(parent.Version+2, restored.Version)
+	assert.Equal(&api.Progress{Done: 0, Total: 1}, restored.Progress)
+	_, err = s.GetToDo(ctx, child.ID)
+	assert.NoError(err)
+
+	_, err = s.RestoreToDo(ctx, user.ID, parent.ID)
+	isError(t, err, api.ErrNotFound)
+	isError(t, s.DeleteToDo(ctx, missingID, api.Precondition{}), api.ErrNotFound)
+}
+
+func testPurgeToDos(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	todo := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "throw away", Position: "a"})
+	kept := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "keep", Position: "b"})
+	assert.NoError(s.DeleteToDo(ctx, todo.ID, api.Precondition{}))
+	trash, err := s.GetToDos(ctx, user.ID, repository.ToDoFilter{Deleted: true}, 10, 0)
+	if !assert.NoError(err) || !assert.Len(trash, 1) {
+		return
+	}
+	deletedAt := *trash[0].DeletedAt
+
+	// Only the todos deleted before the time are purged.
+	_, err = s.PurgeToDos(ctx, deletedAt)
+	assert.NoError(err)
+	count, err := s.CountToDos(ctx, user.ID, repository.ToDoFilter{Deleted: true})
+	assert.NoError(err)
+	assert.Equal(int64(1), count)
+
+	purged, err := s.PurgeToDos(ctx, deletedAt.Add(time.Millisecond))
+	assert.NoError(err)
+	assert.True(purged >= 1)
+	count, err = s.CountToDos(ctx, user.ID, repository.ToDoFilter{Deleted: true})
+	assert.NoError(err)
+	assert.Zero(count)
+	_, err = s.RestoreToDo(ctx, user.ID, todo.ID)
+	isError(t, err, api.ErrNotFound)
+
+	_, err = s.GetToDo(ctx, kept.ID)
+	assert.NoError(err)
+}
+
+func testGetToDos(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user, other := newUser(t, s), newUser(t, s)
+	first := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "first", Position: "c", Priority: api.PriorityLow})
+	tick()
+	second := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "second", Position: "a", Priority: api.PriorityUrgent})
+	third := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "third", Position: "b"})
+	newToDo(t, s, api.ToDo{UserID: other.ID, Message: "not mine", Position: "a"})
+	done := api.StatusDone
+	_, err := s.PatchToDo(ctx, third.ID, api.ToDoUpdate{Status: &done}, api.Precondition{})
+	assert.NoError(err)
+
+	todos, err := s.GetToDos(ctx, user.ID, repository.ToDoFilter{}, 10, 0)
+	assert.NoError(err)
+	assert.Equal([]int64{first.ID, second.ID, third.ID}, ids(todos))
+
+	todos, err = s.GetToDos(ctx, user.ID, repository.ToDoFilter{Order: repository.OrderPosition}, 10, 0)
+	assert.NoError(err)
+	assert.Equal([]int64{second.ID, third.ID, first.ID}, ids(todos))
+
+	todos, err = s.GetToDos(ctx, user.ID, repository.ToDoFilter{Order: repository.OrderPriority}, 10, 0)
+	assert.NoError(err)
+	assert.Equal([]int64{second.ID, third.ID, first.ID}, ids(todos))
+
+	todos, err = s.GetToDos(ctx, user.ID, repository.ToDoFilter{Order: repository.OrderPosition}, 1, 1)
+	assert.NoError(err)
+	assert.Equal([]int64{third.ID}, ids(todos))
+
+	filter := repository.ToDoFilter{Statuses: []string{api.StatusOpen}}
+	todos, err = s.GetToDos(ctx, user.ID, filter, 10, 0)
+	assert.NoError(err)
+	assert.Equal([]int64{first.ID, second.ID}, ids(todos))
+	count, err := s.CountToDos(ctx, user.ID, filter)
+	assert.NoError(err)
+	assert.Equal(int64(2), count)
+
+	filter = repository.ToDoFilter{Priorities: []string{api.PriorityUrgent, api.PriorityNormal}}
+	todos, err = s.GetToDos(ctx, user.ID, filter, 10, 0)
+	assert.NoError(err)
+	assert.Equal([]int64{second.ID, third.ID}, ids(todos))
+
+	filter = repository.ToDoFilter{CreatedFrom: &second.CreatedAt, Sort: []repository.SortKey{{Field: repository.SortCreated, Desc: true}}}
+	todos, err = s.GetToDos(ctx, user.ID, filter, 10, 0)
+	assert.NoError(err)
+	assert.Equal([]int64{third.ID, second.ID}, ids(todos))
+
+	count, err = s.CountToDos(ctx, missingID, repository.ToDoFilter{})
+	assert.NoError(err)
+	assert.Zero(count)
+}
+
+func testGetToDosAfter(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	due := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, position := range []string{"e", "b", "d", "a", "c"} {
+		todo := api.ToDo{UserID: user.ID, Message: fmt.Sprint(i), Position: position}
+		if i%2 == 0 {
+			todoDue := due.Add(time.Duration(i) * time.Hour)
+			todo.DueAt = &todoDue
+		}
+		newToDo(t, s, todo)
+	}
+
+	filters := []repository.ToDoFilter{
+		{},
+		{Order: repository.OrderPosition},
+		{Order: repository.OrderPriority},
+		{Sort: []repository.SortKey{{Field: repository.SortDue, Desc: true}, {Field: repository.SortPosition}}},
+	}
+	for _, filter := range filters {
+		all, err := s.GetToDos(ctx, user.ID, filter, 10, 0)
+		if !assert.NoError(err) || !assert.Len(all, 5) {
+			continue
+		}
+
+		// Pages of two todos walk through the same order.
+		var walked []api.ToDo
+		var after *repository.ToDoKey
+		for len(walked) < len(all) {
+			page, err := s.GetToDosAfter(ctx, user.ID, filter, after, 2)
+			if !assert.NoError(err) || len(page) == 0 {
+				break
+			}
+			walked = append(walked, page...)
+			last := page[len(page)-1]
+			after = &repository.ToDoKey{
+				ID:          last.ID,
+				CreatedAt:   last.CreatedAt,
+				UpdatedAt:   last.UpdatedAt,
+				Position:    last.Position,
+				Priority:    last.Priority,
+				DueAt:       last.DueAt,
+				CompletedAt: last.CompletedAt,
+			}
+		}
+		assert.Equal(ids(all), ids(walked), "filter %+v", filter)
+
+		rest, err := s.GetToDosAfter(ctx, user.ID, filter, after, 2)
+		assert.NoError(err)
+		assert.Empty(rest)
+	}
+}
+
+func testPositions(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	last, err := s.LastPosition(ctx, user.ID)
+	assert.NoError(err)
+	assert.Equal("", last)
+
+	for _, position := range []string{"c", "a", "e"} {
+		newToDo(t, s, api.ToDo{UserID: user.ID, Message: position, Position: position})
+	}
+	// Positions compare byte by byte, lowercase letters follow uppercase ones.
+	newToDo(t, s, api.ToDo{UserID: user.ID, Message: "B", Position: "B"})
+
+	last, err = s.LastPosition(ctx, user.ID)
+	assert.NoError(err)
+	assert.Equal("e", last)
+
+	for _, tc := range []struct {
+		position string
+		before   bool
+		want     string
+	}{
+		{"c", true, "a"},
+		{"c", false, "e"},
+		{"a", true, "B"},
+		{"B", true, ""},
+		{"e", false, ""},
+		{"b", false, "c"},
+	} {
+		adjacent, err := s.AdjacentPosition(ctx, user.ID, tc.position, tc.before)
+		assert.NoError(err)
+		assert.Equal(tc.want, adjacent, "position %q before %v", tc.position, tc.before)
+	}
+}
+
+func testTags(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	todo := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "plan the trip", Position: "a"})
+	other := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "book the hotel", Position: "b"})
+
+	tagged, err := s.AddToDoTags(ctx, todo.ID, []string{"travel", "family"})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal([]string{"family", "travel"}, tagged.Tags)
+	assert.Equal(todo.Version+1, tagged.Version)
+
+	// Adding a tag twice changes only the version.
+	tagged, err = s.AddToDoTags(ctx, todo.ID, []string{"travel"})
+	assert.NoError(err)
+	assert.Equal([]string{"family", "travel"}, tagged.Tags)
+	_, err = s.AddToDoTags(ctx, other.ID, []string{"travel"})
+	assert.NoError(err)
+
+	tags, err := s.GetTags(ctx, user.ID)
+	assert.NoError(err)
+	assert.Equal([]api.Tag{{Name: "family", Count: 1}, {Name: "travel", Count: 2}}, tags)
+
+	count, err := s.CountToDos(ctx, user.ID, repository.ToDoFilter{AllTags: []string{"family", "travel"}})
+	assert.NoError(err)
+	assert.Equal(int64(1), count)
+	count, err = s.CountToDos(ctx, user.ID, repository.ToDoFilter{AnyTags: []string{"family", "travel"}})
+	assert.NoError(err)
+	assert.Equal(int64(2), count)
+
+	untagged, err := s.RemoveToDoTag(ctx, todo.ID, "family")
+	assert.NoError(err)
+	assert.Equal([]string{"travel"}, untagged.Tags)
+	// The unused tag is gone.
+	tags, err = s.GetTags(ctx, user.ID)
+	assert.NoError(err)
+	assert.Equal([]api.Tag{{Name: "travel", Count: 2}}, tags)
+
+	_, err = s.RemoveToDoTag(ctx, todo.ID, "family")
+	isError(t, err, api.ErrNotFound)
+	_, err = s.RemoveToDoTag(ctx, missingID, "travel")
+	isError(t, err, api.ErrNotFound)
+	_, err = s.AddToDoTags(ctx, missingID, []string{"travel"})
+	isError(t, err, api.ErrNotFound)
+
+	// Deleted todos are not counted.
+	assert.NoError(s.DeleteToDo(ctx, other.ID, api.Precondition{}))
+	tags, err = s.GetTags(ctx, user.ID)
+	assert.NoError(err)
+	assert.Equal([]api.Tag{{Name: "travel", Count: 1}}, tags)
+}
+
+func testSearchToDos(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	apples := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "buy apples", Position: "a"})
+	newToDo(t, s, api.ToDo{UserID: user.ID, Message: "sell the old bike", Position: "b"})
+	deleted := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "buy more apples", Position: "c"})
+	assert.NoError(s.DeleteToDo(ctx, deleted.ID, api.Precondition{}))
+
+	results, total, err := s.SearchToDos(ctx, user.ID, []repository.SearchTerm{{Text: "apple"}}, 10, 0)
+	assert.NoError(err)
+	assert.Equal(int64(1), total)
+	if assert.Len(results, 1) {
+		assert.Equal(apples.ID, results[0].ToDo.ID)
+		assert.Contains(results[0].Snippet, "<mark>apples</mark>")
+		assert.True(results[0].Rank > 0)
+	}
+
+	results, total, err = s.SearchToDos(ctx, user.ID, []repository.SearchTerm{{Text: "bi", Prefix: true}}, 10, 0)
+	assert.NoError(err)
+	assert.Equal(int64(1), total)
+	assert.Len(results, 1)
+
+	results, total, err = s.SearchToDos(ctx, user.ID, []repository.SearchTerm{{Text: "apple"}, {Text: "bike"}}, 10, 0)
+	assert.NoError(err)
+	assert.Zero(total)
+	assert.Empty(results)
+
+	results, total, err = s.SearchToDos(ctx, missingID, []repository.SearchTerm{{Text: "apple"}}, 10, 0)
+	assert.NoError(err)
+	assert.Zero(total)
+	assert.Empty(results)
+}
+
+func testUpdateSeries(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	start := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	first := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "water", Position: "a", Recurrence: "FREQ=DAILY", SeriesStart: &start})
+	assert.Equal(&first.ID, first.SeriesID)
+	done := api.StatusDone
+	_, err := s.PatchToDo(ctx, first.ID, api.ToDoUpdate{Status: &done}, api.Precondition{})
+	assert.NoError(err)
+	next := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "water", Position: "b", Recurrence: "FREQ=DAILY", SeriesID: &first.ID, SeriesStart: &start})
+	assert.Equal(&first.ID, next.SeriesID)
+
+	// Only the open occurrences change.
+	message := "water the roses"
+	changed, err := s.UpdateSeries(ctx, first.ID, api.ToDoUpdate{Message: &message})
+	assert.NoError(err)
+	assert.Equal(int64(1), changed)
+	got, err := s.GetToDo(ctx, next.ID)
+	assert.NoError(err)
+	assert.Equal(message, got.Message)
+	assert.Equal(next.Version+1, got.Version)
+
+	series, err := s.GetToDos(ctx, user.ID, repository.ToDoFilter{SeriesID: &first.ID}, 10, 0)
+	assert.NoError(err)
+	assert.Equal([]int64{first.ID, next.ID}, ids(series))
+
+	changed, err = s.UpdateSeries(ctx, missingID, api.ToDoUpdate{Message: &message})
+	assert.NoError(err)
+	assert.Zero(changed)
+}
+
+func testSubtasks(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	root := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "renovate", Position: "a"})
+	child := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "kitchen", Position: "c", ParentID: &root.ID})
+	sibling := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "bathroom", Position: "b", ParentID: &root.ID})
+	grandchild := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "tiles", Position: "d", ParentID: &child.ID})
+
+	subtasks, err := s.GetSubtasks(ctx, root.ID)
+	assert.NoError(err)
+	assert.Equal([]int64{sibling.ID, child.ID}, ids(subtasks))
+
+	ancestors, err := s.GetAncestorIDs(ctx, grandchild.ID)
+	assert.NoError(err)
+	assert.Equal([]int64{child.ID, root.ID}, ancestors)
+	ancestors, err = s.GetAncestorIDs(ctx, root.ID)
+	assert.NoError(err)
+	assert.Empty(ancestors)
+
+	done, cancelled := api.StatusDone, api.StatusCancelled
+	_, err = s.PatchToDo(ctx, sibling.ID, api.ToDoUpdate{Status: &done}, api.Precondition{})
+	assert.NoError(err)
+	got, err := s.GetToDo(ctx, root.ID)
+	assert.NoError(err)
+	assert.Equal(&api.Progress{Done: 1, Total: 2}, got.Progress)
+	_, err = s.PatchToDo(ctx, child.ID, api.ToDoUpdate{Status: &cancelled}, api.Precondition{})
+	assert.NoError(err)
+	got, err = s.GetToDo(ctx, root.ID)
+	assert.NoError(err)
+	assert.Equal(&api.Progress{Done: 1, Total: 1}, got.Progress)
+
+	missing := int64(missingID)
+	_, err = s.PatchToDo(ctx, child.ID, api.ToDoUpdate{ParentID: &api.IDUpdate{ID: &missing}}, api.Precondition{})
+	isError(t, err, api.ErrValidation)
+	moved, err := s.PatchToDo(ctx, child.ID, api.ToDoUpdate{ParentID: &api.IDUpdate{}}, api.Precondition{})
+	assert.NoError(err)
+	assert.Nil(moved.ParentID)
+
+	subtasks, err = s.GetSubtasks(ctx, missingID)
+	assert.NoError(err)
+	assert.Empty(subtasks)
+}
+
+func testLists(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	work, err := s.CreateList(ctx, api.List{UserID: user.ID, Name: "work", Color: "#aa5500"})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(user.ID, work.UserID)
+	assert.Equal("#aa5500", work.Color)
+	assert.False(work.Archived)
+	home, err := s.CreateList(ctx, api.List{UserID: user.ID, Name: "home", Archived: true})
+	assert.NoError(err)
+
+	_, err = s.CreateList(ctx, api.List{UserID: user.ID, Name: "work"})
+	isError(t, err, api.ErrConflict)
+	_, err = s.CreateList(ctx, api.List{UserID: missingID, Name: "work"})
+	isError(t, err, api.ErrValidation)
+
+	got, err := s.GetList(ctx, work.ID)
+	assert.NoError(err)
+	assert.Equal(work, got)
+	_, err = s.GetList(ctx, missingID)
+	isError(t, err, api.ErrNotFound)
+
+	lists, err := s.GetLists(ctx, user.ID, false)
+	assert.NoError(err)
+	assert.Equal([]api.List{*work}, lists)
+	lists, err = s.GetLists(ctx, user.ID, true)
+	assert.NoError(err)
+	assert.Equal([]api.List{*home, *work}, lists)
+
+	home.Archived, home.Color = false, "#00aa55"
+	updated, err := s.UpdateList(ctx, *home)
+	assert.NoError(err)
+	assert.Equal(home, updated)
+	home.Name = "work"
+	_, err = s.UpdateList(ctx, *home)
+	isError(t, err, api.ErrConflict)
+	_, err = s.UpdateList(ctx, api.List{ID: missingID, Name: "garden"})
+	isError(t, err, api.ErrNotFound)
+
+	// Deleting a list moves its todos to the inbox.
+	todo := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "write the report", Position: "a", ListID: &work.ID})
+	assert.NoError(s.DeleteList(ctx, work.ID))
+	_, err = s.GetList(ctx, work.ID)
+	isError(t, err, api.ErrNotFound)
+	inbox, err := s.GetToDo(ctx, todo.ID)
+	assert.NoError(err)
+	assert.Nil(inbox.ListID)
+	isError(t, s.DeleteList(ctx, work.ID), api.ErrNotFound)
+}
+
+func testAPITokens(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	other := newUser(t, s)
+	hash := []byte(user.Name)
+	token, err := s.CreateAPIToken(ctx, api.APIToken{UserID: user.ID, Name: "ci"}, hash)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(user.ID, token.UserID)
+	assert.Equal("ci", token.Name)
+	assert.False(token.CreatedAt.IsZero())
+	second, err := s.CreateAPIToken(ctx, api.APIToken{UserID: user.ID, Name: "laptop"}, []byte(user.Name+"-laptop"))
+	assert.NoError(err)
+
+	_, err = s.CreateAPIToken(ctx, api.APIToken{UserID: other.ID, Name: "ci"}, hash)
+	isError(t, err, api.ErrConflict)
+	_, err = s.CreateAPIToken(ctx, api.APIToken{UserID: missingID, Name: "ci"}, []byte(other.Name))
+	isError(t, err, api.ErrValidation)
+
+	tokens, err := s.GetAPITokens(ctx, user.ID)
+	assert.NoError(err)
+	assert.Equal([]api.APIToken{*token, *second}, tokens)
+	tokens, err = s.GetAPITokens(ctx, other.ID)
+	assert.NoError(err)
+	assert.Empty(tokens)
+
+	owner, err := s.GetAPITokenUser(ctx, hash)
+	assert.NoError(err)
+	assert.Equal(user, owner)
+	_, err = s.GetAPITokenUser(ctx, []byte(other.Name))
+	isError(t, err, api.ErrNotFound)
+
+	// Only the owner deletes the token.
+	isError(t, s.DeleteAPIToken(ctx, other.ID, token.ID), api.ErrNotFound)
+	assert.NoError(s.DeleteAPIToken(ctx, user.ID, token.ID))
+	_, err = s.GetAPITokenUser(ctx, hash)
+	isError(t, err, api.ErrNotFound)
+
+	// Deleting the user deletes its tokens.
+	assert.NoError(s.DeleteUser(ctx, user.ID))
+	_, err = s.GetAPITokenUser(ctx, []byte(user.Name+"-laptop"))
+	isError(t, err, api.ErrNotFound)
+}
+
+func testWithTx(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	var committed *api.ToDo
+	err := s.WithTx(ctx, func(tx repository.Storage) error {
+		committed = newToDo(t, tx, api.ToDo{UserID: user.ID, Message: "water the plants", Position: "a"})
+		// The transaction reads its own changes.
+		got, err := tx.GetToDo(ctx, committed.ID)
+		assert.NoError(err)
+		assert.Equal(committed, got)
+		return nil
+	})
+	assert.NoError(err)
+	got, err := s.GetToDo(ctx, committed.ID)
+	assert.NoError(err)
+	assert.Equal(committed, got)
+
+	// A failed fn rolls back all of its changes and its error is returned.
+	var rolledBack *api.ToDo
+	message := "water the garden"
+	err = s.WithTx(ctx, func(tx repository.Storage) error {
+		rolledBack = newToDo(t, tx, api.ToDo{UserID: user.ID, Message: "mow the lawn", Position: "b"})
+		if _, err := tx.PatchToDo(ctx, committed.ID, api.ToDoUpdate{Message: &message}, api.Precondition{}); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	assert.True(errors.Is(err, errFailed), "want %v, got %v", errFailed, err)
+	_, err = s.GetToDo(ctx, rolledBack.ID)
+	isError(t, err, api.ErrNotFound)
+	got, err = s.GetToDo(ctx, committed.ID)
+	assert.NoError(err)
+	assert.Equal(committed, got)
+
+	// Domain errors keep their kind.
+	err = s.WithTx(ctx, func(tx repository.Storage) error {
+		_, err := tx.GetToDo(ctx, missingID)
+		return err
+	})
+	isError(t, err, api.ErrNotFound)
+}
+
+func testNestedWithTx(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+
+	// The inner WithTx runs in the outer transaction, a failure after it rolls it back too.
+	var outer, inner *api.ToDo
+	err := s.WithTx(ctx, func(tx repository.Storage) error {
+		outer = newToDo(t, tx, api.ToDo{UserID: user.ID, Message: "plan the trip", Position: "a"})
+		if err := tx.WithTx(ctx, func(tx repository.Storage) error {
+			inner = newToDo(t, tx, api.ToDo{UserID: user.ID, Message: "book the hotel", Position: "b"})
+			return nil
+		}); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	assert.True(errors.Is(err, errFailed), "want %v, got %v", errFailed, err)
+	_, err = s.GetToDo(ctx, outer.ID)
+	isError(t, err, api.ErrNotFound)
+	_, err = s.GetToDo(ctx, inner.ID)
+	isError(t, err, api.ErrNotFound)
+
+	// A failed inner WithTx fails the outer transaction when it returns the error.
+	err = s.WithTx(ctx, func(tx repository.Storage) error {
+		outer = newToDo(t, tx, api.ToDo{UserID: user.ID, Message: "plan the trip", Position: "a"})
+		return tx.WithTx(ctx, func(tx repository.Storage) error {
+			inner = newToDo(t, tx, api.ToDo{UserID: user.ID, Message: "book the hotel", Position: "b"})
+			return errFailed
+		})
+	})
+	assert.True(errors.Is(err, errFailed), "want %v, got %v", errFailed, err)
+	_, err = s.GetToDo(ctx, outer.ID)
+	isError(t, err, api.ErrNotFound)
+	_, err = s.GetToDo(ctx, inner.ID)
+	isError(t, err, api.ErrNotFound)
+
+	err = s.WithTx(ctx, func(tx repository.Storage) error {
+		outer = newToDo(t, tx, api.ToDo{UserID: user.ID, Message: "plan the trip", Position: "a"})
+		return tx.WithTx(ctx, func(tx repository.Storage) error {
+			inner = newToDo(t, tx, api.ToDo{UserID: user.ID, Message: "book the hotel", Position: "b"})
+			return nil
+		})
+	})
+	assert.NoError(err)
+	todos, err := s.GetToDos(ctx, user.ID, repository.ToDoFilter{}, 10, 0)
+	assert.NoError(err)
+	assert.ElementsMatch([]int64{outer.ID, inner.ID}, ids(todos))
+}
+
+// createOp returns a batch operation that creates a todo of the user and stores it in created.
+func createOp(t *testing.T, userID int64, message string, created **api.ToDo) func(repository.Storage) error {
+	return func(s repository.Storage) error {
+		todo, err := s.CreateToDo(context.Background(), api.ToDo{UserID: userID, Message: message, Priority: api.PriorityNormal, Position: "a"})
+		*created = todo
+		return err
+	}
+}
+
+func testBatch(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	existing := newToDo(t, s, api.ToDo{UserID: user.ID, Message: "call the plumber", Position: "a"})
+	var first, undone, last *api.ToDo
+	message := "call the electrician"
+	errs, err := s.Batch(ctx, []func(repository.Storage) error{
+		createOp(t, user.ID, "buy paint", &first),
+		// A failed operation is undone alone, with the changes it made before failing.
+		func(s repository.Storage) error {
+			if err := createOp(t, user.ID, "buy brushes", &undone)(s); err != nil {
+				return err
+			}
+			if _, err := s.PatchToDo(ctx, existing.ID, api.ToDoUpdate{Message: &message}, api.Precondition{}); err != nil {
+				return err
+			}
+			return errFailed
+		},
+		func(s repository.Storage) error {
+			_, err := s.PatchToDo(ctx, missingID, api.ToDoUpdate{Message: &message}, api.Precondition{})
+			return err
+		},
+		createOp(t, user.ID, "paint the wall", &last),
+	}, false)
+	if !assert.NoError(err) || !assert.Len(errs, 4) {
+		return
+	}
+	assert.NoError(errs[0])
+	assert.True(errors.Is(errs[1], errFailed), "want %v, got %v", errFailed, errs[1])
+	isError(t, errs[2], api.ErrNotFound)
+	assert.NoError(errs[3])
+
+	// SQLite hands the id of the undone todo out again, so the todos are told apart by message.
+	todos, err := s.GetToDos(ctx, user.ID, repository.ToDoFilter{}, 10, 0)
+	assert.NoError(err)
+	messages := []string{}
+	for _, todo := range todos {
+		messages = append(messages, todo.Message)
+	}
+	assert.ElementsMatch([]string{"call the plumber", "buy paint", "paint the wall"}, messages)
+	assert.NotNil(undone)
+	got, err := s.GetToDo(ctx, existing.ID)
+	assert.NoError(err)
+	assert.Equal(existing, got)
+
+	// Batches do not nest in transactions.
+	err = s.WithTx(ctx, func(tx repository.Storage) error {
+		_, err := tx.Batch(ctx, []func(repository.Storage) error{createOp(t, user.ID, "clean up", &last)}, false)
+		return err
+	})
+	assert.Error(err)
+}
+
+func testAtomicBatch(t *testing.T, s repository.Storage) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	user := newUser(t, s)
+	var first, second *api.ToDo
+	errs, err := s.Batch(ctx, []func(repository.Storage) error{
+		createOp(t, user.ID, "pack the tent", &first),
+		createOp(t, user.ID, "pack the stove", &second),
+	}, true)
+	assert.NoError(err)
+	assert.Equal([]error{nil, nil}, errs)
+	todos, err := s.GetToDos(ctx, user.ID, repository.ToDoFilter{}, 10, 0)
+	assert.NoError(err)
+	assert.ElementsMatch([]int64{first.ID, second.ID}, ids(todos))
+
+	// The first failure rolls back the whole batch, the operations after it do not run.
+	var created *api.ToDo
+	ran := false
+	errs, err = s.Batch(ctx, []func(repository.Storage) error{
+		createOp(t, user.ID, "pack the map", &created),
+		func(s repository.Storage) error {
+			return s.DeleteToDo(ctx, first.ID, api.Precondition{})
+		},
+		func(s repository.Storage) error {
+			_, err := s.GetToDo(ctx, missingID)
+			return err
+		},
+		func(s repository.Storage) error {
+			ran = true
+			return nil
+		},
+	}, true)
+	if !assert.NoError(err) || !assert.Len(errs, 4) {
+		return
+	}
+	assert.NoError(errs[0])
+	assert.NoError(errs[1])
+	isError(t, errs[2], api.ErrNotFound)
+	assert.NoError(errs[3])
+	assert.False(ran)
+
+	_, err = s.GetToDo(ctx, created.ID)
+	isError(t, err, api.ErrNotFound)
+	got, err := s.GetToDo(ctx, first.ID)
+	assert.NoError(err)
+	assert.Equal(first, got)
+}